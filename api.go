@@ -0,0 +1,21 @@
+package ovhwrapper
+
+import (
+	"context"
+
+	"github.com/ovh/go-ovh/ovh"
+)
+
+// API is the subset of the OVH API client used by ovhwrapper.
+// *ovh.Client implements it, but every function of the library accepts the interface, so callers can
+// wrap the client (e.g. for logging or rate limiting) or replace it with a fake in their own tests.
+// All calls take a context.Context, which allows cancelling or putting deadlines on long-running scans.
+type API interface {
+	GetWithContext(ctx context.Context, url string, resType any) error
+	PostWithContext(ctx context.Context, url string, reqBody, resType any) error
+	PutWithContext(ctx context.Context, url string, reqBody, resType any) error
+	DeleteWithContext(ctx context.Context, url string, resType any) error
+}
+
+// make sure the go-ovh client always satisfies the API interface
+var _ API = (*ovh.Client)(nil)
@@ -3,11 +3,11 @@ package main
 import (
 	"encoding/json"
 	"fmt"
+	"github.com/snafuprinzip/ovhwrapper"
 	"io"
 	"log"
 	"net/http"
 	"os"
-	"ovhwrapper"
 	"time"
 )
 
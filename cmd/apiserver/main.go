@@ -6,11 +6,11 @@ package main
 
 import (
 	"fmt"
+	"github.com/snafuprinzip/ovhwrapper"
 	"gopkg.in/yaml.v3"
 	"log"
 	"net/http"
 	"os"
-	"ovhwrapper"
 )
 
 const debug = false
@@ -1,32 +1,38 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	goteamsnotify "github.com/atc0005/go-teams-notify/v2"
 	"github.com/atc0005/go-teams-notify/v2/adaptivecard"
 	"github.com/ovh/go-ovh/ovh"
 	"github.com/snafuprinzip/ovhwrapper"
+	"github.com/snafuprinzip/ovhwrapper/inventory"
 	"log"
 	"net/smtp"
 	"os"
 	"strings"
+	"time"
 )
 
-// CollectInformation collects the information of all service lines, including their clusters down to the nodes.
-func CollectInformation(client *ovh.Client) []ovhwrapper.ServiceLine {
-	var servicelines []ovhwrapper.ServiceLine
-
-	services := ovhwrapper.GetServicelines(client)
-	for _, service := range services {
-		serviceline := CollectServiceline(client, service)
-		servicelines = append(servicelines, *serviceline)
+// gatherServicelines collects all servicelines including their clusters. Resources which could not be gathered
+// are logged and left out, only a failing serviceline list is fatal.
+func gatherServicelines(ctx context.Context, client ovhwrapper.API) []ovhwrapper.ServiceLine {
+	servicelines, err := inventory.New(client, inventory.Options{Clusters: true}).Gather(ctx)
+	if errs, ok := inventory.AsErrors(err); ok {
+		for _, resErr := range errs {
+			log.Printf("Failed to gather %s", explainError(resErr))
+		}
+	} else if err != nil {
+		log.Fatalf("Failed to get servicelines: %s", explainError(err))
 	}
-
 	return servicelines
 }
 
 // GetServiceline asks the API for 'shallow' information about a specific serviceline, excluding the clusters.
-func GetServiceline(client *ovh.Client, serviceid string) *ovhwrapper.ServiceLine {
-	servicedetails, err := ovhwrapper.GetServicelineDetails(client, serviceid)
+func GetServiceline(ctx context.Context, client ovhwrapper.API, serviceid string) *ovhwrapper.ServiceLine {
+	servicedetails, err := ovhwrapper.GetServicelineDetails(ctx, client, serviceid)
 	if err != nil {
 		log.Fatalf("Failed to get serviceline details: %v", err)
 	}
@@ -38,46 +44,15 @@ func GetServiceline(client *ovh.Client, serviceid string) *ovhwrapper.ServiceLin
 	return &serviceline
 }
 
-// CollectServiceline collects information about a serviceline, including its clusters.
-func CollectServiceline(client *ovh.Client, serviceid string) *ovhwrapper.ServiceLine {
-	servicedetails, err := ovhwrapper.GetServicelineDetails(client, serviceid)
-	if err != nil {
-		log.Fatalf("Failed to get serviceline details: %v", err)
-	}
-	clusterids, err := ovhwrapper.GetK8SClusterIDs(client, serviceid)
-	if err != nil {
-		log.Fatalf("Failed to get cluster IDs: %v", err)
-	}
-	var clusterlist []ovhwrapper.K8SCluster
-	for _, clusterid := range clusterids {
-		cluster := CollectCluster(client, serviceid, clusterid)
-		if cluster != nil {
-			clusterlist = append(clusterlist, *cluster)
-		}
-	}
-	serviceline := ovhwrapper.ServiceLine{
-		ID:        serviceid,
-		SLDetails: servicedetails,
-		Cluster:   clusterlist,
+// gatherCluster returns a cluster including its etcd usage, nodepools and nodes, or nil if it could not be fetched.
+func gatherCluster(ctx context.Context, client ovhwrapper.API, serviceid, clusterid string) *ovhwrapper.K8SCluster {
+	cluster, err := inventory.New(client, inventory.Options{}).GatherCluster(ctx, serviceid, clusterid)
+	if cluster == nil {
+		log.Printf("Failed to get cluster %s: %s", clusterid, explainError(err))
+		return nil
 	}
-
-	return &serviceline
-}
-
-// GetCluster asks the API for 'shallow' information about a specific cluster, excluding nested information
-// like etcd usage, nodes or nodepools
-func GetCluster(client *ovh.Client, serviceid, clusterid string) *ovhwrapper.K8SCluster {
-	return ovhwrapper.GetK8SCluster(client, serviceid, clusterid)
-}
-
-// CollectCluster returns information about a Cluster, including its etcd usage, nodepools and nodes.
-func CollectCluster(client *ovh.Client, serviceid, clusterid string) *ovhwrapper.K8SCluster {
-	cluster := ovhwrapper.GetK8SCluster(client, serviceid, clusterid)
-	var err error
-
-	cluster, err = ovhwrapper.GetK8SClusterDetails(client, cluster, serviceid, clusterid)
 	if err != nil {
-		log.Printf("Failed to get cluster details: %v", err)
+		log.Printf("Failed to get cluster details: %s", explainError(err))
 	}
 	return cluster
 }
@@ -98,6 +73,87 @@ func MatchItem[T ovhwrapper.ServiceLine | ovhwrapper.K8SCluster](object T, ident
 	return match
 }
 
+// connect reads the configuration of the given profile and creates the api clients. If the writer has no
+// consumer key yet, a new one is created and saved and the program exits, as the key has to be validated first.
+func connect(ctx context.Context, profile string) (ovhwrapper.Configuration, *ovh.Client, *ovh.Client) {
+	config, err := ovhwrapper.ReadProfile(profile)
+	if err != nil {
+		log.Fatalf("Error loading configuration, no valid config found: %v", err)
+	}
+	for _, warning := range config.Warnings() {
+		log.Printf("Warning: %s", warning)
+	}
+
+	reader, err := ovhwrapper.CreateReader(config)
+	if err != nil {
+		log.Fatalf("Error creating OVH API Reader: %q\n", err)
+	}
+
+	writer, err := ovhwrapper.CreateWriter(config)
+	if err != nil {
+		log.Fatalf("Error creating OVH API Writer: %q\n", err)
+	}
+	debugTransport(reader, writer)
+
+	// create Writer ConsumerKey if necessary
+	if config.Writer.ConsumerKey == "" {
+		consumerkey, validationURL, err := ovhwrapper.CreateConsumerKey(ctx, reader, writer)
+		if err != nil {
+			log.Fatalf("Error creating consumer key: %s\n", explainError(err))
+		}
+		fmt.Printf("Generated consumer key for profile %s: %s\n", config.ProfileName(), consumerkey)
+		fmt.Printf("Please visit %s to validate it\n", validationURL)
+		config.Writer.ConsumerKey = consumerkey
+
+		if err := config.Save(); err != nil {
+			log.Fatalf("Error saving config file %s: %q\n", config.GetPath(), err)
+		}
+		os.Exit(0)
+	}
+
+	return config, reader, writer
+}
+
+// explainError returns the error message together with a hint on how to solve the most common api errors
+func explainError(err error) string {
+	switch {
+	case errors.Is(err, ovhwrapper.ErrUnauthorized):
+		return fmt.Sprintf("%v\nthe consumer key is invalid or has expired, run 'ovhcon logout' to create a new one", err)
+	case errors.Is(err, ovhwrapper.ErrForbidden):
+		return fmt.Sprintf("%v\nyour writer key has no rights on this resource (e.g. a serviceline created after the key), "+
+			"run 'ovhcon logout' to create a new one", err)
+	case errors.Is(err, ovhwrapper.ErrNotFound):
+		return fmt.Sprintf("%v\nthe resource does not exist (anymore)", err)
+	case errors.Is(err, ovhwrapper.ErrRateLimited):
+		return fmt.Sprintf("%v\ntoo many requests, please try again later", err)
+	}
+	return err.Error()
+}
+
+// debugTransport logs every retry of the api clients once debug output is enabled
+func debugTransport(clients ...*ovh.Client) {
+	for _, client := range clients {
+		if transport, ok := ovhwrapper.GetRetryTransport(client); ok {
+			transport.Debugf = func(format string, args ...any) {
+				if debug {
+					log.Printf(format, args...)
+				}
+			}
+		}
+	}
+}
+
+// printTransportStats prints the request and retry counters of an api client
+func printTransportStats(name string, client *ovh.Client) {
+	transport, ok := ovhwrapper.GetRetryTransport(client)
+	if !ok {
+		return
+	}
+	stats := transport.Stats()
+	log.Printf("%s: %d requests, %d retries, %d rate limited, %d server errors, %d failed\n", name,
+		stats.Requests, stats.Retries, stats.RateLimited, stats.ServerError, stats.Failed)
+}
+
 // fileExists returns true if a file exists, false if not
 func fileExists(filename string) bool {
 	_, err := os.Stat(filename)
@@ -107,6 +163,16 @@ func fileExists(filename string) bool {
 	return err == nil
 }
 
+// sleepContext pauses for the given duration and returns false if the context got cancelled in the meantime
+func sleepContext(ctx context.Context, d time.Duration) bool {
+	select {
+	case <-ctx.Done():
+		return false
+	case <-time.After(d):
+		return true
+	}
+}
+
 func SendMail(subject, body string, to []string) error {
 	r := strings.NewReplacer("\r\n", "", "\r", "", "\n", "", "%0a", "", "%0d", "")
 
@@ -7,6 +7,8 @@ import (
 	"github.com/urfave/cli/v3"
 	"log"
 	"os"
+	"os/signal"
+	"syscall"
 )
 
 // global command options
@@ -32,38 +34,16 @@ func main() {
 	var writer *ovh.Client
 	var config ovhwrapper.Configuration
 
-	config, err := ovhwrapper.ReadConfiguration()
-	if err != nil {
-		log.Fatalf("Error loading configuration, no valid config found: %v", err)
-	}
-
-	reader, err = ovhwrapper.CreateReader(config)
-	if err != nil {
-		log.Fatalf("Error creating OVH API Reader: %q\n", err)
-	}
-
-	writer, err = ovhwrapper.CreateWriter(config)
-	if err != nil {
-		log.Fatalf("Error creating OVH API Writer: %q\n", err)
-	}
-
-	// create Writer ConsumerKey if necessary
-	if config.Writer.ConsumerKey == "" {
-		// consumer key erzeugen
-		consumerkey, err := ovhwrapper.CreateConsumerKey(reader, writer)
-		if err != nil {
-			log.Fatalf("Error: %q\n", err)
-		}
-		config.Writer.ConsumerKey = consumerkey
-
-		err = ovhwrapper.SaveYaml(config, config.GetPath())
-		if err != nil {
-			log.Fatalf("Error saving config file %s: %q\n", config.GetPath(), err)
-		}
-		os.Exit(0)
-	}
+	// cancel running api requests and update monitoring on ctrl-c
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
 	globalFlags := []cli.Flag{
+		&cli.StringFlag{
+			Name:    "profile",
+			Usage:   "use the named profile of the configuration file",
+			Sources: cli.EnvVars("OVH_PROFILE"),
+		},
 		&cli.BoolFlag{
 			Name:  "debug",
 			Usage: "enable debug output",
@@ -89,6 +69,20 @@ func main() {
 		Usage:     "cli tool for the ovh api",
 		UsageText: "ovhcon <command> [subcommand] [options]",
 		Flags:     globalFlags,
+		Before: func(ctx context.Context, cmd *cli.Command) (context.Context, error) {
+			// flag actions run after the before hooks, set the global options here for the setup
+			debug = cmd.Bool("debug")
+			verbose = cmd.Bool("verbose")
+			config, reader, writer = connect(ctx, cmd.String("profile"))
+			return ctx, nil
+		},
+		After: func(ctx context.Context, cmd *cli.Command) error {
+			if debug {
+				printTransportStats("reader", reader)
+				printTransportStats("writer", writer)
+			}
+			return nil
+		},
 		Commands: []*cli.Command{
 			{
 				Name:    "list",
@@ -99,7 +93,7 @@ func main() {
 					&cli.StringFlag{Name: "serviceline", Aliases: []string{"s"}, Usage: "list clusters of given serviceline"},
 				},
 				Action: func(ctx context.Context, cmd *cli.Command) error {
-					list(reader, cmd.Bool("all"), cmd.String("serviceline"))
+					list(ctx, reader, cmd.Bool("all"), cmd.String("serviceline"))
 					return nil
 				},
 			},
@@ -113,7 +107,7 @@ func main() {
 					&cli.StringFlag{Name: "cluster", Aliases: []string{"c"}, Usage: "specific cluster of a given serviceline"},
 				},
 				Action: func(ctx context.Context, cmd *cli.Command) error {
-					status(reader, cmd.Bool("all"), cmd.String("serviceline"), cmd.String("cluster"))
+					status(ctx, reader, cmd.Bool("all"), cmd.String("serviceline"), cmd.String("cluster"))
 					return nil
 				},
 			},
@@ -128,7 +122,7 @@ func main() {
 					&cli.StringFlag{Name: "output", Aliases: []string{"o"}, Usage: "set output format [yaml, json, text]"},
 				},
 				Action: func(ctx context.Context, cmd *cli.Command) error {
-					describe(reader, cmd.Bool("all"), cmd.String("serviceline"), cmd.String("cluster"), cmd.String("output"))
+					describe(ctx, reader, cmd.Bool("all"), cmd.String("serviceline"), cmd.String("cluster"), cmd.String("output"))
 					return nil
 				},
 			},
@@ -152,7 +146,7 @@ func main() {
 									"if background is set the program will exit immediately after starting the upgrade"},
 						},
 						Action: func(ctx context.Context, cmd *cli.Command) error {
-							UpdateCluster(reader, writer, config, cmd.String("serviceline"), cmd.String("cluster"),
+							UpdateCluster(ctx, reader, writer, config, cmd.String("serviceline"), cmd.String("cluster"),
 								cmd.Bool("background"), cmd.Bool("latest"), cmd.Bool("force"))
 							return nil
 						},
@@ -169,7 +163,7 @@ func main() {
 								Usage: "set strategy to LATEST_PATCH (default is NEXT_MINOR)"},
 						},
 						Action: func(ctx context.Context, cmd *cli.Command) error {
-							UpdateClusterGroup(reader, writer, config, cmd.String("clustergroup"), cmd.String("inventory"),
+							UpdateClusterGroup(ctx, reader, writer, config, cmd.String("clustergroup"), cmd.String("inventory"),
 								cmd.Bool("latest"), cmd.Bool("force"))
 							return nil
 						},
@@ -195,7 +189,7 @@ func main() {
 						},
 						Action: func(ctx context.Context, cmd *cli.Command) error {
 
-							DownloadKubeconfig(reader, writer, cmd.Bool("all"), cmd.String("serviceline"),
+							DownloadKubeconfig(ctx, reader, writer, cmd.Bool("all"), cmd.String("serviceline"),
 								cmd.String("cluster"), cmd.String("output"), cmd.String("path"))
 							return nil
 						},
@@ -211,7 +205,7 @@ func main() {
 									"if background is set the program will exit immediately after starting the reset"},
 						},
 						Action: func(ctx context.Context, cmd *cli.Command) error {
-							ResetKubeconfig(reader, writer, config, cmd.String("serviceline"),
+							ResetKubeconfig(ctx, reader, writer, config, cmd.String("serviceline"),
 								cmd.String("cluster"), cmd.Bool("background"))
 							//fmt.Println("reset kubeconfig: ", cmd.Args().First())
 							return nil
@@ -227,7 +221,7 @@ func main() {
 					&cli.StringFlag{Name: "output", Aliases: []string{"o"}, Usage: "set output format [yaml, json, text]"},
 				},
 				Action: func(ctx context.Context, cmd *cli.Command) error {
-					credentials(reader, writer, cmd.String("output"))
+					credentials(ctx, reader, writer, cmd.String("output"))
 					return nil
 				},
 			},
@@ -236,14 +230,14 @@ func main() {
 				Aliases: []string{"o"},
 				Usage:   "revoke consumer key, next time the command will be run it will create a new consumer key",
 				Action: func(ctx context.Context, cmd *cli.Command) error {
-					Logout(writer, config)
+					Logout(ctx, writer, config)
 					return nil
 				},
 			},
 		},
 	}
 
-	if err := cmd.Run(context.Background(), os.Args); err != nil {
+	if err := cmd.Run(ctx, os.Args); err != nil {
 		log.Fatal(err)
 	}
 }
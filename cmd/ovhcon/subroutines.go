@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"encoding/base64"
 	"fmt"
 	"gopkg.in/yaml.v3"
@@ -12,7 +13,6 @@ import (
 	"sync"
 	"time"
 
-	"github.com/ovh/go-ovh/ovh"
 	"github.com/snafuprinzip/ovhwrapper"
 )
 
@@ -33,14 +33,14 @@ type CGProject struct {
 }
 
 // credentials returns information about the reader and writer accounts in different formats (yaml, json or text)
-func credentials(reader, writer *ovh.Client, format string) {
-	rcred, err := ovhwrapper.GetCredential(reader)
+func credentials(ctx context.Context, reader, writer ovhwrapper.API, format string) {
+	rcred, err := ovhwrapper.GetCredential(ctx, reader)
 	if err != nil {
-		log.Printf("Error getting reader credentials: %q\n", err)
+		log.Printf("Error getting reader credentials: %s\n", explainError(err))
 	}
-	wcred, err := ovhwrapper.GetCredential(writer)
+	wcred, err := ovhwrapper.GetCredential(ctx, writer)
 	if err != nil {
-		log.Printf("Error getting writer credentials: %q\n", err)
+		log.Printf("Error getting writer credentials: %s\n", explainError(err))
 	}
 
 	switch format {
@@ -69,17 +69,20 @@ func credentials(reader, writer *ovh.Client, format string) {
 
 // list lists servicelines and their clusters (when -a is set),
 // servicelines (when -s is not set) or clusters (when -s is set)
-func list(client *ovh.Client, all bool, serviceid string) {
+func list(ctx context.Context, client ovhwrapper.API, all bool, serviceid string) {
 	var err error
 	var sls []ovhwrapper.ServiceLine
 
 	//fmt.Println(all, serviceid)
 
 	// Get flat Serviceline info
-	slids := ovhwrapper.GetServicelines(client) // list of sl ids
+	slids, err := ovhwrapper.GetServicelines(ctx, client) // list of sl ids
+	if err != nil {
+		log.Fatalf("Failed to get servicelines: %s", explainError(err))
+	}
 	for _, slid := range slids {
 		sl := ovhwrapper.ServiceLine{ID: slid}
-		sl.SLDetails, err = ovhwrapper.GetServicelineDetails(client, slid)
+		sl.SLDetails, err = ovhwrapper.GetServicelineDetails(ctx, client, slid)
 		if err != nil {
 			log.Fatalf("Failed to get service lines: %v", err)
 		}
@@ -88,13 +91,17 @@ func list(client *ovh.Client, all bool, serviceid string) {
 
 	if all { // list all servicelines and their clusters
 		for idx := range sls {
-			clusterids, err := ovhwrapper.GetK8SClusterIDs(client, sls[idx].ID)
+			clusterids, err := ovhwrapper.GetK8SClusterIDs(ctx, client, sls[idx].ID)
 			if err != nil {
 				log.Fatalf("Failed to get cluster IDs: %v", err)
 			}
 			var clusterlist []ovhwrapper.K8SCluster
 			for _, clusterid := range clusterids {
-				cluster := ovhwrapper.GetK8SCluster(client, sls[idx].ID, clusterid)
+				cluster, err := ovhwrapper.GetK8SCluster(ctx, client, sls[idx].ID, clusterid)
+				if err != nil {
+					log.Printf("Failed to get cluster %s: %s", clusterid, explainError(err))
+					continue
+				}
 				//fmt.Printf("sl %-2d: %s\t%v\n", idx, clusterid, cluster)
 				if cluster != nil {
 					clusterlist = append(clusterlist, *cluster)
@@ -126,13 +133,17 @@ func list(client *ovh.Client, all bool, serviceid string) {
 			return
 		}
 
-		clusterids, err := ovhwrapper.GetK8SClusterIDs(client, sl.ID)
+		clusterids, err := ovhwrapper.GetK8SClusterIDs(ctx, client, sl.ID)
 		if err != nil {
 			log.Fatalf("Failed to get cluster IDs: %v", err)
 		}
 		var clusterlist []ovhwrapper.K8SCluster
 		for _, clusterid := range clusterids {
-			cluster := ovhwrapper.GetK8SCluster(client, sl.ID, clusterid)
+			cluster, err := ovhwrapper.GetK8SCluster(ctx, client, sl.ID, clusterid)
+			if err != nil {
+				log.Printf("Failed to get cluster %s: %s", clusterid, explainError(err))
+				continue
+			}
 			//fmt.Printf("sl %-2d: %s\t%v\n", idx, clusterid, cluster)
 			if cluster != nil {
 				clusterlist = append(clusterlist, *cluster)
@@ -152,7 +163,7 @@ func list(client *ovh.Client, all bool, serviceid string) {
 	}
 }
 
-func DownloadKubeconfig(reader, writer *ovh.Client, all bool, serviceid, clusterid, output, outpath string) {
+func DownloadKubeconfig(ctx context.Context, reader, writer ovhwrapper.API, all bool, serviceid, clusterid, output, outpath string) {
 	var sls []ovhwrapper.ServiceLine
 	var err error
 	globalconfig := ovhwrapper.KubeConfig{
@@ -165,20 +176,26 @@ func DownloadKubeconfig(reader, writer *ovh.Client, all bool, serviceid, cluster
 	}
 
 	// Get flat Serviceline and cluster info
-	slids := ovhwrapper.GetServicelines(reader) // list of sl ids
+	slids, err := ovhwrapper.GetServicelines(ctx, reader) // list of sl ids
+	if err != nil {
+		log.Fatalf("Failed to get servicelines: %s", explainError(err))
+	}
 	for _, slid := range slids {
 		sl := ovhwrapper.ServiceLine{ID: slid}
-		sl.SLDetails, err = ovhwrapper.GetServicelineDetails(reader, slid)
+		sl.SLDetails, err = ovhwrapper.GetServicelineDetails(ctx, reader, slid)
 		if err != nil {
 			log.Fatalf("Failed to get service lines: %v", err)
 		}
-		clusterids, err := ovhwrapper.GetK8SClusterIDs(reader, sl.ID)
+		clusterids, err := ovhwrapper.GetK8SClusterIDs(ctx, reader, sl.ID)
 		if err != nil {
 			log.Fatalf("Failed to get cluster IDs: %v", err)
 		}
 		var clusterlist []ovhwrapper.K8SCluster
 		for _, clid := range clusterids {
-			cluster := ovhwrapper.GetK8SCluster(reader, slid, clid)
+			cluster, err := ovhwrapper.GetK8SCluster(ctx, reader, slid, clid)
+			if err != nil {
+				log.Printf("Failed to get cluster %s: %s", clid, explainError(err))
+			}
 			if cluster != nil {
 				clusterlist = append(clusterlist, *cluster)
 			}
@@ -191,9 +208,9 @@ func DownloadKubeconfig(reader, writer *ovh.Client, all bool, serviceid, cluster
 		for _, sl := range sls {
 			fmt.Println("Processing Serviceline: ", sl.SLDetails.Description)
 			for _, cl := range sl.Cluster {
-				kc, err := ovhwrapper.GetKubeconfig(writer, sl.ID, cl.ID)
+				kc, err := ovhwrapper.GetKubeconfig(ctx, writer, sl.ID, cl.ID)
 				if err != nil {
-					log.Printf("Failed to get kubeconfig: %v", err)
+					log.Printf("Failed to get kubeconfig: %s", explainError(err))
 					continue
 				}
 				switch output {
@@ -257,9 +274,9 @@ func DownloadKubeconfig(reader, writer *ovh.Client, all bool, serviceid, cluster
 			if MatchItem(sl, serviceid) {
 				for _, cl := range sl.Cluster {
 					if MatchItem(cl, clusterid) {
-						kc, err := ovhwrapper.GetKubeconfig(writer, sl.ID, cl.ID)
+						kc, err := ovhwrapper.GetKubeconfig(ctx, writer, sl.ID, cl.ID)
 						if err != nil {
-							log.Printf("Failed to get kubeconfig: %v", err)
+							log.Printf("Failed to get kubeconfig: %s", explainError(err))
 							return
 						}
 						switch output {
@@ -329,19 +346,14 @@ func DownloadKubeconfig(reader, writer *ovh.Client, all bool, serviceid, cluster
 
 // status shows the current status of servicelines and their clusters (when -a is set),
 // or a specific cluster (when -s and -c is set)
-func status(client *ovh.Client, all bool, serviceline, cluster string) {
+func status(ctx context.Context, client ovhwrapper.API, all bool, serviceline, cluster string) {
 	var sls []ovhwrapper.ServiceLine
 	var cl *ovhwrapper.K8SCluster
 	var realslid, realclid string
-	var err error
 
 	if all {
-		services := ovhwrapper.GetServicelines(client)
-		for _, service := range services {
-			sl := CollectServiceline(client, service)
-			sls = append(sls, *sl)
-		}
-		flavors, err := ovhwrapper.GetK8SFlavors(client, sls[0].ID, sls[0].Cluster[0].ID)
+		sls = gatherServicelines(ctx, client)
+		flavors, err := ovhwrapper.GetK8SFlavors(ctx, client, sls[0].ID, sls[0].Cluster[0].ID)
 		if err != nil {
 			log.Printf("Error getting available flavors: %q\n", err)
 		}
@@ -356,23 +368,26 @@ func status(client *ovh.Client, all bool, serviceline, cluster string) {
 				}
 				fmt.Println()
 			}
-			fmt.Println("-------------------\n")
+			fmt.Print("-------------------\n\n")
 		}
 		return
 	}
 
 	if serviceline != "" { // list serviceline and it's clusters
-		services := ovhwrapper.GetServicelines(client)
+		services, err := ovhwrapper.GetServicelines(ctx, client)
+		if err != nil {
+			log.Fatalf("Failed to get servicelines: %s", explainError(err))
+		}
 		for _, service := range services {
-			sl := GetServiceline(client, service)
+			sl := GetServiceline(ctx, client, service)
 			if MatchItem(*sl, serviceline) {
 				realslid = sl.ID
-				sl.SLDetails, err = ovhwrapper.GetServicelineDetails(client, sl.ID)
+				sl.SLDetails, err = ovhwrapper.GetServicelineDetails(ctx, client, sl.ID)
 				if err != nil {
 					break
 				}
 
-				clusterids, err := ovhwrapper.GetK8SClusterIDs(client, sl.ID)
+				clusterids, err := ovhwrapper.GetK8SClusterIDs(ctx, client, sl.ID)
 				if err != nil {
 					log.Fatalf("Failed to get cluster IDs: %v", err)
 				}
@@ -381,17 +396,21 @@ func status(client *ovh.Client, all bool, serviceline, cluster string) {
 
 				for _, clid := range clusterids {
 					if cluster != "" { // cluster id is given on the command line
-						cl = ovhwrapper.GetK8SCluster(client, sl.ID, clid)
+						cl, err = ovhwrapper.GetK8SCluster(ctx, client, sl.ID, clid)
+						if err != nil {
+							log.Printf("Failed to get cluster %s: %s", clid, explainError(err))
+							continue
+						}
 						if MatchItem(*cl, cluster) {
 							realclid = cl.ID
-							cl, err = ovhwrapper.GetK8SClusterDetails(client, cl, realslid, realclid)
+							cl, err = ovhwrapper.GetK8SClusterDetails(ctx, client, cl, realslid, realclid)
 							if err == nil && cl != nil {
 								clusterlist = append(clusterlist, *cl)
 								break
 							}
 						}
 					} else { // all clusters
-						cl := CollectCluster(client, sl.ID, clid)
+						cl := gatherCluster(ctx, client, sl.ID, clid)
 						if cl != nil {
 							clusterlist = append(clusterlist, *cl)
 						}
@@ -414,7 +433,7 @@ func status(client *ovh.Client, all bool, serviceline, cluster string) {
 			return
 		}
 
-		flavors, err := ovhwrapper.GetK8SFlavors(client, sls[0].ID, sls[0].Cluster[0].ID)
+		flavors, err := ovhwrapper.GetK8SFlavors(ctx, client, sls[0].ID, sls[0].Cluster[0].ID)
 		if err != nil {
 			log.Printf("Error getting available flavors: %q\n", err)
 			return
@@ -440,25 +459,27 @@ func status(client *ovh.Client, all bool, serviceline, cluster string) {
 }
 
 // statusString returns the current status of a specific cluster
-func statusString(client *ovh.Client, serviceline, cluster string) string {
+func statusString(ctx context.Context, client ovhwrapper.API, serviceline, cluster string) string {
 	var sls []ovhwrapper.ServiceLine
 	var cl *ovhwrapper.K8SCluster
 	var s string
 	var realslid, realclid string
-	var err error
 
 	if serviceline != "" { // list serviceline and it's clusters
-		services := ovhwrapper.GetServicelines(client)
+		services, err := ovhwrapper.GetServicelines(ctx, client)
+		if err != nil {
+			log.Fatalf("Failed to get servicelines: %s", explainError(err))
+		}
 		for _, service := range services {
-			sl := GetServiceline(client, service)
+			sl := GetServiceline(ctx, client, service)
 			if MatchItem(*sl, serviceline) {
 				realslid = sl.ID
-				sl.SLDetails, err = ovhwrapper.GetServicelineDetails(client, sl.ID)
+				sl.SLDetails, err = ovhwrapper.GetServicelineDetails(ctx, client, sl.ID)
 				if err != nil {
 					break
 				}
 
-				clusterids, err := ovhwrapper.GetK8SClusterIDs(client, sl.ID)
+				clusterids, err := ovhwrapper.GetK8SClusterIDs(ctx, client, sl.ID)
 				if err != nil {
 					log.Fatalf("Failed to get cluster IDs: %v", err)
 				}
@@ -467,17 +488,21 @@ func statusString(client *ovh.Client, serviceline, cluster string) string {
 
 				for _, clid := range clusterids {
 					if cluster != "" { // cluster id is given on the command line
-						cl = ovhwrapper.GetK8SCluster(client, sl.ID, clid)
+						cl, err = ovhwrapper.GetK8SCluster(ctx, client, sl.ID, clid)
+						if err != nil {
+							log.Printf("Failed to get cluster %s: %s", clid, explainError(err))
+							continue
+						}
 						if MatchItem(*cl, cluster) {
 							realclid = cl.ID
-							cl, err = ovhwrapper.GetK8SClusterDetails(client, cl, realslid, realclid)
+							cl, err = ovhwrapper.GetK8SClusterDetails(ctx, client, cl, realslid, realclid)
 							if err == nil && cl != nil {
 								clusterlist = append(clusterlist, *cl)
 								break
 							}
 						}
 					} else { // all clusters
-						cl := CollectCluster(client, sl.ID, clid)
+						cl := gatherCluster(ctx, client, sl.ID, clid)
 						if cl != nil {
 							clusterlist = append(clusterlist, *cl)
 						}
@@ -500,7 +525,7 @@ func statusString(client *ovh.Client, serviceline, cluster string) string {
 			return ""
 		}
 
-		flavors, err := ovhwrapper.GetK8SFlavors(client, sls[0].ID, sls[0].Cluster[0].ID)
+		flavors, err := ovhwrapper.GetK8SFlavors(ctx, client, sls[0].ID, sls[0].Cluster[0].ID)
 		if err != nil {
 			log.Printf("Error getting available flavors: %q\n", err)
 			return ""
@@ -530,15 +555,18 @@ func statusString(client *ovh.Client, serviceline, cluster string) string {
 // describe shows the details of servicelines and their clusters (when only -a is set),
 // a serviceline (when -c is not set), a serviceline and all it's clusters (when -a is set as well)
 // or a specific cluster (when -s and -c is set, including serviceline if -a is set as well)
-func describe(client *ovh.Client, all bool, serviceid, clusterid, output string) {
+func describe(ctx context.Context, client ovhwrapper.API, all bool, serviceid, clusterid, output string) {
 	var err error
 	var sls []ovhwrapper.ServiceLine
 
 	// Get flat Serviceline info
-	slids := ovhwrapper.GetServicelines(client) // list of sl ids
+	slids, err := ovhwrapper.GetServicelines(ctx, client) // list of sl ids
+	if err != nil {
+		log.Fatalf("Failed to get servicelines: %s", explainError(err))
+	}
 	for _, slid := range slids {
 		sl := ovhwrapper.ServiceLine{ID: slid}
-		sl.SLDetails, err = ovhwrapper.GetServicelineDetails(client, slid)
+		sl.SLDetails, err = ovhwrapper.GetServicelineDetails(ctx, client, slid)
 		if err != nil {
 			log.Fatalf("Failed to get service lines: %v", err)
 		}
@@ -547,13 +575,13 @@ func describe(client *ovh.Client, all bool, serviceid, clusterid, output string)
 
 	if serviceid == "" && clusterid == "" { // all servicelines and their clusters
 		for idx := range sls {
-			clusterids, err := ovhwrapper.GetK8SClusterIDs(client, sls[idx].ID)
+			clusterids, err := ovhwrapper.GetK8SClusterIDs(ctx, client, sls[idx].ID)
 			if err != nil {
 				log.Fatalf("Failed to get cluster IDs: %v", err)
 			}
 			var clusterlist []ovhwrapper.K8SCluster
 			for _, clusterid := range clusterids {
-				cluster := CollectCluster(client, sls[idx].ID, clusterid)
+				cluster := gatherCluster(ctx, client, sls[idx].ID, clusterid)
 				//fmt.Printf("sl %-2d: %s\t%v\n", idx, clusterid, cluster)
 				if cluster != nil {
 					clusterlist = append(clusterlist, *cluster)
@@ -571,7 +599,7 @@ func describe(client *ovh.Client, all bool, serviceid, clusterid, output string)
 		case "text":
 			fallthrough
 		default:
-			flavors, err := ovhwrapper.GetK8SFlavors(client, sls[0].ID, sls[0].Cluster[0].ID)
+			flavors, err := ovhwrapper.GetK8SFlavors(ctx, client, sls[0].ID, sls[0].Cluster[0].ID)
 			if err != nil {
 				log.Printf("Error getting available flavors: %q\n", err)
 			}
@@ -594,7 +622,7 @@ func describe(client *ovh.Client, all bool, serviceid, clusterid, output string)
 					}
 					fmt.Println("\n-----")
 				}
-				fmt.Println("\n\n")
+				fmt.Print("\n\n\n")
 			}
 		}
 	} else if serviceid != "" && clusterid == "" { // show all clusters for a specific serviceline
@@ -613,14 +641,14 @@ func describe(client *ovh.Client, all bool, serviceid, clusterid, output string)
 		}
 
 		if all {
-			clusterids, err := ovhwrapper.GetK8SClusterIDs(client, sl.ID)
+			clusterids, err := ovhwrapper.GetK8SClusterIDs(ctx, client, sl.ID)
 			if err != nil {
 				log.Fatalf("Failed to get cluster IDs: %v", err)
 			}
 
 			var clusterlist []ovhwrapper.K8SCluster
 			for _, clid := range clusterids {
-				cluster := CollectCluster(client, sl.ID, clid)
+				cluster := gatherCluster(ctx, client, sl.ID, clid)
 				if cluster != nil {
 					clusterlist = append(clusterlist, *cluster)
 				}
@@ -631,7 +659,7 @@ func describe(client *ovh.Client, all bool, serviceid, clusterid, output string)
 		// output
 		var flavors ovhwrapper.K8SFlavors
 		if sl.Cluster != nil {
-			flavors, err = ovhwrapper.GetK8SFlavors(client, sl.ID, sl.Cluster[0].ID)
+			flavors, err = ovhwrapper.GetK8SFlavors(ctx, client, sl.ID, sl.Cluster[0].ID)
 			if err != nil {
 				log.Printf("Error getting available flavors: %q\n", err)
 			}
@@ -678,17 +706,20 @@ func describe(client *ovh.Client, all bool, serviceid, clusterid, output string)
 			return
 		}
 
-		clusterids, err := ovhwrapper.GetK8SClusterIDs(client, sl.ID)
+		clusterids, err := ovhwrapper.GetK8SClusterIDs(ctx, client, sl.ID)
 		if err != nil {
 			log.Fatalf("Failed to get cluster IDs: %v", err)
 		}
 
 		var cluster *ovhwrapper.K8SCluster
 		for _, clid := range clusterids {
-			cl := ovhwrapper.GetK8SCluster(client, sl.ID, clid)
+			cl, err := ovhwrapper.GetK8SCluster(ctx, client, sl.ID, clid)
+			if err != nil {
+				log.Printf("Failed to get cluster %s: %s", clid, explainError(err))
+			}
 			if cl != nil {
 				if MatchItem(*cl, clusterid) {
-					cluster = CollectCluster(client, sl.ID, cl.ID)
+					cluster = gatherCluster(ctx, client, sl.ID, cl.ID)
 					break
 				}
 			}
@@ -716,7 +747,7 @@ func describe(client *ovh.Client, all bool, serviceid, clusterid, output string)
 				return
 			}
 
-			flavors, err := ovhwrapper.GetK8SFlavors(client, sl.ID, cluster.ID)
+			flavors, err := ovhwrapper.GetK8SFlavors(ctx, client, sl.ID, cluster.ID)
 			if err != nil {
 				log.Printf("Error getting available flavors: %q\n", err)
 			}
@@ -749,7 +780,7 @@ func describe(client *ovh.Client, all bool, serviceid, clusterid, output string)
 	}
 }
 
-func readInventory(reader *ovh.Client, config ovhwrapper.Configuration, inventory string) Inventory {
+func readInventory(ctx context.Context, reader ovhwrapper.API, config ovhwrapper.Configuration, inventory string) Inventory {
 	if inventory == "" {
 		// check if local inventory file "./clustergroups.yaml" exists
 		if fileExists("./clustergroups.yaml") {
@@ -780,12 +811,12 @@ func readInventory(reader *ovh.Client, config ovhwrapper.Configuration, inventor
 	return inv
 }
 
-func UpdateClusterGroup(reader, writer *ovh.Client, config ovhwrapper.Configuration, clustergroup, inventory string, latest, force bool) {
+func UpdateClusterGroup(ctx context.Context, reader, writer ovhwrapper.API, config ovhwrapper.Configuration, clustergroup, inventory string, latest, force bool) {
 	var wg sync.WaitGroup
 	var status chan string
 
 	// read inventory file
-	inv := readInventory(reader, config, inventory)
+	inv := readInventory(ctx, reader, config, inventory)
 	fmt.Printf("%s\n", inv)
 
 	// count number of clusters to update
@@ -816,23 +847,34 @@ func UpdateClusterGroup(reader, writer *ovh.Client, config ovhwrapper.Configurat
 					slTeamsHook := project.TeamsWebhook
 
 					// determine project and cluster IDs
-					slids := ovhwrapper.GetServicelines(reader)
+					slids, err := ovhwrapper.GetServicelines(ctx, reader)
+					if err != nil {
+						log.Fatalf("Failed to get servicelines: %s", explainError(err))
+					}
 					for _, slid := range slids {
-						details := ovhwrapper.GetOVHServiceline(reader, slid)
+						details, err := ovhwrapper.GetOVHServiceline(ctx, reader, slid)
+						if err != nil {
+							log.Printf("Failed to get serviceline %s: %s", slid, explainError(err))
+							continue
+						}
 						sl := ovhwrapper.ServiceLine{
 							ID:        slid,
 							SLDetails: *details,
 						}
 						if MatchItem(sl, project.Name) {
 							realslid = sl.ID
-							clids, err := ovhwrapper.GetK8SClusterIDs(reader, slid)
+							clids, err := ovhwrapper.GetK8SClusterIDs(ctx, reader, slid)
 							if err != nil {
 								fmt.Printf("Failed to get cluster IDs: %v\n", err)
 								continue
 							}
 
 							for _, clid := range clids {
-								cl := ovhwrapper.GetK8SCluster(reader, slid, clid)
+								cl, err := ovhwrapper.GetK8SCluster(ctx, reader, slid, clid)
+								if err != nil {
+									log.Printf("Failed to get cluster %s: %s", clid, explainError(err))
+									continue
+								}
 								if MatchItem(*cl, clustername) {
 									realclid = cl.ID
 								}
@@ -844,11 +886,11 @@ func UpdateClusterGroup(reader, writer *ovh.Client, config ovhwrapper.Configurat
 					go func(wg *sync.WaitGroup, sl, slid, cl, clid string) {
 						defer wg.Done()
 						fmt.Printf("Updating cluster %25s (%s) in serviceline %25s (%s)\n", cl, clid, sl, slid)
-						//err := ovhwrapper.UpdateK8SCluster(writer, slid, clid, latest, force)
+						//err := ovhwrapper.UpdateK8SCluster(ctx, writer, slid, clid, latest, force)
 						//if err != nil {
 						//	log.Fatalf("Failed to initiate cluster update: %v", err)
 						//}
-						res := CheckCronClusterUpdate(reader, writer, config, sl, slid, cl, clid, slEmail, slTeamsHook)
+						res := CheckCronClusterUpdate(ctx, reader, writer, config, sl, slid, cl, clid, slEmail, slTeamsHook)
 						status <- res
 					}(&wg, project.Name, realslid, clustername, realclid)
 
@@ -866,7 +908,7 @@ func UpdateClusterGroup(reader, writer *ovh.Client, config ovhwrapper.Configurat
 	}
 }
 
-func CheckCronClusterUpdate(reader, writer *ovh.Client, config ovhwrapper.Configuration, sl, realslid, cl, realclid, email, teamshook string) string {
+func CheckCronClusterUpdate(ctx context.Context, reader, writer ovhwrapper.API, config ovhwrapper.Configuration, sl, realslid, cl, realclid, email, teamshook string) string {
 	var curStatus, prevStatus string
 
 	logfile, err := os.OpenFile(path.Join("/var/log/k8s/updates", sl+"-"+cl+".log"), os.O_WRONLY|os.O_CREATE, 0660)
@@ -878,18 +920,21 @@ func CheckCronClusterUpdate(reader, writer *ovh.Client, config ovhwrapper.Config
 	fmt.Fprintf(logfile, "Update for %s started at %s...\n\n", cl, time.Now().Format(time.RFC1123Z))
 
 	// mock sleep to simulate a little bit of update time
-	time.Sleep(time.Second * time.Duration(rand.Intn(30)))
+	sleepContext(ctx, time.Second*time.Duration(rand.Intn(30)))
 
-	for {
+	for ctx.Err() == nil {
 		client, err := ovhwrapper.CreateReader(config)
 		if err != nil {
 			log.Fatalf("Error creating OVH API Reader: %q\n", err)
 		}
 
-		cl := ovhwrapper.GetK8SCluster(client, realslid, realclid)
+		cl, err := ovhwrapper.GetK8SCluster(ctx, client, realslid, realclid)
+		if err != nil {
+			log.Printf("Failed to get cluster %s: %s", realclid, explainError(err))
+		}
 		if cl != nil {
 			//fmt.Println("\033[2J")  // clear screen
-			curStatus = statusString(client, realslid, realclid)
+			curStatus = statusString(ctx, client, realslid, realclid)
 			// show status if status has changed since last check
 			if curStatus != prevStatus {
 				fmt.Fprintln(logfile, curStatus)
@@ -900,8 +945,11 @@ func CheckCronClusterUpdate(reader, writer *ovh.Client, config ovhwrapper.Config
 			if cl.Status == "READY" {
 				break
 			}
-			time.Sleep(60 * time.Second)
 		}
+		sleepContext(ctx, 60*time.Second)
+	}
+	if ctx.Err() != nil {
+		fmt.Fprintf(logfile, "Monitoring of %s aborted: %v\n", cl, ctx.Err())
 	}
 	fmt.Fprintf(logfile, "Update for %s finished at %s...\n", cl, time.Now().Format(time.RFC1123Z))
 	err = logfile.Close()
@@ -936,27 +984,38 @@ func CheckCronClusterUpdate(reader, writer *ovh.Client, config ovhwrapper.Config
 	return curStatus
 }
 
-func UpdateCluster(reader, writer *ovh.Client, config ovhwrapper.Configuration, serviceid, clusterid string, background, latest, force bool) {
+func UpdateCluster(ctx context.Context, reader, writer ovhwrapper.API, config ovhwrapper.Configuration, serviceid, clusterid string, background, latest, force bool) {
 	var realslid, realclid string
 	var curStatus, prevStatus string
 
-	slids := ovhwrapper.GetServicelines(reader)
+	slids, err := ovhwrapper.GetServicelines(ctx, reader)
+	if err != nil {
+		log.Fatalf("Failed to get servicelines: %s", explainError(err))
+	}
 	for _, slid := range slids {
-		details := ovhwrapper.GetOVHServiceline(reader, slid)
+		details, err := ovhwrapper.GetOVHServiceline(ctx, reader, slid)
+		if err != nil {
+			log.Printf("Failed to get serviceline %s: %s", slid, explainError(err))
+			continue
+		}
 		sl := ovhwrapper.ServiceLine{
 			ID:        slid,
 			SLDetails: *details,
 		}
 		if MatchItem(sl, serviceid) {
 			realslid = sl.ID
-			clids, err := ovhwrapper.GetK8SClusterIDs(reader, slid)
+			clids, err := ovhwrapper.GetK8SClusterIDs(ctx, reader, slid)
 			if err != nil {
 				fmt.Printf("Failed to get cluster IDs: %v\n", err)
 				continue
 			}
 
 			for _, clid := range clids {
-				cl := ovhwrapper.GetK8SCluster(reader, slid, clid)
+				cl, err := ovhwrapper.GetK8SCluster(ctx, reader, slid, clid)
+				if err != nil {
+					log.Printf("Failed to get cluster %s: %s", clid, explainError(err))
+					continue
+				}
 				if MatchItem(*cl, clusterid) {
 					realclid = cl.ID
 				}
@@ -964,13 +1023,17 @@ func UpdateCluster(reader, writer *ovh.Client, config ovhwrapper.Configuration,
 		}
 	}
 
-	err := ovhwrapper.UpdateK8SCluster(writer, realslid, realclid, latest, force)
+	err = ovhwrapper.UpdateK8SCluster(ctx, writer, realslid, realclid, latest, force)
 	if err != nil {
-		log.Fatalf("Failed to initiate cluster update: %v", err)
+		log.Fatalf("Failed to initiate cluster update: %s", explainError(err))
 	}
 
 	if !background {
-		time.Sleep(10 * time.Second) // give the update 10 seconds to get triggered
+		// give the update 10 seconds to get triggered
+		if !sleepContext(ctx, 10*time.Second) {
+			log.Printf("Monitoring of cluster %s aborted: %v\n", realclid, ctx.Err())
+			return
+		}
 
 		for {
 			client, err := ovhwrapper.CreateReader(config)
@@ -978,10 +1041,13 @@ func UpdateCluster(reader, writer *ovh.Client, config ovhwrapper.Configuration,
 				log.Fatalf("Error creating OVH API Reader: %q\n", err)
 			}
 
-			cl := ovhwrapper.GetK8SCluster(client, realslid, realclid)
+			cl, err := ovhwrapper.GetK8SCluster(ctx, client, realslid, realclid)
+			if err != nil {
+				log.Printf("Failed to get cluster %s: %s", realclid, explainError(err))
+			}
 			if cl != nil {
 				//fmt.Println("\033[2J")  // clear screen
-				curStatus = statusString(client, realslid, realclid)
+				curStatus = statusString(ctx, client, realslid, realclid)
 				// show status if status has changed since last check
 				if curStatus != prevStatus {
 					fmt.Println(curStatus)
@@ -992,36 +1058,50 @@ func UpdateCluster(reader, writer *ovh.Client, config ovhwrapper.Configuration,
 				if cl.Status == "READY" {
 					break
 				}
-				time.Sleep(60 * time.Second)
+			}
+			if !sleepContext(ctx, 60*time.Second) {
+				log.Printf("Monitoring of cluster %s aborted: %v\n", realclid, ctx.Err())
+				return
 			}
 		}
 	}
 }
 
-func ResetKubeconfig(reader, writer *ovh.Client, config ovhwrapper.Configuration, serviceid, clusterid string, background bool) {
+func ResetKubeconfig(ctx context.Context, reader, writer ovhwrapper.API, config ovhwrapper.Configuration, serviceid, clusterid string, background bool) {
 	var realslid, realclid string
 
 	//fmt.Printf("Serviceline: %s\n"+
 	//	"Cluster ID: %s\n"+
 	//	"Background: %v\n", serviceid, clusterid, background)
 
-	slids := ovhwrapper.GetServicelines(reader)
+	slids, err := ovhwrapper.GetServicelines(ctx, reader)
+	if err != nil {
+		log.Fatalf("Failed to get servicelines: %s", explainError(err))
+	}
 	for _, slid := range slids {
-		details := ovhwrapper.GetOVHServiceline(reader, slid)
+		details, err := ovhwrapper.GetOVHServiceline(ctx, reader, slid)
+		if err != nil {
+			log.Printf("Failed to get serviceline %s: %s", slid, explainError(err))
+			continue
+		}
 		sl := ovhwrapper.ServiceLine{
 			ID:        slid,
 			SLDetails: *details,
 		}
 		if MatchItem(sl, serviceid) {
 			realslid = sl.ID
-			clids, err := ovhwrapper.GetK8SClusterIDs(reader, slid)
+			clids, err := ovhwrapper.GetK8SClusterIDs(ctx, reader, slid)
 			if err != nil {
 				fmt.Printf("Failed to get cluster IDs: %v\n", err)
 				continue
 			}
 
 			for _, clid := range clids {
-				cl := ovhwrapper.GetK8SCluster(reader, slid, clid)
+				cl, err := ovhwrapper.GetK8SCluster(ctx, reader, slid, clid)
+				if err != nil {
+					log.Printf("Failed to get cluster %s: %s", clid, explainError(err))
+					continue
+				}
 				if MatchItem(*cl, clusterid) {
 					realclid = cl.ID
 				}
@@ -1037,14 +1117,18 @@ func ResetKubeconfig(reader, writer *ovh.Client, config ovhwrapper.Configuration
 	}
 
 	fmt.Printf("Resetting kubeconfig for serviceline %s (%s) cluster %s(%s)\n", serviceid, realslid, clusterid, realclid)
-	kc, err := ovhwrapper.ResetKubeconfig(writer, realslid, realclid)
+	kc, err := ovhwrapper.ResetKubeconfig(ctx, writer, realslid, realclid)
 	if err != nil {
-		log.Fatalf("Failed to initiate kubeconfig reset: %v", err)
+		log.Fatalf("Failed to initiate kubeconfig reset: %s", explainError(err))
 	}
 	fmt.Println(kc)
 
 	if !background {
-		time.Sleep(10 * time.Second) // give the reset 10 seconds to get triggered
+		// give the reset 10 seconds to get triggered
+		if !sleepContext(ctx, 10*time.Second) {
+			log.Printf("Monitoring of cluster %s aborted: %v\n", realclid, ctx.Err())
+			return
+		}
 
 		for {
 			client, err := ovhwrapper.CreateReader(config)
@@ -1052,29 +1136,35 @@ func ResetKubeconfig(reader, writer *ovh.Client, config ovhwrapper.Configuration
 				log.Fatalf("Error creating OVH API Reader: %q\n", err)
 			}
 
-			cl := ovhwrapper.GetK8SCluster(client, realslid, realclid)
+			cl, err := ovhwrapper.GetK8SCluster(ctx, client, realslid, realclid)
+			if err != nil {
+				log.Printf("Failed to get cluster %s: %s", realclid, explainError(err))
+			}
 			if cl != nil {
 				//fmt.Println("\033[2J")  // clear screen
-				status(client, false, realslid, realclid)
+				status(ctx, client, false, realslid, realclid)
 
 				if cl.Status == "READY" {
 					break
 				}
-				time.Sleep(60 * time.Second)
+			}
+			if !sleepContext(ctx, 60*time.Second) {
+				log.Printf("Monitoring of cluster %s aborted: %v\n", realclid, ctx.Err())
+				return
 			}
 		}
 	}
 }
 
-func Logout(writer *ovh.Client, config ovhwrapper.Configuration) {
+func Logout(ctx context.Context, writer ovhwrapper.API, config ovhwrapper.Configuration) {
 	var result []byte
-	if err := writer.Post("/auth/logout", nil, &result); err != nil {
-		fmt.Printf("Error revoking consumer key: %q\n", err)
+	if err := writer.PostWithContext(ctx, "/auth/logout", nil, &result); err != nil {
+		fmt.Printf("Error revoking consumer key: %s\n", explainError(err))
 	}
 	fmt.Println(string(result))
 	config.Writer.ConsumerKey = ""
 
-	err := ovhwrapper.SaveYaml(config, config.GetPath())
+	err := config.Save()
 	if err != nil {
 		log.Printf("Error saving configuration: %v", err)
 	}
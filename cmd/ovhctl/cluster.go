@@ -0,0 +1,656 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/snafuprinzip/ovhwrapper"
+	"gopkg.in/yaml.v3"
+)
+
+// CreateCluster creates a cluster from a spec file. Unless background is set, it waits until the cluster is
+// READY and creates the remaining nodepools of the spec. The cluster is registered in the clustergroup given
+// by the flag or the spec.
+func CreateCluster(ctx context.Context, reader, writer ovhwrapper.API, config ovhwrapper.Configuration, specfile, serviceid, clustergroup, inventory string, background bool, wait waitOptions) {
+	var spec ovhwrapper.K8SClusterSpec
+	if err := ovhwrapper.LoadYaml(&spec, specfile); err != nil {
+		log.Fatalf("Failed to read cluster spec %s: %v", specfile, err)
+	}
+	if err := spec.Validate(); err != nil {
+		log.Fatalf("Invalid cluster spec %s: %v", specfile, err)
+	}
+	if serviceid == "" {
+		serviceid = spec.Serviceline
+	}
+	if clustergroup == "" {
+		clustergroup = spec.Clustergroup
+	}
+	if serviceid == "" {
+		log.Fatalf("No serviceline given, set it with -s or in the spec")
+	}
+	if background && len(spec.Nodepools) > 1 {
+		log.Fatalf("The nodepools after the first one can only be created once the cluster is READY, " +
+			"run without --background")
+	}
+
+	sl, err := ovhwrapper.NewResolver(GlobalInventory).Serviceline(serviceid)
+	if err != nil {
+		log.Fatalf("%s\n", explainError(err))
+	}
+	for _, cl := range sl.Cluster {
+		if cl.Name == spec.Name {
+			log.Fatalf("Cluster %s already exists in serviceline %s (%s)", spec.Name, sl.SLDetails.Description, cl.ID)
+		}
+	}
+	if len(Flavors) > 0 {
+		for _, np := range spec.Nodepools {
+			if _, ok := Flavors[np.Flavor]; !ok {
+				log.Fatalf("Unknown flavor %s of nodepool %s", np.Flavor, np.Name)
+			}
+		}
+	}
+	// check the inventory before creating anything, registering must not fail afterwards
+	if clustergroup != "" {
+		inventory = inventoryPath(config, inventory)
+	}
+
+	fmt.Printf("Creating cluster %s in serviceline %s (%s), region %s\n", spec.Name, sl.SLDetails.Description,
+		sl.ID, spec.Region)
+	cl, err := ovhwrapper.CreateK8SCluster(ctx, writer, sl.ID, spec)
+	if err != nil {
+		log.Fatalf("Failed to create cluster: %s", explainError(err))
+	}
+	fmt.Printf("Cluster %s created with id %s\n", cl.Name, cl.ID)
+
+	if clustergroup != "" {
+		err := registerCluster(inventory, clustergroup, ovhwrapper.ShortenName(sl.SLDetails.Description), cl.Name)
+		if err != nil {
+			log.Printf("Failed to register cluster in clustergroup %s: %v", clustergroup, err)
+		} else {
+			fmt.Printf("Cluster %s registered in clustergroup %s of %s\n", cl.Name, clustergroup, inventory)
+		}
+	}
+
+	if !background {
+		if !waitForCluster(ctx, reader, sl.ID, cl.ID, wait) {
+			refreshInventory(ctx, reader, config)
+			os.Exit(1)
+		}
+		for _, np := range spec.Nodepools[min(1, len(spec.Nodepools)):] {
+			fmt.Printf("Creating nodepool %s\n", np.Name)
+			pool, err := ovhwrapper.CreateK8SNodepool(ctx, writer, sl.ID, cl.ID, np)
+			if err != nil {
+				log.Fatalf("Failed to create nodepool %s: %s", np.Name, explainError(err))
+			}
+			if !waitForNodepool(ctx, reader, sl.ID, cl.ID, pool.Id, wait) {
+				refreshInventory(ctx, reader, config)
+				os.Exit(1)
+			}
+		}
+	}
+
+	// make the new cluster known to the following commands
+	refreshInventory(ctx, reader, config)
+}
+
+// DeleteCluster deletes a cluster after showing everything that gets destroyed with it: nodepools, nodes,
+// volumes and the kubeconfig contexts pointing to it. The user has to confirm by retyping the cluster name.
+// Clusters of a protected clustergroup of the inventory are never deleted.
+func DeleteCluster(ctx context.Context, reader, writer ovhwrapper.API, config ovhwrapper.Configuration, serviceid, clusterid, inventory string, noProtectionCheck, background bool, wait waitOptions) {
+	resolver := ovhwrapper.NewResolver(GlobalInventory)
+	sl, cl, err := resolver.Cluster(serviceid, clusterid)
+	if err != nil {
+		log.Fatalf("%s\n", explainError(err))
+	}
+
+	groups := clustergroupsOf(ctx, reader, resolver, config, inventory, cl.ID, noProtectionCheck)
+	refuseProtected(cl.Name, groups, "deleted")
+
+	// show the current state of the cluster instead of the cached one
+	cluster, err := ovhwrapper.GetK8SCluster(ctx, reader, sl.ID, cl.ID)
+	if err != nil {
+		log.Fatalf("Failed to get cluster %s: %s", cl.Name, explainError(err))
+	}
+	if _, err := ovhwrapper.GetK8SClusterDetails(ctx, reader, cluster, sl.ID, cl.ID); err != nil {
+		log.Fatalf("Failed to get nodepools of cluster %s: %s", cl.Name, explainError(err))
+	}
+	volumes, err := ovhwrapper.GetOVHVolumes(ctx, reader, sl.ID, cl.ID)
+	if err != nil {
+		log.Printf("Warning: failed to get volumes of cluster %s: %s", cl.Name, explainError(err))
+	}
+
+	fmt.Printf("Cluster %s (%s) in serviceline %s (%s), region %s, version %s [%s]\n", cluster.Name, cluster.ID,
+		sl.SLDetails.Description, sl.ID, cluster.Region, cluster.Version, cluster.Status)
+	for _, cg := range groups {
+		fmt.Printf("  Clustergroup: %s\n", cg.Name)
+	}
+	fmt.Printf("  Nodepools: %d with %d nodes\n", len(cluster.Nodepools), len(cluster.Nodes))
+	for _, np := range cluster.Nodepools {
+		fmt.Printf("    %-30s %-10s %3d nodes\n", np.Name, np.Flavor, np.CurrentNodes)
+	}
+	fmt.Printf("  Volumes: %d\n", len(volumes))
+	for _, volume := range volumes {
+		attached := "unattached"
+		if len(volume.AttachedTo) > 0 {
+			attached = "attached to " + strings.Join(volume.AttachedTo, ", ")
+		}
+		fmt.Printf("    %-50s %5d GB  %-10s %s\n", volume.Name, volume.Size, volume.Status, attached)
+	}
+	contexts := kubeconfigContexts(*cluster)
+	fmt.Printf("  Kubeconfig contexts: %d\n", len(contexts))
+	for _, kc := range contexts {
+		fmt.Printf("    %s\n", kc)
+	}
+	fmt.Println()
+
+	if !confirm(fmt.Sprintf("Type the cluster name %s to confirm the deletion: ", cluster.Name), cluster.Name) {
+		log.Fatalf("Cluster name does not match, %s has not been deleted", cluster.Name)
+	}
+	if err := ovhwrapper.DeleteK8SCluster(ctx, writer, sl.ID, cluster.ID); err != nil {
+		log.Fatalf("Failed to delete cluster %s: %s", cluster.Name, explainError(err))
+	}
+	fmt.Printf("Deleting cluster %s\n", cluster.Name)
+
+	if !background {
+		deleted := waitUntil(ctx, "cluster "+cluster.Name, wait, untilGone(func(ctx context.Context) (string, error) {
+			cur, err := ovhwrapper.GetK8SCluster(ctx, reader, sl.ID, cluster.ID)
+			if err != nil {
+				return "", err
+			}
+			return fmt.Sprintf("  Cluster: %s\t[%s]", cur.Name, cur.Status), nil
+		}))
+		if !deleted {
+			refreshInventory(ctx, reader, config)
+			os.Exit(1)
+		}
+		fmt.Printf("Cluster %s deleted\n", cluster.Name)
+	}
+
+	// forget the deleted cluster in the following commands
+	refreshInventory(ctx, reader, config)
+}
+
+// ResetCluster reinstalls the control plane of a cluster with the given or its current version after the user
+// confirmed by retyping the cluster name. All kubernetes resources are lost, the worker nodes are deleted or with
+// reinstallNodes set reinstalled. Clusters of a protected clustergroup of the inventory are never reset.
+func ResetCluster(ctx context.Context, reader, writer ovhwrapper.API, config ovhwrapper.Configuration, serviceid, clusterid, version, inventory string, reinstallNodes, noProtectionCheck, background bool, wait waitOptions) {
+	resolver := ovhwrapper.NewResolver(GlobalInventory)
+	sl, cl, err := resolver.Cluster(serviceid, clusterid)
+	if err != nil {
+		log.Fatalf("%s\n", explainError(err))
+	}
+	refuseProtected(cl.Name, clustergroupsOf(ctx, reader, resolver, config, inventory, cl.ID, noProtectionCheck), "reset")
+
+	cluster, err := ovhwrapper.GetK8SCluster(ctx, reader, sl.ID, cl.ID)
+	if err != nil {
+		log.Fatalf("Failed to get cluster %s: %s", cl.Name, explainError(err))
+	}
+	if _, err := ovhwrapper.GetK8SClusterDetails(ctx, reader, cluster, sl.ID, cl.ID); err != nil {
+		log.Fatalf("Failed to get nodepools of cluster %s: %s", cl.Name, explainError(err))
+	}
+	if version == "" {
+		version = cluster.Version
+	}
+	reset := ovhwrapper.K8SClusterReset{
+		Name:              cluster.Name,
+		Version:           version,
+		UpdatePolicy:      cluster.UpdatePolicy,
+		KubeProxyMode:     cluster.KubeProxyMode,
+		WorkerNodesPolicy: ovhwrapper.WorkerNodesDelete,
+	}
+	nodes := "deleted and recreated"
+	if reinstallNodes {
+		reset.WorkerNodesPolicy = ovhwrapper.WorkerNodesReinstall
+		nodes = "reinstalled"
+	}
+
+	fmt.Printf("Cluster %s (%s) in serviceline %s (%s), version %s [%s]\n", cluster.Name, cluster.ID,
+		sl.SLDetails.Description, sl.ID, cluster.Version, cluster.Status)
+	fmt.Printf("  The control plane is reinstalled with version %s, all kubernetes resources are erased\n", version)
+	fmt.Printf("  Nodepools: %d with %d nodes, the nodes are %s\n", len(cluster.Nodepools), len(cluster.Nodes), nodes)
+	fmt.Println()
+
+	if !confirm(fmt.Sprintf("Type the cluster name %s to confirm the reset: ", cluster.Name), cluster.Name) {
+		log.Fatalf("Cluster name does not match, %s has not been reset", cluster.Name)
+	}
+	if err := ovhwrapper.ResetK8SCluster(ctx, writer, sl.ID, cluster.ID, reset); err != nil {
+		log.Fatalf("Failed to reset cluster %s: %s", cluster.Name, explainError(err))
+	}
+	fmt.Printf("Resetting cluster %s\n", cluster.Name)
+	if !background && !waitForClusterChange(ctx, reader, sl.ID, cluster.ID, wait) {
+		os.Exit(1)
+	}
+	refreshInventory(ctx, reader, config)
+}
+
+// RestartCluster restarts the control plane of a cluster after confirmation.
+func RestartCluster(ctx context.Context, reader, writer ovhwrapper.API, config ovhwrapper.Configuration, serviceid, clusterid string, background bool, wait waitOptions) {
+	sl, cluster := resolveCluster(ctx, reader, serviceid, clusterid)
+
+	fmt.Printf("Cluster %s (%s) in serviceline %s (%s), version %s [%s]\n", cluster.Name, cluster.ID,
+		sl.SLDetails.Description, sl.ID, cluster.Version, cluster.Status)
+	if !confirm(fmt.Sprintf("Restart the control plane of cluster %s? (y/N): ", cluster.Name), "y") {
+		log.Fatalf("Cluster %s has not been restarted", cluster.Name)
+	}
+	if err := ovhwrapper.RestartK8SCluster(ctx, writer, sl.ID, cluster.ID); err != nil {
+		log.Fatalf("Failed to restart cluster %s: %s", cluster.Name, explainError(err))
+	}
+	fmt.Printf("Restarting the control plane of cluster %s\n", cluster.Name)
+	if !background && !waitForClusterChange(ctx, reader, sl.ID, cluster.ID, wait) {
+		os.Exit(1)
+	}
+	refreshInventory(ctx, reader, config)
+}
+
+// refuseProtected exits if one of the clustergroups of the cluster is protected.
+func refuseProtected(cluster string, groups []Clustergroup, action string) {
+	for _, cg := range groups {
+		if cg.Protected {
+			log.Fatalf("Cluster %s is part of the protected clustergroup %s and can not be %s", cluster, cg.Name, action)
+		}
+	}
+}
+
+// resolveCluster returns the serviceline of the selectors and the current state of the cluster or exits.
+func resolveCluster(ctx context.Context, reader ovhwrapper.API, serviceid, clusterid string) (*ovhwrapper.ServiceLine, *ovhwrapper.K8SCluster) {
+	sl, cl, err := ovhwrapper.NewResolver(GlobalInventory).Cluster(serviceid, clusterid)
+	if err != nil {
+		log.Fatalf("%s\n", explainError(err))
+	}
+	cluster, err := ovhwrapper.GetK8SCluster(ctx, reader, sl.ID, cl.ID)
+	if err != nil {
+		log.Fatalf("Failed to get cluster %s: %s", cl.Name, explainError(err))
+	}
+	return sl, cluster
+}
+
+// readClusterSpec reads a cluster spec file without validating it, as only some of its values are used.
+func readClusterSpec(specfile string) ovhwrapper.K8SClusterSpec {
+	var spec ovhwrapper.K8SClusterSpec
+	if err := ovhwrapper.LoadYaml(&spec, specfile); err != nil {
+		log.Fatalf("Failed to read cluster spec %s: %v", specfile, err)
+	}
+	return spec
+}
+
+// SetClusterPolicy sets the update policy of a cluster, given by the flag or the updatePolicy of a spec file.
+func SetClusterPolicy(ctx context.Context, reader, writer ovhwrapper.API, config ovhwrapper.Configuration, serviceid, clusterid, policy, specfile string, yes, background bool, wait waitOptions) {
+	if policy == "" && specfile != "" {
+		policy = readClusterSpec(specfile).UpdatePolicy
+	}
+	if policy == "" {
+		log.Fatalf("No update policy given, set it with --policy or as updatePolicy in the spec")
+	}
+	if err := ovhwrapper.ValidateUpdatePolicy(policy); err != nil {
+		log.Fatalf("%s", err)
+	}
+	sl, cluster := resolveCluster(ctx, reader, serviceid, clusterid)
+
+	var diff []string
+	if cluster.UpdatePolicy != policy {
+		diff = []string{"- updatePolicy " + cluster.UpdatePolicy, "+ updatePolicy " + policy}
+	}
+	if !confirmDiff("update policy of cluster "+cluster.Name, diff, yes) {
+		return
+	}
+	if err := ovhwrapper.UpdateK8SClusterPolicy(ctx, writer, sl.ID, cluster.ID, policy); err != nil {
+		log.Fatalf("Failed to set update policy of cluster %s: %s", cluster.Name, explainError(err))
+	}
+	fmt.Printf("Update policy of cluster %s set to %s\n", cluster.Name, policy)
+	if !background && !waitForClusterChange(ctx, reader, sl.ID, cluster.ID, wait) {
+		os.Exit(1)
+	}
+	refreshInventory(ctx, reader, config)
+}
+
+// CustomizeCluster enables and disables admission plugins of the api server of a cluster. A spec file replaces
+// the current plugins with its admissionPlugins, the flags are applied on top.
+func CustomizeCluster(ctx context.Context, reader, writer ovhwrapper.API, config ovhwrapper.Configuration, serviceid, clusterid, specfile string, enable, disable []string, yes, background bool, wait waitOptions) {
+	sl, cluster := resolveCluster(ctx, reader, serviceid, clusterid)
+	current := cluster.Customization.APIServer.AdmissionPlugins
+
+	plugins := ovhwrapper.AdmissionPluginSpec{Enabled: slices.Clone(current.Enabled), Disabled: slices.Clone(current.Disabled)}
+	if specfile != "" {
+		spec := readClusterSpec(specfile)
+		if spec.AdmissionPlugins == nil {
+			log.Fatalf("No admissionPlugins in cluster spec %s", specfile)
+		}
+		plugins = *spec.AdmissionPlugins
+	}
+	for _, plugin := range enable {
+		plugins.Enable(plugin)
+	}
+	for _, plugin := range disable {
+		plugins.Disable(plugin)
+	}
+	for _, plugin := range plugins.Enabled {
+		if slices.Contains(plugins.Disabled, plugin) {
+			log.Fatalf("Admission plugin %s is both enabled and disabled", plugin)
+		}
+	}
+
+	diff := append(diffList("enabled", current.Enabled, plugins.Enabled), diffList("disabled", current.Disabled, plugins.Disabled)...)
+	if !confirmDiff("admission plugins of cluster "+cluster.Name, diff, yes) {
+		return
+	}
+	if err := ovhwrapper.UpdateK8SClusterCustomization(ctx, writer, sl.ID, cluster.ID, plugins); err != nil {
+		log.Fatalf("Failed to customize cluster %s: %s", cluster.Name, explainError(err))
+	}
+	fmt.Printf("Admission plugins of cluster %s updated, the api server is redeployed\n", cluster.Name)
+	if !background && !waitForClusterChange(ctx, reader, sl.ID, cluster.ID, wait) {
+		os.Exit(1)
+	}
+	refreshInventory(ctx, reader, config)
+}
+
+// diffList returns the values removed from and added to a list, prefixed with - and + and the kind of the values.
+func diffList(kind string, before, after []string) []string {
+	var diff []string
+	for _, value := range before {
+		if !slices.Contains(after, value) {
+			diff = append(diff, fmt.Sprintf("- %s %s", kind, value))
+		}
+	}
+	for _, value := range after {
+		if !slices.Contains(before, value) {
+			diff = append(diff, fmt.Sprintf("+ %s %s", kind, value))
+		}
+	}
+	return diff
+}
+
+// confirmDiff shows the changes and asks whether to apply them, unless yes is set. It returns false without
+// asking if there are no changes.
+func confirmDiff(what string, diff []string, yes bool) bool {
+	if len(diff) == 0 {
+		fmt.Printf("The %s is unchanged\n", what)
+		return false
+	}
+	fmt.Printf("Changes to the %s:\n", what)
+	for _, line := range diff {
+		fmt.Printf("  %s\n", line)
+	}
+	if !yes && !confirm("Apply the changes? (y/N): ", "y") {
+		log.Fatalf("The %s has not been changed", what)
+	}
+	return true
+}
+
+// clustergroupsOf returns the clustergroups of the inventory containing the cluster. If the protection of the
+// clustergroups can not be checked, because there is no inventory file or a cluster of a protected clustergroup
+// can not be resolved, ovhctl exits, without an inventory file only unless noProtectionCheck is set.
+func clustergroupsOf(ctx context.Context, reader ovhwrapper.API, resolver *ovhwrapper.Resolver, config ovhwrapper.Configuration, inventory, clusterid string, noProtectionCheck bool) []Clustergroup {
+	inventory, err := findInventory(config, inventory)
+	if errors.Is(err, ErrNoInventory) {
+		if !noProtectionCheck {
+			log.Fatalf("%v, clustergroup protection can not be checked (use --no-protection-check to go on without it)", err)
+		}
+		log.Printf("Warning: %v, clustergroup protection is not checked", err)
+		return nil
+	} else if err != nil {
+		log.Fatalf("%v", err)
+	}
+
+	groups, err := readInventory(ctx, reader, config, inventory).clustergroupsOf(resolver, clusterid)
+	if err != nil {
+		log.Fatalf("%s\n", explainError(err))
+	}
+	return groups
+}
+
+// clustergroupsOf returns the clustergroups containing the cluster. It fails if a cluster of a protected
+// clustergroup can not be resolved, as that might be the cluster.
+func (i Inventory) clustergroupsOf(resolver *ovhwrapper.Resolver, clusterid string) ([]Clustergroup, error) {
+	var groups []Clustergroup
+	for _, cg := range i.Clustergroups {
+		for _, project := range cg.Projects {
+			for _, clustername := range project.Clusters {
+				_, cl, err := resolver.Cluster(project.Name, clustername)
+				if err != nil {
+					if cg.Protected {
+						return nil, fmt.Errorf("checking protected clustergroup %s: %w", cg.Name, err)
+					}
+					continue
+				}
+				if cl.ID == clusterid && !slices.ContainsFunc(groups, func(g Clustergroup) bool {
+					return g.Name == cg.Name
+				}) {
+					groups = append(groups, cg)
+				}
+			}
+		}
+	}
+	return groups, nil
+}
+
+// kubeconfigFiles returns the existing kubeconfig files of the user, from $KUBECONFIG or ~/.kube/config,
+// and the central kubeconfig /etc/k8s/config.
+func kubeconfigFiles() []string {
+	var files []string
+	if env := os.Getenv("KUBECONFIG"); env != "" {
+		files = filepath.SplitList(env)
+	} else if home, err := os.UserHomeDir(); err == nil {
+		files = append(files, filepath.Join(home, ".kube", "config"))
+	}
+	files = append(files, "/etc/k8s/config")
+	return slices.DeleteFunc(files, func(file string) bool {
+		return !fileExists(file)
+	})
+}
+
+// kubeconfigContexts returns the contexts of the kubeconfig files pointing to the cluster as "<context> (<file>)".
+func kubeconfigContexts(cluster ovhwrapper.K8SCluster) []string {
+	var contexts []string
+	for _, file := range kubeconfigFiles() {
+		var kc ovhwrapper.KubeConfig
+		if err := ovhwrapper.LoadYaml(&kc, file); err != nil {
+			log.Printf("Warning: failed to read kubeconfig %s: %v", file, err)
+			continue
+		}
+		for _, con := range kc.ClusterContexts(cluster) {
+			contexts = append(contexts, fmt.Sprintf("%s (%s)", con.Name, file))
+		}
+	}
+	return contexts
+}
+
+// waitOptions configure how long ovhctl waits for a cluster to get READY, see ovhwrapper.ClusterWaiter.
+type waitOptions struct {
+	Interval   time.Duration `yaml:"interval"`
+	Timeout    time.Duration `yaml:"timeout"`
+	StuckAfter time.Duration `yaml:"stuckAfter"`
+}
+
+var (
+	// defaultWait are the defaults of the flags for waiting of the commands changing a cluster or nodepool
+	defaultWait = waitOptions{Interval: 30 * time.Second, Timeout: 4 * time.Hour, StuckAfter: time.Hour}
+	// updateWait are the defaults of the flags of the update commands
+	updateWait = waitOptions{Interval: time.Minute, Timeout: 4 * time.Hour, StuckAfter: time.Hour}
+)
+
+// watchCluster waits until the cluster is READY after a change, which is given the delay to get triggered. The
+// status of the cluster and its nodes is passed to show whenever it changes.
+func watchCluster(ctx context.Context, client ovhwrapper.API, slid, clid string, opts waitOptions, delay time.Duration, show func(status string)) ovhwrapper.WaitResult {
+	waiter := ovhwrapper.ClusterWaiter{
+		Delay:      delay,
+		Interval:   opts.Interval,
+		Timeout:    opts.Timeout,
+		StuckAfter: opts.StuckAfter,
+		Progress: func(*ovhwrapper.K8SCluster) string {
+			return statusString(ctx, client, slid, clid)
+		},
+		OnProgress: show,
+		OnError: func(err error) {
+			log.Printf("Failed to get cluster %s: %s", clid, explainError(err))
+		},
+	}
+	return waiter.Wait(ctx, client, slid, clid)
+}
+
+// reportWait logs the result of waiting for a cluster unless it succeeded, and returns whether it did.
+func reportWait(clid string, result ovhwrapper.WaitResult) bool {
+	if result.Succeeded() {
+		return true
+	}
+	name := clid
+	if result.Cluster != nil {
+		name = result.Cluster.Name
+	}
+	log.Printf("Waiting for cluster %s %s\n", name, result)
+	return false
+}
+
+// printStatus shows the status of a cluster.
+func printStatus(status string) {
+	fmt.Println(status)
+}
+
+// waitForCluster shows the status of a cluster whenever it changes, until it is READY. It returns false if the
+// cluster failed, did not get READY in time or the context got cancelled in the meantime.
+func waitForCluster(ctx context.Context, client ovhwrapper.API, slid, clid string, opts waitOptions) bool {
+	return reportWait(clid, watchCluster(ctx, client, slid, clid, opts, 0, printStatus))
+}
+
+// waitForClusterChange is like waitForCluster, but gives a change of the cluster 10 seconds
+// to get triggered.
+func waitForClusterChange(ctx context.Context, client ovhwrapper.API, slid, clid string, opts waitOptions) bool {
+	return reportWait(clid, watchCluster(ctx, client, slid, clid, opts, 10*time.Second, printStatus))
+}
+
+// waitForNodepool shows the status of a nodepool whenever it changes, until it is READY, has the desired number
+// of nodes and all of them are READY. It returns false if waiting did not succeed, see waitUntil.
+func waitForNodepool(ctx context.Context, client ovhwrapper.API, slid, clid, poolid string, opts waitOptions) bool {
+	return waitUntil(ctx, "nodepool "+poolid, opts, func(ctx context.Context) (string, bool, error) {
+		np, err := ovhwrapper.GetK8SNodepool(ctx, client, slid, clid, poolid)
+		if err != nil {
+			return "", false, err
+		}
+		nodes, err := ovhwrapper.GetK8SNodes(ctx, client, slid, clid)
+		if err != nil {
+			return "", false, err
+		}
+		var count, ready int
+		for _, node := range nodes {
+			if node.NodePoolId == poolid {
+				count++
+				if node.Status == "READY" {
+					ready++
+				}
+			}
+		}
+
+		progress := fmt.Sprintf("  Nodepool: %s\t[%s]\t%d of %d nodes, %d READY", np.Name, np.Status,
+			np.CurrentNodes, np.DesiredNodes, ready)
+		return progress, np.Status == "READY" && np.CurrentNodes == np.DesiredNodes && count == np.DesiredNodes &&
+			ready == count, nil
+	})
+}
+
+// waitUntil polls check in the interval of the options until it is done and shows its progress whenever it
+// changes, see ovhwrapper.ClusterWaiter.WaitFor. Transient errors of check are logged and polled again. It returns
+// false and logs why if waiting for what did not succeed.
+func waitUntil(ctx context.Context, what string, opts waitOptions, check ovhwrapper.WaitCheck) bool {
+	waiter := ovhwrapper.ClusterWaiter{
+		Interval:   opts.Interval,
+		Timeout:    opts.Timeout,
+		StuckAfter: opts.StuckAfter,
+		OnProgress: printStatus,
+		OnError: func(err error) {
+			log.Printf("Waiting for %s: %s\n", what, explainError(err))
+		},
+	}
+	result := waiter.WaitFor(ctx, check)
+	if !result.Succeeded() {
+		log.Printf("Waiting for %s %s\n", what, result)
+	}
+	return result.Succeeded()
+}
+
+// untilGone returns a check for waitUntil which is done as soon as the resource returned by get is not found.
+func untilGone(get func(ctx context.Context) (progress string, err error)) ovhwrapper.WaitCheck {
+	return func(ctx context.Context) (string, bool, error) {
+		progress, err := get(ctx)
+		if errors.Is(err, ovhwrapper.ErrNotFound) {
+			return "", true, nil
+		}
+		return progress, false, err
+	}
+}
+
+// registerCluster adds a cluster to a clustergroup of the inventory file, creating the clustergroup and
+// serviceline entries if necessary. The file is edited as a yaml tree, so comments and order are kept.
+func registerCluster(inventory, clustergroup, serviceline, cluster string) error {
+	data, err := os.ReadFile(inventory)
+	if err != nil {
+		return err
+	}
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return fmt.Errorf("parsing %s: %w", inventory, err)
+	}
+	if len(doc.Content) == 0 {
+		doc.Content = []*yaml.Node{{Kind: yaml.MappingNode}}
+	}
+
+	groups := mappingValue(doc.Content[0], "clustergroups", yaml.SequenceNode)
+	group := sequenceItem(groups, "name", clustergroup)
+	projects := mappingValue(group, "servicelines", yaml.SequenceNode)
+	project := sequenceItem(projects, "name", serviceline)
+	clusters := mappingValue(project, "clusters", yaml.SequenceNode)
+	for _, c := range clusters.Content {
+		if c.Value == cluster {
+			return nil
+		}
+	}
+	clusters.Content = append(clusters.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: cluster})
+
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(&doc); err != nil {
+		return err
+	}
+	return os.WriteFile(inventory, buf.Bytes(), 0644)
+}
+
+// mappingValue returns the value of a key of a yaml mapping, adding an empty node of the given kind if the
+// key does not exist.
+func mappingValue(mapping *yaml.Node, key string, kind yaml.Kind) *yaml.Node {
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		if mapping.Content[i].Value == key {
+			value := mapping.Content[i+1]
+			if value.Kind != kind && value.Tag == "!!null" {
+				// an empty entry like "clusters:"
+				value.Kind, value.Tag, value.Value = kind, "", ""
+			}
+			return value
+		}
+	}
+	value := &yaml.Node{Kind: kind}
+	mapping.Content = append(mapping.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: key}, value)
+	return value
+}
+
+// sequenceItem returns the mapping of a yaml sequence whose key has the given value, adding a new one
+// if there is none.
+func sequenceItem(sequence *yaml.Node, key, value string) *yaml.Node {
+	for _, item := range sequence.Content {
+		for i := 0; i+1 < len(item.Content); i += 2 {
+			if item.Content[i].Value == key && item.Content[i+1].Value == value {
+				return item
+			}
+		}
+	}
+	item := &yaml.Node{Kind: yaml.MappingNode}
+	mappingValue(item, key, yaml.ScalarNode).Value = value
+	sequence.Content = append(sequence.Content, item)
+	return item
+}
@@ -5,98 +5,153 @@ import (
 	"github.com/atc0005/go-teams-notify/v2/adaptivecard"
 	"github.com/ovh/go-ovh/ovh"
 	"github.com/snafuprinzip/ovhwrapper"
+	"github.com/urfave/cli/v3"
 
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
 	"log"
 	"net/smtp"
 	"os"
+	"path/filepath"
+	"slices"
 	"strings"
+	"time"
 )
 
-// CollectInformation collects the information of all service lines, including their clusters down to the nodes.
-func CollectInformation(client *ovh.Client) []ovhwrapper.ServiceLine {
-	var servicelines []ovhwrapper.ServiceLine
-
-	services := ovhwrapper.GetServicelines(client)
-	for _, service := range services {
-		serviceline := CollectServiceline(client, service)
-		servicelines = append(servicelines, *serviceline)
-	}
-
-	return servicelines
-}
-
-// GetServiceline asks the API for 'shallow' information about a specific serviceline, excluding the clusters.
-func GetServiceline(client *ovh.Client, serviceid string) *ovhwrapper.ServiceLine {
-	servicedetails, err := ovhwrapper.GetServicelineDetails(client, serviceid)
+// connect reads the configuration of the given profile and creates the api clients. If the writer has no
+// consumer key yet, a new one is created and saved and the program exits, as the key has to be validated first.
+// With offline set no api request is made, the clients are only created.
+func connect(ctx context.Context, profile string, offline bool) (ovhwrapper.Configuration, *ovh.Client, *ovh.Client) {
+	config, err := ovhwrapper.ReadProfile(profile)
 	if err != nil {
-		log.Fatalf("Failed to get serviceline details: %v", err)
+		log.Fatalf("Error loading configuration, no valid config found: %v", err)
 	}
-	serviceline := ovhwrapper.ServiceLine{
-		ID:        serviceid,
-		SLDetails: servicedetails,
-		Cluster:   []ovhwrapper.K8SCluster{},
+	for _, warning := range config.Warnings() {
+		log.Printf("Warning: %s", warning)
 	}
-	return &serviceline
-}
 
-// CollectServiceline collects information about a serviceline, including its clusters.
-func CollectServiceline(client *ovh.Client, serviceid string) *ovhwrapper.ServiceLine {
-	servicedetails, err := ovhwrapper.GetServicelineDetails(client, serviceid)
+	reader, err := ovhwrapper.CreateReader(config)
 	if err != nil {
-		log.Fatalf("Failed to get serviceline details: %v", err)
+		log.Fatalf("Error creating OVH API Reader: %q\n", err)
 	}
-	clusterids, err := ovhwrapper.GetK8SClusterIDs(client, serviceid)
+
+	writer, err := ovhwrapper.CreateWriter(config)
 	if err != nil {
-		log.Fatalf("Failed to get cluster IDs: %v", err)
+		log.Fatalf("Error creating OVH API Writer: %q\n", err)
 	}
-	var clusterlist []ovhwrapper.K8SCluster
-	for _, clusterid := range clusterids {
-		cluster := CollectCluster(client, serviceid, clusterid)
-		if cluster != nil {
-			clusterlist = append(clusterlist, *cluster)
+	debugTransport(reader, writer)
+
+	// create Writer ConsumerKey if necessary
+	if config.Writer.ConsumerKey == "" && !offline {
+		consumerkey, validationURL, err := ovhwrapper.CreateConsumerKey(ctx, reader, writer)
+		if err != nil {
+			log.Fatalf("Error creating consumer key: %s\n", explainError(err))
 		}
-	}
-	serviceline := ovhwrapper.ServiceLine{
-		ID:        serviceid,
-		SLDetails: servicedetails,
-		Cluster:   clusterlist,
+		fmt.Printf("Generated consumer key for profile %s: %s\n", config.ProfileName(), consumerkey)
+		fmt.Printf("Please visit %s to validate it\n", validationURL)
+		config.Writer.ConsumerKey = consumerkey
+
+		if err := config.Save(); err != nil {
+			log.Fatalf("Error saving config file %s: %q\n", config.GetPath(), err)
+		}
+		os.Exit(0)
 	}
 
-	return &serviceline
+	return config, reader, writer
 }
 
-// GetCluster asks the API for 'shallow' information about a specific cluster, excluding nested information
-// like etcd usage, nodes or nodepools
-func GetCluster(client *ovh.Client, serviceid, clusterid string) *ovhwrapper.K8SCluster {
-	return ovhwrapper.GetK8SCluster(client, serviceid, clusterid)
-}
+// completeIdentifiers returns a shell completion function, which completes the values of the serviceline and
+// cluster flags with the names of the cached inventory and completes the flags of the command otherwise.
+// The api is never queried, as completion has to be fast, so nothing is completed without a snapshot.
+func completeIdentifiers() cli.ShellCompleteFunc {
+	return func(ctx context.Context, cmd *cli.Command) {
+		var prev string
+		if len(os.Args) > 1 {
+			// the last argument is the completion flag itself
+			prev = os.Args[len(os.Args)-2]
+		}
 
-// CollectCluster returns information about a Cluster, including its etcd usage, nodepools and nodes.
-func CollectCluster(client *ovh.Client, serviceid, clusterid string) *ovhwrapper.K8SCluster {
-	cluster := ovhwrapper.GetK8SCluster(client, serviceid, clusterid)
-	var err error
+		var candidates func(*ovhwrapper.Resolver) []string
+		switch prev {
+		case "-s", "--serviceline":
+			candidates = func(r *ovhwrapper.Resolver) []string { return r.ServicelineCandidates() }
+		case "-c", "--cluster":
+			candidates = func(r *ovhwrapper.Resolver) []string { return r.ClusterCandidates(cmd.String("serviceline")) }
+		default:
+			cli.DefaultCompleteWithFlags(ctx, cmd)
+			return
+		}
 
-	cluster, err = ovhwrapper.GetK8SClusterDetails(client, cluster, serviceid, clusterid)
-	if err != nil {
-		log.Printf("Failed to get cluster details: %v", err)
+		// the credentials are not needed, so a credential process is not run
+		config, err := ovhwrapper.ReadConfiguration()
+		if err != nil || config.UseProfile(cmd.String("profile")) != nil {
+			return
+		}
+		snapshot, err := inventoryCache(config).Load()
+		if err != nil {
+			return
+		}
+		for _, name := range candidates(ovhwrapper.NewResolver(snapshot.Servicelines)) {
+			fmt.Fprintln(cmd.Root().Writer, name)
+		}
 	}
-	return cluster
 }
 
-// MatchItem will check if the id or the (abbreviated) name matches with the identifier and returns true or false
-func MatchItem[T ovhwrapper.ServiceLine | ovhwrapper.K8SCluster](object T, identifier string) bool {
-	match := false
-	switch object := any(object).(type) { // lazy hack, as generic functions implement specific types and not interfaces, so we cast to any to check its type
-	case ovhwrapper.ServiceLine:
-		if object.ID == identifier || object.SLDetails.Description == identifier || ovhwrapper.ShortenName(object.SLDetails.Description) == identifier {
-			match = true
+// setShellComplete sets the completion function of all commands with a serviceline or cluster flag
+func setShellComplete(commands []*cli.Command, complete cli.ShellCompleteFunc) {
+	for _, cmd := range commands {
+		for _, flag := range cmd.Flags {
+			if slices.Contains(flag.Names(), "serviceline") || slices.Contains(flag.Names(), "cluster") {
+				cmd.ShellComplete = complete
+				break
+			}
 		}
-	case ovhwrapper.K8SCluster:
-		if object.ID == identifier || object.Name == identifier || ovhwrapper.ShortenName(object.Name) == identifier {
-			match = true
+		setShellComplete(cmd.Commands, complete)
+	}
+}
+
+// explainError returns the error message together with a hint on how to solve the most common api errors
+func explainError(err error) string {
+	switch {
+	case errors.Is(err, ovhwrapper.ErrUnauthorized):
+		return fmt.Sprintf("%v\nthe consumer key is invalid or has expired, run 'ovhctl logout' to create a new one", err)
+	case errors.Is(err, ovhwrapper.ErrForbidden):
+		return fmt.Sprintf("%v\nyour writer key has no rights on this resource (e.g. a serviceline created after the key), "+
+			"run 'ovhctl logout' to create a new one", err)
+	case errors.Is(err, ovhwrapper.ErrNotFound):
+		return fmt.Sprintf("%v\nthe resource does not exist (anymore)", err)
+	case errors.Is(err, ovhwrapper.ErrAmbiguous):
+		return fmt.Sprintf("%v\nplease use the id or the full name", err)
+	case errors.Is(err, ovhwrapper.ErrRateLimited):
+		return fmt.Sprintf("%v\ntoo many requests, please try again later", err)
+	}
+	return err.Error()
+}
+
+// debugTransport logs every retry of the api clients once debug output is enabled
+func debugTransport(clients ...*ovh.Client) {
+	for _, client := range clients {
+		if transport, ok := ovhwrapper.GetRetryTransport(client); ok {
+			transport.Debugf = func(format string, args ...any) {
+				if debug {
+					log.Printf(format, args...)
+				}
+			}
 		}
 	}
-	return match
+}
+
+// printTransportStats prints the request and retry counters of an api client
+func printTransportStats(name string, client *ovh.Client) {
+	transport, ok := ovhwrapper.GetRetryTransport(client)
+	if !ok {
+		return
+	}
+	stats := transport.Stats()
+	log.Printf("%s: %d requests, %d retries, %d rate limited, %d server errors, %d failed\n", name,
+		stats.Requests, stats.Retries, stats.RateLimited, stats.ServerError, stats.Failed)
 }
 
 // fileExists returns true if a file exists, false if not
@@ -108,6 +163,34 @@ func fileExists(filename string) bool {
 	return err == nil
 }
 
+// sleepContext pauses for the given duration and returns false if the context got cancelled in the meantime
+func sleepContext(ctx context.Context, d time.Duration) bool {
+	select {
+	case <-ctx.Done():
+		return false
+	case <-time.After(d):
+		return true
+	}
+}
+
+// flagValue returns a pointer to the value of a flag, or nil if the flag has not been set on the command line
+func flagValue[T any](cmd *cli.Command, name string, value T) *T {
+	if !cmd.IsSet(name) {
+		return nil
+	}
+	return &value
+}
+
+// confirm asks the user to type the expected answer and returns true if the input matches it exactly
+func confirm(prompt, expected string) bool {
+	fmt.Print(prompt)
+	answer, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && answer == "" {
+		return false
+	}
+	return strings.TrimSpace(answer) == expected
+}
+
 func SendMail(subject, body string, to []string) error {
 	r := strings.NewReplacer("\r\n", "", "\r", "", "\n", "", "%0a", "", "%0d", "")
 
@@ -210,3 +293,25 @@ func TeamsNotify(subject, body string, webhooks []string) error {
 
 	return nil
 }
+
+// waitOptionsOf returns the options for waiting for a cluster given with the flags of the update commands
+func waitOptionsOf(cmd *cli.Command) waitOptions {
+	return waitOptions{
+		Interval:   cmd.Duration("poll-interval"),
+		Timeout:    cmd.Duration("timeout"),
+		StuckAfter: cmd.Duration("stuck-after"),
+	}
+}
+
+// stateDir returns the directory of the profile the state of long running commands like rollouts is kept in,
+// e.g. ~/.cache/ovhwrapper/ovhctl-rollouts, so they can be resumed.
+func stateDir(config ovhwrapper.Configuration, name string) string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		dir = os.TempDir()
+	}
+	if config.ProfileName() != ovhwrapper.DefaultProfileName {
+		name += "-" + config.ProfileName()
+	}
+	return filepath.Join(dir, "ovhwrapper", name)
+}
@@ -0,0 +1,279 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+
+	"github.com/snafuprinzip/ovhwrapper"
+)
+
+// ipRestrictionTarget is a cluster whose ip restrictions are shown or changed, with the networks expected by the
+// ip restriction sets of its clustergroups. expected is nil if none of its clustergroups uses a set.
+type ipRestrictionTarget struct {
+	sl       *ovhwrapper.ServiceLine
+	cl       *ovhwrapper.K8SCluster
+	expected []string
+}
+
+// ipRestrictions returns the networks of the named ip restriction sets, sorted and without duplicates.
+func (i Inventory) ipRestrictions(sets []string) ([]string, error) {
+	var ips []string
+	for _, name := range sets {
+		set, ok := i.IPRestrictions[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown ip restriction set %s", name)
+		}
+		for _, ip := range set {
+			cidr, err := ovhwrapper.ParseCIDR(ip)
+			if err != nil {
+				return nil, fmt.Errorf("ip restriction set %s: %w", name, err)
+			}
+			ips = append(ips, cidr)
+		}
+	}
+	slices.Sort(ips)
+	return slices.Compact(ips), nil
+}
+
+// expectedIPRestrictions returns the networks of the ip restriction sets used by the clustergroups, or nil if
+// none of them uses a set.
+func (i Inventory) expectedIPRestrictions(groups []Clustergroup) []string {
+	var sets []string
+	for _, cg := range groups {
+		sets = append(sets, cg.IPRestrictions...)
+	}
+	if len(sets) == 0 {
+		return nil
+	}
+	ips, err := i.ipRestrictions(sets)
+	if err != nil {
+		log.Fatalf("%v", err)
+	}
+	return ips
+}
+
+// ipRestrictionTargets returns the cluster of the selectors or all clusters of the clustergroup. The expected
+// networks are taken from the inventory, without an inventory file the clusters are not checked for drift.
+func ipRestrictionTargets(ctx context.Context, reader ovhwrapper.API, config ovhwrapper.Configuration, serviceid, clusterid, clustergroup, inventory string) ([]ipRestrictionTarget, Inventory) {
+	resolver := ovhwrapper.NewResolver(GlobalInventory)
+
+	if clustergroup == "" {
+		if serviceid == "" || clusterid == "" {
+			log.Fatalf("Select a cluster with --serviceline and --cluster or a clustergroup with --clustergroup")
+		}
+		sl, cl, err := resolver.Cluster(serviceid, clusterid)
+		if err != nil {
+			log.Fatalf("%s\n", explainError(err))
+		}
+		var inv Inventory
+		if path, err := findInventory(config, inventory); err == nil {
+			inv = readInventory(ctx, reader, config, path)
+		} else if !errors.Is(err, ErrNoInventory) {
+			log.Fatalf("%v", err)
+		}
+		groups, err := inv.clustergroupsOf(resolver, cl.ID)
+		if err != nil {
+			log.Fatalf("%s\n", explainError(err))
+		}
+		expected := inv.expectedIPRestrictions(groups)
+		return []ipRestrictionTarget{{sl: sl, cl: cl, expected: expected}}, inv
+	}
+
+	inv := readInventory(ctx, reader, config, inventory)
+	i := slices.IndexFunc(inv.Clustergroups, func(cg Clustergroup) bool { return cg.Name == clustergroup })
+	if i < 0 {
+		log.Fatalf("Clustergroup %s not found in the inventory", clustergroup)
+	}
+	cg := inv.Clustergroups[i]
+
+	var targets []ipRestrictionTarget
+	for _, project := range cg.Projects {
+		for _, clustername := range project.Clusters {
+			sl, cl, err := resolver.Cluster(project.Name, clustername)
+			if err != nil {
+				log.Printf("Skipping cluster: %s\n", explainError(err))
+				continue
+			}
+			// a cluster can be in more than one clustergroup, it expects the networks of all of them
+			groups, err := inv.clustergroupsOf(resolver, cl.ID)
+			if err != nil {
+				log.Printf("Skipping cluster: %s\n", explainError(err))
+				continue
+			}
+			targets = append(targets, ipRestrictionTarget{sl: sl, cl: cl, expected: inv.expectedIPRestrictions(groups)})
+		}
+	}
+	return targets, inv
+}
+
+// ipRestrictionArgs returns the networks given with --ip and those of the sets given with --set.
+func ipRestrictionArgs(inv Inventory, ips, sets []string) []string {
+	var cidrs []string
+	for _, ip := range ips {
+		cidr, err := ovhwrapper.ParseCIDR(ip)
+		if err != nil {
+			log.Fatalf("%v", err)
+		}
+		cidrs = append(cidrs, cidr)
+	}
+	fromSets, err := inv.ipRestrictions(sets)
+	if err != nil {
+		log.Fatalf("%v", err)
+	}
+	cidrs = append(cidrs, fromSets...)
+	slices.Sort(cidrs)
+	return slices.Compact(cidrs)
+}
+
+// ListIPRestrictions lists the networks allowed to reach the api servers of the clusters. Clusters whose
+// clustergroups use ip restriction sets are compared with them, it exits with an error if any cluster differs.
+func ListIPRestrictions(ctx context.Context, reader ovhwrapper.API, config ovhwrapper.Configuration, serviceid, clusterid, clustergroup, inventory string) {
+	targets, _ := ipRestrictionTargets(ctx, reader, config, serviceid, clusterid, clustergroup, inventory)
+
+	var drifted int
+	for _, t := range targets {
+		ips, err := ovhwrapper.GetK8SIPRestrictions(ctx, reader, t.sl.ID, t.cl.ID)
+		if err != nil {
+			log.Printf("Failed to get ip restrictions of cluster %s: %s", t.cl.Name, explainError(err))
+			drifted++
+			continue
+		}
+		fmt.Printf("Cluster: %s (%s)\n", t.cl.Name, t.sl.SLDetails.Description)
+		if len(ips) == 0 {
+			fmt.Printf("  no ip restrictions, the api server is reachable from everywhere\n")
+		}
+		for _, ip := range ips {
+			fmt.Printf("  %s\n", ip)
+		}
+		if t.expected == nil {
+			continue
+		}
+		if drift := ipRestrictionDrift(t.expected, ips); len(drift) > 0 {
+			drifted++
+			fmt.Printf("  Drift from the ip restriction sets of the clustergroup:\n")
+			for _, line := range drift {
+				fmt.Printf("    %s\n", line)
+			}
+		}
+	}
+	if drifted > 0 {
+		log.Fatalf("%d of %d clusters differ from the ip restrictions of their clustergroups", drifted, len(targets))
+	}
+}
+
+// ipRestrictionDrift returns the expected networks missing on a cluster and the networks not expected.
+func ipRestrictionDrift(expected, actual []string) []string {
+	var drift []string
+	for _, ip := range expected {
+		if !slices.Contains(actual, ip) {
+			drift = append(drift, "missing    "+ip)
+		}
+	}
+	for _, ip := range actual {
+		if !slices.Contains(expected, ip) {
+			drift = append(drift, "unexpected "+ip)
+		}
+	}
+	return drift
+}
+
+// AddIPRestrictions allows the networks and those of the ip restriction sets to reach the api servers of the
+// clusters.
+func AddIPRestrictions(ctx context.Context, reader, writer ovhwrapper.API, config ovhwrapper.Configuration, serviceid, clusterid, clustergroup, inventory string, ips, sets []string, yes bool) {
+	targets, inv := ipRestrictionTargets(ctx, reader, config, serviceid, clusterid, clustergroup, inventory)
+	add := ipRestrictionArgs(inv, ips, sets)
+	if len(add) == 0 {
+		log.Fatalf("No networks given, use --ip or --set")
+	}
+	changeIPRestrictions(ctx, reader, writer, targets, yes, func(_ ipRestrictionTarget, current []string) []string {
+		desired := slices.Clone(current)
+		for _, ip := range add {
+			if !slices.Contains(desired, ip) {
+				desired = append(desired, ip)
+			}
+		}
+		return desired
+	})
+}
+
+// RemoveIPRestrictions removes the networks and those of the ip restriction sets from the api servers of the
+// clusters.
+func RemoveIPRestrictions(ctx context.Context, reader, writer ovhwrapper.API, config ovhwrapper.Configuration, serviceid, clusterid, clustergroup, inventory string, ips, sets []string, yes bool) {
+	targets, inv := ipRestrictionTargets(ctx, reader, config, serviceid, clusterid, clustergroup, inventory)
+	remove := ipRestrictionArgs(inv, ips, sets)
+	if len(remove) == 0 {
+		log.Fatalf("No networks given, use --ip or --set")
+	}
+	changeIPRestrictions(ctx, reader, writer, targets, yes, func(_ ipRestrictionTarget, current []string) []string {
+		return slices.DeleteFunc(slices.Clone(current), func(ip string) bool {
+			return slices.Contains(remove, ip)
+		})
+	})
+}
+
+// ReplaceIPRestrictions replaces the ip restrictions of the clusters with the networks and those of the ip
+// restriction sets. Without any, the ip restriction sets of the clustergroups of the clusters are applied.
+func ReplaceIPRestrictions(ctx context.Context, reader, writer ovhwrapper.API, config ovhwrapper.Configuration, serviceid, clusterid, clustergroup, inventory string, ips, sets []string, yes bool) {
+	targets, inv := ipRestrictionTargets(ctx, reader, config, serviceid, clusterid, clustergroup, inventory)
+	replace := ipRestrictionArgs(inv, ips, sets)
+	changeIPRestrictions(ctx, reader, writer, targets, yes, func(t ipRestrictionTarget, _ []string) []string {
+		if len(replace) > 0 {
+			return replace
+		}
+		if t.expected == nil {
+			log.Fatalf("No networks given for cluster %s and its clustergroups use no ip restriction sets, use --ip or --set", t.cl.Name)
+		}
+		return t.expected
+	})
+}
+
+// changeIPRestrictions shows the changes of the ip restrictions of each cluster and applies them after
+// confirmation. Only added networks are posted and only removed networks deleted, otherwise the list is replaced.
+func changeIPRestrictions(ctx context.Context, reader, writer ovhwrapper.API, targets []ipRestrictionTarget, yes bool, desired func(ipRestrictionTarget, []string) []string) {
+	for _, t := range targets {
+		current, err := ovhwrapper.GetK8SIPRestrictions(ctx, reader, t.sl.ID, t.cl.ID)
+		if err != nil {
+			log.Fatalf("Failed to get ip restrictions of cluster %s: %s", t.cl.Name, explainError(err))
+		}
+		ips := desired(t, current)
+
+		var added, removed []string
+		for _, ip := range ips {
+			if !slices.Contains(current, ip) {
+				added = append(added, ip)
+			}
+		}
+		for _, ip := range current {
+			if !slices.Contains(ips, ip) {
+				removed = append(removed, ip)
+			}
+		}
+
+		if len(ips) == 0 && len(current) > 0 {
+			fmt.Printf("Warning: without ip restrictions the api server of cluster %s is reachable from everywhere\n", t.cl.Name)
+		}
+		if !confirmDiff("ip restrictions of cluster "+t.cl.Name, diffList("network", current, ips), yes) {
+			continue
+		}
+
+		switch {
+		case len(removed) == 0:
+			err = ovhwrapper.AddK8SIPRestrictions(ctx, writer, t.sl.ID, t.cl.ID, added)
+		case len(added) == 0:
+			for _, ip := range removed {
+				if err = ovhwrapper.DeleteK8SIPRestriction(ctx, writer, t.sl.ID, t.cl.ID, ip); err != nil {
+					break
+				}
+			}
+		default:
+			err = ovhwrapper.ReplaceK8SIPRestrictions(ctx, writer, t.sl.ID, t.cl.ID, ips)
+		}
+		if err != nil {
+			log.Fatalf("Failed to change ip restrictions of cluster %s: %s", t.cl.Name, explainError(err))
+		}
+		fmt.Printf("Ip restrictions of cluster %s changed\n", t.cl.Name)
+	}
+}
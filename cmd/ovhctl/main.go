@@ -2,8 +2,11 @@ package main
 
 import (
 	"context"
+	"errors"
 	"log"
 	"os"
+	"os/signal"
+	"syscall"
 
 	"github.com/ovh/go-ovh/ovh"
 	"github.com/snafuprinzip/ovhwrapper"
@@ -25,44 +28,21 @@ func main() {
 	var writer *ovh.Client
 	var config ovhwrapper.Configuration
 
-	config, err := ovhwrapper.ReadConfiguration()
-	if err != nil {
-		log.Fatalf("Error loading configuration, no valid config found: %v", err)
-	}
-
-	reader, err = ovhwrapper.CreateReader(config)
-	if err != nil {
-		log.Fatalf("Error creating OVH API Reader: %q\n", err)
-	}
+	// cancel running api requests and update monitoring on ctrl-c
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
-	writer, err = ovhwrapper.CreateWriter(config)
-	if err != nil {
-		log.Fatalf("Error creating OVH API Writer: %q\n", err)
-	}
-
-	// create Writer ConsumerKey if necessary
-	if config.Writer.ConsumerKey == "" {
-		// consumer key erzeugen
-		consumerkey, err := ovhwrapper.CreateConsumerKey(reader, writer)
-		if err != nil {
-			log.Fatalf("Error: %q\n", err)
-		}
-		config.Writer.ConsumerKey = consumerkey
-
-		err = ovhwrapper.SaveYaml(config, config.GetPath())
-		if err != nil {
-			log.Fatalf("Error saving config file %s: %q\n", config.GetPath(), err)
-		}
-		os.Exit(0)
-	}
-
-	GatherGlobalInventory(reader)
-	Flavors, err = ovhwrapper.GetK8SFlavors(reader, GlobalInventory[0].ID, GlobalInventory[0].Cluster[0].ID)
-	if err != nil {
-		log.Fatalf("Error getting available flavors: %q\n", err)
+	// commands working on servicelines and clusters load the inventory before they run
+	withInventory := func(ctx context.Context, cmd *cli.Command) (context.Context, error) {
+		return ctx, LoadInventory(ctx, reader, config, cmd.Bool("refresh"), cmd.Bool("offline"))
 	}
 
 	globalFlags := []cli.Flag{
+		&cli.StringFlag{
+			Name:    "profile",
+			Usage:   "use the named profile of the configuration file",
+			Sources: cli.EnvVars("OVH_PROFILE"),
+		},
 		&cli.BoolFlag{
 			Name:  "debug",
 			Usage: "enable debug output",
@@ -71,6 +51,14 @@ func main() {
 				return nil
 			},
 		},
+		&cli.BoolFlag{
+			Name:  "refresh",
+			Usage: "gather the inventory from the api instead of using the cached snapshot",
+		},
+		&cli.BoolFlag{
+			Name:  "offline",
+			Usage: "only use the last cached inventory snapshot, e.g. on hosts without api access",
+		},
 		&cli.BoolFlag{
 			Name:  "verbose",
 			Usage: "be more verbose",
@@ -81,6 +69,35 @@ func main() {
 		},
 	}
 
+	// the cluster iprestrictions commands select a cluster or a clustergroup, changes take networks and sets
+	ipRestrictionFlags := func(change bool) []cli.Flag {
+		flags := []cli.Flag{
+			&cli.StringFlag{Name: "serviceline", Aliases: []string{"s"}, Usage: "serviceline id or name"},
+			&cli.StringFlag{Name: "cluster", Aliases: []string{"c"}, Usage: "cluster id or name"},
+			&cli.StringFlag{Name: "clustergroup", Aliases: []string{"g"}, Usage: "all clusters of the clustergroup"},
+			&cli.StringFlag{Name: "inventory", Aliases: []string{"i"}, Usage: "inventory file of the clustergroups"},
+		}
+		if change {
+			flags = append(flags,
+				&cli.StringSliceFlag{Name: "ip", Usage: "network in CIDR notation or single address"},
+				&cli.StringSliceFlag{Name: "set", Usage: "named ip restriction set of the inventory"},
+				&cli.BoolFlag{Name: "yes", Aliases: []string{"y"}, Usage: "apply the changes without asking"},
+			)
+		}
+		return flags
+	}
+
+	// the update commands wait until the cluster is READY again, but give up on a failed or stuck cluster
+	waitFlags := func(defaults waitOptions) []cli.Flag {
+		return []cli.Flag{
+			&cli.DurationFlag{Name: "timeout", Value: defaults.Timeout,
+				Usage: "give up waiting after this time, 0 waits without a time limit"},
+			&cli.DurationFlag{Name: "poll-interval", Value: defaults.Interval, Usage: "interval of checking the status"},
+			&cli.DurationFlag{Name: "stuck-after", Value: defaults.StuckAfter,
+				Usage: "give up if the status did not change for this time, 0 disables the check"},
+		}
+	}
+
 	cmd := &cli.Command{
 		Name:      "ovhctl",
 		Version:   "v0.1.5",
@@ -89,17 +106,34 @@ func main() {
 		Usage:     "cli tool for the ovh api",
 		UsageText: "ovhctl <command> [subcommand] [options]",
 		Flags:     globalFlags,
+		// complete serviceline and cluster names, see 'ovhctl completion -h'
+		EnableShellCompletion: true,
+		Before: func(ctx context.Context, cmd *cli.Command) (context.Context, error) {
+			// flag actions run after the before hooks, set the global options here for loading the inventory
+			debug = cmd.Bool("debug")
+			verbose = cmd.Bool("verbose")
+			config, reader, writer = connect(ctx, cmd.String("profile"), cmd.Bool("offline"))
+			return ctx, nil
+		},
+		After: func(ctx context.Context, cmd *cli.Command) error {
+			if debug {
+				printTransportStats("reader", reader)
+				printTransportStats("writer", writer)
+			}
+			return nil
+		},
 		Commands: []*cli.Command{
 			{
 				Name:    "list",
 				Aliases: []string{"l"},
 				Usage:   "list servicelines and/or clusters",
+				Before:  withInventory,
 				Flags: []cli.Flag{
 					&cli.BoolFlag{Name: "all", Aliases: []string{"a"}, Usage: "list all servicelines and clusters"},
 					&cli.StringFlag{Name: "serviceline", Aliases: []string{"s"}, Usage: "list clusters of given serviceline"},
 				},
 				Action: func(ctx context.Context, cmd *cli.Command) error {
-					List(reader, cmd.Bool("all"), cmd.String("serviceline"))
+					List(ctx, reader, cmd.Bool("all"), cmd.String("serviceline"))
 					return nil
 				},
 			},
@@ -107,13 +141,14 @@ func main() {
 				Name:    "status",
 				Aliases: []string{"s"},
 				Usage:   "show status of a serviceline or cluster",
+				Before:  withInventory,
 				Flags: []cli.Flag{
 					&cli.BoolFlag{Name: "all", Aliases: []string{"a"}, Usage: "all servicelines and clusters"},
 					&cli.StringFlag{Name: "serviceline", Aliases: []string{"s"}, Usage: "clusters of a given serviceline"},
 					&cli.StringFlag{Name: "cluster", Aliases: []string{"c"}, Usage: "specific cluster of a given serviceline"},
 				},
 				Action: func(ctx context.Context, cmd *cli.Command) error {
-					Status(reader, cmd.Bool("all"), cmd.String("serviceline"), cmd.String("cluster"))
+					Status(ctx, reader, cmd.Bool("all"), cmd.String("serviceline"), cmd.String("cluster"))
 					return nil
 				},
 			},
@@ -121,6 +156,7 @@ func main() {
 				Name:    "describe",
 				Aliases: []string{"d"},
 				Usage:   "show details of a serviceline and or cluster(s)",
+				Before:  withInventory,
 				Flags: []cli.Flag{
 					&cli.BoolFlag{Name: "all", Aliases: []string{"a"}, Usage: "all servicelines and clusters, including nodes and nodepools"},
 					&cli.StringFlag{Name: "serviceline", Aliases: []string{"s"}, Usage: "describe a serviceline"},
@@ -128,7 +164,7 @@ func main() {
 					&cli.StringFlag{Name: "output", Aliases: []string{"o"}, Usage: "set output format [yaml, json, text]"},
 				},
 				Action: func(ctx context.Context, cmd *cli.Command) error {
-					Describe(reader, cmd.Bool("all"), cmd.String("serviceline"), cmd.String("cluster"), cmd.String("output"))
+					Describe(ctx, reader, cmd.Bool("all"), cmd.String("serviceline"), cmd.String("cluster"), cmd.String("output"))
 					return nil
 				},
 			},
@@ -136,24 +172,40 @@ func main() {
 				Name:    "update",
 				Aliases: []string{"u"},
 				Usage:   "update kubernetes version",
+				Before:  withInventory,
 				Commands: []*cli.Command{
 					{
 						Name:    "cluster",
 						Aliases: []string{"c"},
 						Usage:   "update a single cluster",
-						Flags: []cli.Flag{
+						Flags: append([]cli.Flag{
 							&cli.StringFlag{Name: "serviceline", Aliases: []string{"s"}, Usage: "clusters of a given serviceline"},
 							&cli.StringFlag{Name: "cluster", Aliases: []string{"c"}, Usage: "specific cluster of a given serviceline"},
 							&cli.BoolFlag{Name: "force", Aliases: []string{"f"}, Usage: "force update"},
+							&cli.BoolFlag{Name: "skip-preflight", Usage: "start the update even if the pre-flight checks fail"},
+							&cli.IntFlag{Name: "max-etcd", Value: DefaultMaxEtcdPercent,
+								Usage: "refuse the update if the etcd usage is above this percentage of the quota"},
 							&cli.BoolFlag{Name: "latest", Aliases: []string{"l"},
 								Usage: "set strategy to LATEST_PATCH (default is NEXT_MINOR)"},
 							&cli.BoolFlag{Name: "background", Aliases: []string{"b"},
 								Usage: "if not set the update status will be printed in 1 minute intervals until the cluster is READY again, " +
 									"if background is set the program will exit immediately after starting the upgrade"},
-						},
+							&cli.StringFlag{Name: "to-version", Aliases: []string{"t"},
+								Usage: "upgrade one minor version after the other until the cluster runs this version"},
+						}, waitFlags(updateWait)...),
 						Action: func(ctx context.Context, cmd *cli.Command) error {
-							UpdateCluster(reader, writer, config, cmd.String("serviceline"), cmd.String("cluster"),
-								cmd.Bool("background"), cmd.Bool("latest"), cmd.Bool("force"))
+							if target := cmd.String("to-version"); target != "" {
+								if cmd.Bool("latest") {
+									return errors.New("--latest and --to-version cannot be used together")
+								}
+								UpgradeCluster(ctx, reader, writer, config, cmd.String("serviceline"), cmd.String("cluster"),
+									target, cmd.Bool("background"), cmd.Bool("force"), cmd.Bool("skip-preflight"), int(cmd.Int("max-etcd")),
+									waitOptionsOf(cmd))
+								return nil
+							}
+							UpdateCluster(ctx, reader, writer, config, cmd.String("serviceline"), cmd.String("cluster"),
+								cmd.Bool("background"), cmd.Bool("latest"), cmd.Bool("force"), cmd.Bool("skip-preflight"),
+								int(cmd.Int("max-etcd")), waitOptionsOf(cmd))
 							return nil
 						},
 					},
@@ -161,16 +213,92 @@ func main() {
 						Name:    "group",
 						Aliases: []string{"g"},
 						Usage:   "update a group of clusters",
-						Flags: []cli.Flag{
+						Flags: append([]cli.Flag{
 							&cli.StringFlag{Name: "clustergroup", Aliases: []string{"g"}, Usage: "name of a group of clusters"},
 							&cli.StringFlag{Name: "inventory", Aliases: []string{"i"}, Usage: "inventory file"},
 							&cli.BoolFlag{Name: "force", Aliases: []string{"f"}, Usage: "force update"},
+							&cli.BoolFlag{Name: "skip-preflight", Usage: "start the update even if the pre-flight checks fail"},
+							&cli.IntFlag{Name: "max-etcd", Value: DefaultMaxEtcdPercent,
+								Usage: "refuse the update if the etcd usage is above this percentage of the quota"},
 							&cli.BoolFlag{Name: "latest", Aliases: []string{"l"},
 								Usage: "set strategy to LATEST_PATCH (default is NEXT_MINOR)"},
+						}, waitFlags(updateWait)...),
+						Action: func(ctx context.Context, cmd *cli.Command) error {
+							UpdateClusterGroup(ctx, reader, writer, config, cmd.String("clustergroup"), cmd.String("inventory"),
+								cmd.Bool("latest"), cmd.Bool("force"), cmd.Bool("skip-preflight"), int(cmd.Int("max-etcd")),
+								waitOptionsOf(cmd))
+							return nil
+						},
+					},
+					{
+						Name:      "resume",
+						Aliases:   []string{"r"},
+						Usage:     "continue an interrupted group update, lists the unfinished runs without a run id",
+						ArgsUsage: "<run-id>",
+						Action: func(ctx context.Context, cmd *cli.Command) error {
+							ResumeGroupUpdate(ctx, reader, writer, config, cmd.Args().First())
+							return nil
+						},
+					},
+					{
+						Name:    "plan",
+						Aliases: []string{"p"},
+						Usage:   "show what an update of a group of clusters would do, without changing anything",
+						Flags: []cli.Flag{
+							&cli.StringFlag{Name: "clustergroup", Aliases: []string{"g"}, Required: true, Usage: "name of a group of clusters"},
+							&cli.StringFlag{Name: "inventory", Aliases: []string{"i"}, Usage: "inventory file"},
+							&cli.BoolFlag{Name: "latest", Aliases: []string{"l"},
+								Usage: "plan with strategy LATEST_PATCH (default is NEXT_MINOR)"},
+							&cli.StringFlag{Name: "output", Aliases: []string{"o"}, Usage: "set output format [yaml, json, text]"},
 						},
 						Action: func(ctx context.Context, cmd *cli.Command) error {
-							UpdateClusterGroup(reader, writer, config, cmd.String("clustergroup"), cmd.String("inventory"),
-								cmd.Bool("latest"), cmd.Bool("force"))
+							PlanClusterGroup(ctx, reader, config, cmd.String("clustergroup"), cmd.String("inventory"),
+								cmd.Bool("latest"), cmd.String("output"))
+							return nil
+						},
+					},
+				},
+			},
+			{
+				Name:   "rollout",
+				Usage:  "update clustergroups one after the other with soak time and health gates",
+				Before: withInventory,
+				Commands: []*cli.Command{
+					{
+						Name:  "run",
+						Usage: "start a rollout",
+						Flags: []cli.Flag{
+							&cli.StringFlag{Name: "file", Aliases: []string{"f"}, Required: true, Usage: "rollout definition file"},
+							&cli.StringFlag{Name: "inventory", Aliases: []string{"i"}, Usage: "inventory file"},
+							&cli.BoolFlag{Name: "force", Usage: "force update"},
+							&cli.BoolFlag{Name: "skip-preflight", Usage: "start the updates even if the pre-flight checks fail"},
+						},
+						Action: func(ctx context.Context, cmd *cli.Command) error {
+							RunRollout(ctx, reader, writer, config, cmd.String("file"), cmd.String("inventory"), cmd.Bool("force"),
+								cmd.Bool("skip-preflight"))
+							return nil
+						},
+					},
+					{
+						Name:  "status",
+						Usage: "show the progress of a rollout or list all rollouts",
+						Flags: []cli.Flag{
+							&cli.StringFlag{Name: "name", Aliases: []string{"n"}, Usage: "name of the rollout"},
+							&cli.StringFlag{Name: "output", Aliases: []string{"o"}, Usage: "set output format [yaml, json, text]"},
+						},
+						Action: func(ctx context.Context, cmd *cli.Command) error {
+							RolloutStatus(config, cmd.String("name"), cmd.String("output"))
+							return nil
+						},
+					},
+					{
+						Name:  "resume",
+						Usage: "continue a stopped rollout",
+						Flags: []cli.Flag{
+							&cli.StringFlag{Name: "name", Aliases: []string{"n"}, Required: true, Usage: "name of the rollout"},
+						},
+						Action: func(ctx context.Context, cmd *cli.Command) error {
+							ResumeRollout(ctx, reader, writer, config, cmd.String("name"))
 							return nil
 						},
 					},
@@ -180,6 +308,7 @@ func main() {
 				Name:    "kubeconfig",
 				Aliases: []string{"kc"},
 				Usage:   "kubernetes client configuration",
+				Before:  withInventory,
 				Commands: []*cli.Command{
 					{
 						Name:    "get",
@@ -192,27 +321,28 @@ func main() {
 							&cli.StringFlag{Name: "cluster", Aliases: []string{"c"}, Usage: "cluster id or name"},
 							&cli.StringFlag{Name: "output", Aliases: []string{"o"}, Usage: "file, central or certs"},
 							&cli.StringFlag{Name: "path", Aliases: []string{"p"}, Usage: "output path"},
+							&cli.BoolFlag{Name: "oidc", Usage: "log in with OpenID Connect (kubectl oidc-login) instead of the admin client certificate"},
 						},
 						Action: func(ctx context.Context, cmd *cli.Command) error {
 
-							DownloadKubeconfig(reader, writer, cmd.Bool("all"), cmd.String("serviceline"),
-								cmd.String("cluster"), cmd.String("output"), cmd.String("path"))
+							DownloadKubeconfig(ctx, reader, writer, cmd.Bool("all"), cmd.String("serviceline"),
+								cmd.String("cluster"), cmd.String("output"), cmd.String("path"), cmd.Bool("oidc"))
 							return nil
 						},
 					},
 					{
 						Name:  "reset",
 						Usage: "reset kubeconfig of cluster in the ovh cloud, will redeploy the cluster and reinstall the nodes",
-						Flags: []cli.Flag{
+						Flags: append([]cli.Flag{
 							&cli.StringFlag{Name: "serviceline", Aliases: []string{"s"}, Usage: "serviceline id or name"},
 							&cli.StringFlag{Name: "cluster", Aliases: []string{"c"}, Usage: "cluster id or name"},
 							&cli.BoolFlag{Name: "background", Aliases: []string{"b"},
 								Usage: "if not set the cluster status will be printed in 1 minute intervals until the cluster is READY again, " +
 									"if background is set the program will exit immediately after starting the reset"},
-						},
+						}, waitFlags(updateWait)...),
 						Action: func(ctx context.Context, cmd *cli.Command) error {
-							ResetKubeconfig(reader, writer, config, cmd.String("serviceline"),
-								cmd.String("cluster"), cmd.Bool("background"))
+							ResetKubeconfig(ctx, reader, writer, config, cmd.String("serviceline"),
+								cmd.String("cluster"), cmd.Bool("background"), waitOptionsOf(cmd))
 							//fmt.Println("reset kubeconfig: ", cmd.Args().First())
 							return nil
 						},
@@ -224,6 +354,7 @@ func main() {
 				Name:    "volumes",
 				Aliases: []string{"vol"},
 				Usage:   "persistent ovh volumes",
+				Before:  withInventory,
 				Commands: []*cli.Command{
 					{
 						Name:    "list",
@@ -237,7 +368,7 @@ func main() {
 							&cli.BoolFlag{Name: "unattached", Aliases: []string{"u"}, Usage: "list only unattached volumes"},
 						},
 						Action: func(ctx context.Context, cmd *cli.Command) error {
-							ListOVHVolumes(reader, writer, cmd.Bool("all"), cmd.String("serviceline"),
+							ListOVHVolumes(ctx, reader, writer, cmd.Bool("all"), cmd.String("serviceline"),
 								cmd.String("cluster"), cmd.Bool("unattached"))
 							return nil
 						},
@@ -255,7 +386,7 @@ func main() {
 							&cli.BoolFlag{Name: "unattached", Aliases: []string{"u"}, Usage: "list only unattached volumes"},
 						},
 						Action: func(ctx context.Context, cmd *cli.Command) error {
-							DescribeOVHVolumes(reader, writer, cmd.Bool("all"), cmd.String("serviceline"),
+							DescribeOVHVolumes(ctx, reader, writer, cmd.Bool("all"), cmd.String("serviceline"),
 								cmd.String("cluster"), cmd.Bool("unattached"), cmd.String("output"))
 							return nil
 						},
@@ -270,7 +401,7 @@ func main() {
 								Usage: "by default only volumes with no pods are deleted, use this flag to delete all volumes"},
 						},
 						Action: func(ctx context.Context, cmd *cli.Command) error {
-							DeleteOVHVolume(reader, writer, cmd.String("serviceline"),
+							DeleteOVHVolume(ctx, reader, writer, cmd.String("serviceline"),
 								cmd.String("cluster"), cmd.Bool("force"))
 							return nil
 						},
@@ -282,36 +413,485 @@ func main() {
 				Name:    "flavors",
 				Aliases: []string{"f"},
 				Usage:   "list available nodepool flavors",
+				Before:  withInventory,
 				Action: func(ctx context.Context, cmd *cli.Command) error {
-					ListFlavors(reader, Flavors)
+					ListFlavors(ctx, reader, Flavors)
 					return nil
 				},
 			},
+			{
+				Name:   "create",
+				Usage:  "create resources from a spec",
+				Before: withInventory,
+				Commands: []*cli.Command{
+					{
+						Name:  "cluster",
+						Usage: "create a cluster with its nodepools from a yaml spec and wait until it is READY",
+						Flags: append([]cli.Flag{
+							&cli.StringFlag{Name: "file", Aliases: []string{"f"}, Required: true, Usage: "cluster spec file"},
+							&cli.StringFlag{Name: "serviceline", Aliases: []string{"s"}, Usage: "serviceline of the cluster, overrides the spec"},
+							&cli.StringFlag{Name: "clustergroup", Aliases: []string{"g"}, Usage: "register the cluster in this clustergroup, overrides the spec"},
+							&cli.StringFlag{Name: "inventory", Aliases: []string{"i"}, Usage: "inventory file of the clustergroups"},
+							&cli.BoolFlag{Name: "background", Aliases: []string{"b"},
+								Usage: "exit after the cluster creation has been started instead of waiting until it is READY"},
+						}, waitFlags(defaultWait)...),
+						Action: func(ctx context.Context, cmd *cli.Command) error {
+							CreateCluster(ctx, reader, writer, config, cmd.String("file"), cmd.String("serviceline"),
+								cmd.String("clustergroup"), cmd.String("inventory"), cmd.Bool("background"), waitOptionsOf(cmd))
+							return nil
+						},
+					},
+				},
+			},
+			{
+				Name:   "delete",
+				Usage:  "delete resources",
+				Before: withInventory,
+				Commands: []*cli.Command{
+					{
+						Name: "cluster",
+						Usage: "delete a cluster with all of its nodepools after retyping its name, clusters of protected " +
+							"clustergroups are refused",
+						Flags: append([]cli.Flag{
+							&cli.StringFlag{Name: "serviceline", Aliases: []string{"s"}, Required: true, Usage: "serviceline id or name"},
+							&cli.StringFlag{Name: "cluster", Aliases: []string{"c"}, Required: true, Usage: "cluster id or name"},
+							&cli.StringFlag{Name: "inventory", Aliases: []string{"i"}, Usage: "inventory file of the clustergroups"},
+							&cli.BoolFlag{Name: "no-protection-check",
+								Usage: "go on without an inventory file, the clustergroup protection is not checked then"},
+							&cli.BoolFlag{Name: "background", Aliases: []string{"b"},
+								Usage: "exit after the deletion has been started instead of waiting until the cluster is gone"},
+						}, waitFlags(defaultWait)...),
+						Action: func(ctx context.Context, cmd *cli.Command) error {
+							DeleteCluster(ctx, reader, writer, config, cmd.String("serviceline"), cmd.String("cluster"),
+								cmd.String("inventory"), cmd.Bool("no-protection-check"), cmd.Bool("background"), waitOptionsOf(cmd))
+							return nil
+						},
+					},
+				},
+			},
+			{
+				Name:   "cluster",
+				Usage:  "change the settings of a cluster",
+				Before: withInventory,
+				Commands: []*cli.Command{
+					{
+						Name:  "policy",
+						Usage: "update policy of a cluster",
+						Commands: []*cli.Command{
+							{
+								Name:  "set",
+								Usage: "set the update policy from the flag or a cluster spec and wait until the cluster is READY",
+								Flags: append([]cli.Flag{
+									&cli.StringFlag{Name: "serviceline", Aliases: []string{"s"}, Required: true, Usage: "serviceline id or name"},
+									&cli.StringFlag{Name: "cluster", Aliases: []string{"c"}, Required: true, Usage: "cluster id or name"},
+									&cli.StringFlag{Name: "policy", Usage: "ALWAYS_UPDATE, MINIMAL_DOWNTIME or NEVER_UPDATE"},
+									&cli.StringFlag{Name: "file", Aliases: []string{"f"}, Usage: "cluster spec with the updatePolicy"},
+									&cli.BoolFlag{Name: "yes", Aliases: []string{"y"}, Usage: "apply the change without asking"},
+									&cli.BoolFlag{Name: "background", Aliases: []string{"b"},
+										Usage: "exit after the change instead of waiting until the cluster is READY"},
+								}, waitFlags(defaultWait)...),
+								Action: func(ctx context.Context, cmd *cli.Command) error {
+									SetClusterPolicy(ctx, reader, writer, config, cmd.String("serviceline"), cmd.String("cluster"),
+										cmd.String("policy"), cmd.String("file"), cmd.Bool("yes"), cmd.Bool("background"), waitOptionsOf(cmd))
+									return nil
+								},
+							},
+						},
+					},
+					{
+						Name:  "customize",
+						Usage: "enable or disable admission plugins of the api server and wait until the cluster is READY",
+						Flags: append([]cli.Flag{
+							&cli.StringFlag{Name: "serviceline", Aliases: []string{"s"}, Required: true, Usage: "serviceline id or name"},
+							&cli.StringFlag{Name: "cluster", Aliases: []string{"c"}, Required: true, Usage: "cluster id or name"},
+							&cli.StringSliceFlag{Name: "enable", Usage: "enable an admission plugin"},
+							&cli.StringSliceFlag{Name: "disable", Usage: "disable an admission plugin"},
+							&cli.StringFlag{Name: "file", Aliases: []string{"f"}, Usage: "cluster spec with the admissionPlugins"},
+							&cli.BoolFlag{Name: "yes", Aliases: []string{"y"}, Usage: "apply the changes without asking"},
+							&cli.BoolFlag{Name: "background", Aliases: []string{"b"},
+								Usage: "exit after the change instead of waiting until the cluster is READY"},
+						}, waitFlags(defaultWait)...),
+						Action: func(ctx context.Context, cmd *cli.Command) error {
+							CustomizeCluster(ctx, reader, writer, config, cmd.String("serviceline"), cmd.String("cluster"),
+								cmd.String("file"), cmd.StringSlice("enable"), cmd.StringSlice("disable"), cmd.Bool("yes"),
+								cmd.Bool("background"), waitOptionsOf(cmd))
+							return nil
+						},
+					},
+					{
+						Name:    "iprestrictions",
+						Aliases: []string{"ipr"},
+						Usage:   "networks allowed to reach the api server of a cluster or of all clusters of a clustergroup",
+						Commands: []*cli.Command{
+							{
+								Name:  "list",
+								Usage: "list the ip restrictions and report the drift from the ip restriction sets of the clustergroups",
+								Flags: ipRestrictionFlags(false),
+								Action: func(ctx context.Context, cmd *cli.Command) error {
+									ListIPRestrictions(ctx, reader, config, cmd.String("serviceline"), cmd.String("cluster"),
+										cmd.String("clustergroup"), cmd.String("inventory"))
+									return nil
+								},
+							},
+							{
+								Name:  "add",
+								Usage: "allow networks to reach the api server",
+								Flags: ipRestrictionFlags(true),
+								Action: func(ctx context.Context, cmd *cli.Command) error {
+									AddIPRestrictions(ctx, reader, writer, config, cmd.String("serviceline"), cmd.String("cluster"),
+										cmd.String("clustergroup"), cmd.String("inventory"), cmd.StringSlice("ip"),
+										cmd.StringSlice("set"), cmd.Bool("yes"))
+									return nil
+								},
+							},
+							{
+								Name:  "remove",
+								Usage: "remove networks from the ip restrictions",
+								Flags: ipRestrictionFlags(true),
+								Action: func(ctx context.Context, cmd *cli.Command) error {
+									RemoveIPRestrictions(ctx, reader, writer, config, cmd.String("serviceline"), cmd.String("cluster"),
+										cmd.String("clustergroup"), cmd.String("inventory"), cmd.StringSlice("ip"),
+										cmd.StringSlice("set"), cmd.Bool("yes"))
+									return nil
+								},
+							},
+							{
+								Name:  "replace",
+								Usage: "replace the ip restrictions, without --ip and --set with the ip restriction sets of the clustergroups",
+								Flags: ipRestrictionFlags(true),
+								Action: func(ctx context.Context, cmd *cli.Command) error {
+									ReplaceIPRestrictions(ctx, reader, writer, config, cmd.String("serviceline"), cmd.String("cluster"),
+										cmd.String("clustergroup"), cmd.String("inventory"), cmd.StringSlice("ip"),
+										cmd.StringSlice("set"), cmd.Bool("yes"))
+									return nil
+								},
+							},
+						},
+					},
+					{
+						Name:  "oidc",
+						Usage: "OpenID Connect configuration of the api server, see 'ovhctl kubeconfig get --oidc'",
+						Commands: []*cli.Command{
+							{
+								Name:  "show",
+								Usage: "show the OpenID Connect configuration",
+								Flags: []cli.Flag{
+									&cli.StringFlag{Name: "serviceline", Aliases: []string{"s"}, Required: true, Usage: "serviceline id or name"},
+									&cli.StringFlag{Name: "cluster", Aliases: []string{"c"}, Required: true, Usage: "cluster id or name"},
+									&cli.StringFlag{Name: "output", Aliases: []string{"o"}, Usage: "set output format [yaml, json, text]"},
+								},
+								Action: func(ctx context.Context, cmd *cli.Command) error {
+									ShowOIDC(ctx, reader, cmd.String("serviceline"), cmd.String("cluster"), cmd.String("output"))
+									return nil
+								},
+							},
+							{
+								Name:  "set",
+								Usage: "create or update the OpenID Connect configuration and wait until the cluster is READY",
+								Flags: append([]cli.Flag{
+									&cli.StringFlag{Name: "serviceline", Aliases: []string{"s"}, Required: true, Usage: "serviceline id or name"},
+									&cli.StringFlag{Name: "cluster", Aliases: []string{"c"}, Required: true, Usage: "cluster id or name"},
+									&cli.StringFlag{Name: "file", Aliases: []string{"f"}, Usage: "yaml file with the OpenID Connect configuration"},
+									&cli.StringFlag{Name: "issuer-url", Usage: "https url of the OpenID Connect provider"},
+									&cli.StringFlag{Name: "client-id", Usage: "client id of the cluster at the provider"},
+									&cli.StringFlag{Name: "username-claim", Usage: "claim used as user name, e.g. email"},
+									&cli.StringFlag{Name: "username-prefix", Usage: "prefix of the user names"},
+									&cli.StringSliceFlag{Name: "groups-claim", Usage: "claim with the groups of the user"},
+									&cli.StringFlag{Name: "groups-prefix", Usage: "prefix of the group names"},
+									&cli.StringSliceFlag{Name: "required-claim", Usage: "claim required in the token, key=value"},
+									&cli.StringSliceFlag{Name: "signing-algorithm", Usage: "accepted signing algorithm, e.g. RS256"},
+									&cli.StringFlag{Name: "ca-file", Usage: "ca certificate of the provider, if it is not signed by a public ca"},
+									&cli.BoolFlag{Name: "yes", Aliases: []string{"y"}, Usage: "apply the changes without asking"},
+									&cli.BoolFlag{Name: "background", Aliases: []string{"b"},
+										Usage: "exit after the change instead of waiting until the cluster is READY"},
+								}, waitFlags(defaultWait)...),
+								Action: func(ctx context.Context, cmd *cli.Command) error {
+									flags := oidcFlags{
+										IssuerURL:         flagValue(cmd, "issuer-url", cmd.String("issuer-url")),
+										ClientID:          flagValue(cmd, "client-id", cmd.String("client-id")),
+										UsernameClaim:     flagValue(cmd, "username-claim", cmd.String("username-claim")),
+										UsernamePrefix:    flagValue(cmd, "username-prefix", cmd.String("username-prefix")),
+										GroupsClaim:       flagValue(cmd, "groups-claim", cmd.StringSlice("groups-claim")),
+										GroupsPrefix:      flagValue(cmd, "groups-prefix", cmd.String("groups-prefix")),
+										RequiredClaim:     flagValue(cmd, "required-claim", cmd.StringSlice("required-claim")),
+										SigningAlgorithms: flagValue(cmd, "signing-algorithm", cmd.StringSlice("signing-algorithm")),
+										CaFile:            flagValue(cmd, "ca-file", cmd.String("ca-file")),
+									}
+									SetOIDC(ctx, reader, writer, config, cmd.String("serviceline"), cmd.String("cluster"),
+										cmd.String("file"), flags, cmd.Bool("yes"), cmd.Bool("background"), waitOptionsOf(cmd))
+									return nil
+								},
+							},
+							{
+								Name:  "delete",
+								Usage: "delete the OpenID Connect configuration and wait until the cluster is READY",
+								Flags: append([]cli.Flag{
+									&cli.StringFlag{Name: "serviceline", Aliases: []string{"s"}, Required: true, Usage: "serviceline id or name"},
+									&cli.StringFlag{Name: "cluster", Aliases: []string{"c"}, Required: true, Usage: "cluster id or name"},
+									&cli.BoolFlag{Name: "yes", Aliases: []string{"y"}, Usage: "delete without asking"},
+									&cli.BoolFlag{Name: "background", Aliases: []string{"b"},
+										Usage: "exit after the deletion instead of waiting until the cluster is READY"},
+								}, waitFlags(defaultWait)...),
+								Action: func(ctx context.Context, cmd *cli.Command) error {
+									DeleteOIDC(ctx, reader, writer, config, cmd.String("serviceline"), cmd.String("cluster"),
+										cmd.Bool("yes"), cmd.Bool("background"), waitOptionsOf(cmd))
+									return nil
+								},
+							},
+						},
+					},
+					{
+						Name: "reset",
+						Usage: "reinstall the control plane, erasing all kubernetes resources, after retyping the cluster name, " +
+							"clusters of protected clustergroups are refused",
+						Flags: append([]cli.Flag{
+							&cli.StringFlag{Name: "serviceline", Aliases: []string{"s"}, Required: true, Usage: "serviceline id or name"},
+							&cli.StringFlag{Name: "cluster", Aliases: []string{"c"}, Required: true, Usage: "cluster id or name"},
+							&cli.StringFlag{Name: "version", Usage: "kubernetes version after the reset (default is the current version)"},
+							&cli.BoolFlag{Name: "reinstall-nodes", Usage: "reinstall the worker nodes instead of deleting them"},
+							&cli.StringFlag{Name: "inventory", Aliases: []string{"i"}, Usage: "inventory file of the clustergroups"},
+							&cli.BoolFlag{Name: "no-protection-check",
+								Usage: "go on without an inventory file, the clustergroup protection is not checked then"},
+							&cli.BoolFlag{Name: "background", Aliases: []string{"b"},
+								Usage: "exit after the reset has been started instead of waiting until the cluster is READY"},
+						}, waitFlags(defaultWait)...),
+						Action: func(ctx context.Context, cmd *cli.Command) error {
+							ResetCluster(ctx, reader, writer, config, cmd.String("serviceline"), cmd.String("cluster"),
+								cmd.String("version"), cmd.String("inventory"), cmd.Bool("reinstall-nodes"), cmd.Bool("no-protection-check"),
+								cmd.Bool("background"), waitOptionsOf(cmd))
+							return nil
+						},
+					},
+					{
+						Name:  "restart",
+						Usage: "restart the control plane after confirmation, the nodes and workloads keep running",
+						Flags: append([]cli.Flag{
+							&cli.StringFlag{Name: "serviceline", Aliases: []string{"s"}, Required: true, Usage: "serviceline id or name"},
+							&cli.StringFlag{Name: "cluster", Aliases: []string{"c"}, Required: true, Usage: "cluster id or name"},
+							&cli.BoolFlag{Name: "background", Aliases: []string{"b"},
+								Usage: "exit after the restart has been started instead of waiting until the cluster is READY"},
+						}, waitFlags(defaultWait)...),
+						Action: func(ctx context.Context, cmd *cli.Command) error {
+							RestartCluster(ctx, reader, writer, config, cmd.String("serviceline"), cmd.String("cluster"),
+								cmd.Bool("background"), waitOptionsOf(cmd))
+							return nil
+						},
+					},
+				},
+			},
+			{
+				Name:    "nodepool",
+				Aliases: []string{"np"},
+				Usage:   "create, scale, tune the autoscaler of and delete nodepools",
+				Before:  withInventory,
+				Commands: []*cli.Command{
+					{
+						Name:  "create",
+						Usage: "create a nodepool after checking flavor and quota and wait until its nodes are READY",
+						Flags: append([]cli.Flag{
+							&cli.StringFlag{Name: "serviceline", Aliases: []string{"s"}, Required: true, Usage: "serviceline id or name"},
+							&cli.StringFlag{Name: "cluster", Aliases: []string{"c"}, Required: true, Usage: "cluster id or name"},
+							&cli.StringFlag{Name: "nodepool", Aliases: []string{"p"}, Required: true, Usage: "name of the new nodepool"},
+							&cli.StringFlag{Name: "flavor", Aliases: []string{"f"}, Required: true, Usage: "flavor of the nodes, see ovhctl flavors"},
+							&cli.IntFlag{Name: "nodes", Aliases: []string{"n"}, Value: 1, Usage: "desired number of nodes"},
+							&cli.IntFlag{Name: "min", Usage: "minimum number of nodes"},
+							&cli.IntFlag{Name: "max", Usage: "maximum number of nodes"},
+							&cli.BoolFlag{Name: "autoscale", Usage: "let the autoscaler change the number of nodes between min and max"},
+							&cli.BoolFlag{Name: "anti-affinity", Usage: "place the nodes on different hypervisors"},
+							&cli.BoolFlag{Name: "monthly-billed", Usage: "bill the nodes monthly instead of hourly"},
+							&cli.BoolFlag{Name: "background", Aliases: []string{"b"},
+								Usage: "exit after the nodepool has been created instead of waiting until its nodes are READY"},
+						}, waitFlags(defaultWait)...),
+						Action: func(ctx context.Context, cmd *cli.Command) error {
+							spec := ovhwrapper.K8SNodepoolSpec{
+								Name:          cmd.String("nodepool"),
+								Flavor:        cmd.String("flavor"),
+								DesiredNodes:  int(cmd.Int("nodes")),
+								MinNodes:      int(cmd.Int("min")),
+								MaxNodes:      int(cmd.Int("max")),
+								Autoscale:     cmd.Bool("autoscale"),
+								AntiAffinity:  cmd.Bool("anti-affinity"),
+								MonthlyBilled: cmd.Bool("monthly-billed"),
+							}
+							CreateNodepool(ctx, reader, writer, config, cmd.String("serviceline"), cmd.String("cluster"),
+								spec, cmd.Bool("background"), waitOptionsOf(cmd))
+							return nil
+						},
+					},
+					{
+						Name:  "scale",
+						Usage: "change the number of nodes after checking the quota and wait until all nodes are READY",
+						Flags: append([]cli.Flag{
+							&cli.StringFlag{Name: "serviceline", Aliases: []string{"s"}, Required: true, Usage: "serviceline id or name"},
+							&cli.StringFlag{Name: "cluster", Aliases: []string{"c"}, Required: true, Usage: "cluster id or name"},
+							&cli.StringFlag{Name: "nodepool", Aliases: []string{"p"}, Required: true, Usage: "nodepool id or name"},
+							&cli.IntFlag{Name: "nodes", Aliases: []string{"n"}, Usage: "desired number of nodes"},
+							&cli.IntFlag{Name: "min", Usage: "minimum number of nodes"},
+							&cli.IntFlag{Name: "max", Usage: "maximum number of nodes"},
+							&cli.BoolFlag{Name: "background", Aliases: []string{"b"},
+								Usage: "exit after the new size has been set instead of waiting until all nodes are READY"},
+						}, waitFlags(defaultWait)...),
+						Action: func(ctx context.Context, cmd *cli.Command) error {
+							ScaleNodepool(ctx, reader, writer, config, cmd.String("serviceline"), cmd.String("cluster"),
+								cmd.String("nodepool"), flagValue(cmd, "nodes", int(cmd.Int("nodes"))),
+								flagValue(cmd, "min", int(cmd.Int("min"))), flagValue(cmd, "max", int(cmd.Int("max"))),
+								cmd.Bool("background"), waitOptionsOf(cmd))
+							return nil
+						},
+					},
+					{
+						Name:  "autoscale",
+						Usage: "enable or disable the autoscaler of a nodepool and tune its scale down behaviour",
+						Flags: []cli.Flag{
+							&cli.StringFlag{Name: "serviceline", Aliases: []string{"s"}, Required: true, Usage: "serviceline id or name"},
+							&cli.StringFlag{Name: "cluster", Aliases: []string{"c"}, Required: true, Usage: "cluster id or name"},
+							&cli.StringFlag{Name: "nodepool", Aliases: []string{"p"}, Required: true, Usage: "nodepool id or name"},
+							&cli.BoolFlag{Name: "enable", Usage: "enable the autoscaler"},
+							&cli.BoolFlag{Name: "disable", Usage: "disable the autoscaler"},
+							&cli.FloatFlag{Name: "utilization-threshold", Usage: "remove nodes with a lower utilization, between 0 and 1"},
+							&cli.DurationFlag{Name: "unneeded-time", Usage: "time a node has to be unneeded before it is removed"},
+							&cli.DurationFlag{Name: "unready-time", Usage: "time a node has to be unready before it is removed"},
+						},
+						Action: func(ctx context.Context, cmd *cli.Command) error {
+							if cmd.Bool("enable") && cmd.Bool("disable") {
+								return errors.New("--enable and --disable cannot be used together")
+							}
+							var autoscale *bool
+							if cmd.Bool("enable") || cmd.Bool("disable") {
+								enabled := cmd.Bool("enable")
+								autoscale = &enabled
+							}
+							AutoscaleNodepool(ctx, reader, writer, config, cmd.String("serviceline"), cmd.String("cluster"),
+								cmd.String("nodepool"), autoscale,
+								flagValue(cmd, "utilization-threshold", cmd.Float("utilization-threshold")),
+								flagValue(cmd, "unneeded-time", cmd.Duration("unneeded-time")),
+								flagValue(cmd, "unready-time", cmd.Duration("unready-time")))
+							return nil
+						},
+					},
+					{
+						Name:  "template",
+						Usage: "labels, annotations and taints applied to the nodes of a nodepool",
+						Commands: []*cli.Command{
+							{
+								Name:  "set",
+								Usage: "add or remove labels, annotations and taints, the changes are shown before they are applied",
+								Flags: []cli.Flag{
+									&cli.StringFlag{Name: "serviceline", Aliases: []string{"s"}, Required: true, Usage: "serviceline id or name"},
+									&cli.StringFlag{Name: "cluster", Aliases: []string{"c"}, Required: true, Usage: "cluster id or name"},
+									&cli.StringFlag{Name: "nodepool", Aliases: []string{"p"}, Required: true, Usage: "nodepool id or name"},
+									&cli.StringSliceFlag{Name: "label", Aliases: []string{"l"}, Usage: "add or change a label, key=value"},
+									&cli.StringSliceFlag{Name: "remove-label", Usage: "remove the label with the given key"},
+									&cli.StringSliceFlag{Name: "annotation", Usage: "add or change an annotation, key=value"},
+									&cli.StringSliceFlag{Name: "remove-annotation", Usage: "remove the annotation with the given key"},
+									&cli.StringSliceFlag{Name: "taint", Aliases: []string{"t"}, Usage: "add or change a taint, key=value:Effect"},
+									&cli.StringSliceFlag{Name: "remove-taint", Usage: "remove the taints with the given key or key:Effect"},
+									&cli.BoolFlag{Name: "yes", Aliases: []string{"y"}, Usage: "apply the changes without asking"},
+								},
+								Action: func(ctx context.Context, cmd *cli.Command) error {
+									SetNodepoolTemplate(ctx, reader, writer, config, cmd.String("serviceline"), cmd.String("cluster"),
+										cmd.String("nodepool"), cmd.StringSlice("label"), cmd.StringSlice("remove-label"),
+										cmd.StringSlice("annotation"), cmd.StringSlice("remove-annotation"),
+										cmd.StringSlice("taint"), cmd.StringSlice("remove-taint"), cmd.Bool("yes"))
+									return nil
+								},
+							},
+						},
+					},
+					{
+						Name:  "delete",
+						Usage: "delete a nodepool with all of its nodes after retyping its name",
+						Flags: append([]cli.Flag{
+							&cli.StringFlag{Name: "serviceline", Aliases: []string{"s"}, Required: true, Usage: "serviceline id or name"},
+							&cli.StringFlag{Name: "cluster", Aliases: []string{"c"}, Required: true, Usage: "cluster id or name"},
+							&cli.StringFlag{Name: "nodepool", Aliases: []string{"p"}, Required: true, Usage: "nodepool id or name"},
+							&cli.BoolFlag{Name: "background", Aliases: []string{"b"},
+								Usage: "exit after the deletion has been started instead of waiting until the nodepool is gone"},
+						}, waitFlags(defaultWait)...),
+						Action: func(ctx context.Context, cmd *cli.Command) error {
+							DeleteNodepool(ctx, reader, writer, config, cmd.String("serviceline"), cmd.String("cluster"),
+								cmd.String("nodepool"), cmd.Bool("background"), waitOptionsOf(cmd))
+							return nil
+						},
+					},
+				},
+			},
+			{
+				Name:   "node",
+				Usage:  "operations on single nodes",
+				Before: withInventory,
+				Commands: []*cli.Command{
+					{
+						Name:  "replace",
+						Usage: "replace the outdated or broken nodes of a nodepool and wait until the replacements are READY",
+						Flags: append([]cli.Flag{
+							&cli.StringFlag{Name: "serviceline", Aliases: []string{"s"}, Required: true, Usage: "serviceline id or name"},
+							&cli.StringFlag{Name: "cluster", Aliases: []string{"c"}, Required: true, Usage: "cluster id or name"},
+							&cli.StringFlag{Name: "nodepool", Aliases: []string{"p"}, Required: true, Usage: "nodepool id or name"},
+							&cli.StringFlag{Name: "node", Aliases: []string{"n"}, Usage: "replace only this node, id or name"},
+							&cli.BoolFlag{Name: "rolling", Aliases: []string{"r"},
+								Usage: "replace one node at a time, the next one only after the replacement is READY"},
+							&cli.IntFlag{Name: "max-unavailable", Aliases: []string{"m"}, Value: 1,
+								Usage: "with --rolling the number of nodes replaced at a time"},
+							&cli.BoolFlag{Name: "yes", Aliases: []string{"y"}, Usage: "replace the nodes without asking"},
+						}, waitFlags(defaultWait)...),
+						Action: func(ctx context.Context, cmd *cli.Command) error {
+							if cmd.IsSet("max-unavailable") && !cmd.Bool("rolling") {
+								return errors.New("--max-unavailable can only be used with --rolling")
+							}
+							ReplaceNodes(ctx, reader, writer, config, cmd.String("serviceline"), cmd.String("cluster"),
+								cmd.String("nodepool"), cmd.String("node"), cmd.Bool("rolling"), int(cmd.Int("max-unavailable")),
+								cmd.Bool("yes"), waitOptionsOf(cmd))
+							return nil
+						},
+					},
+				},
+			},
 			{
 				Name:    "credentials",
 				Aliases: []string{"cred"},
 				Usage:   "shows the credentials used for api access",
 				Flags: []cli.Flag{
 					&cli.StringFlag{Name: "output", Aliases: []string{"o"}, Usage: "set output format [yaml, json, text]"},
+					&cli.BoolFlag{Name: "source", Usage: "show where each credential value came from instead of the tokens"},
 				},
 				Action: func(ctx context.Context, cmd *cli.Command) error {
-					Credentials(reader, writer, cmd.String("output"))
+					if cmd.Bool("source") {
+						CredentialSources(config, cmd.String("output"))
+						return nil
+					}
+					Credentials(ctx, reader, writer, cmd.String("output"))
 					return nil
 				},
+				Commands: []*cli.Command{
+					{
+						Name:  "migrate",
+						Usage: "move the plaintext credentials of all profiles into the keyring or an encrypted file",
+						Flags: []cli.Flag{
+							&cli.StringFlag{Name: "store", Value: ovhwrapper.StoreKeyring, Usage: "secret store [keyring, file]"},
+						},
+						Action: func(ctx context.Context, cmd *cli.Command) error {
+							MigrateSecrets(config, cmd.String("store"))
+							return nil
+						},
+					},
+				},
 			},
 			{
 				Name:    "logout",
 				Aliases: []string{"o"},
 				Usage:   "revoke consumer key, next time the command will be run it will create a new consumer key",
 				Action: func(ctx context.Context, cmd *cli.Command) error {
-					Logout(writer, config)
+					Logout(ctx, writer, config)
 					return nil
 				},
 			},
 		},
 	}
 
-	if err := cmd.Run(context.Background(), os.Args); err != nil {
+	setShellComplete(cmd.Commands, completeIdentifiers())
+
+	if err := cmd.Run(ctx, os.Args); err != nil {
 		log.Fatal(err)
 	}
 }
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/snafuprinzip/ovhwrapper"
+)
+
+// ReplaceNodes replaces the outdated and broken nodes of a nodepool, or only the given node. The nodes are
+// deleted and recreated by the nodepool. Without rolling all of them are replaced at once, with rolling at
+// most maxUnavailable at a time; the next nodes are only replaced once the nodepool has all of its nodes
+// READY again.
+func ReplaceNodes(ctx context.Context, reader, writer ovhwrapper.API, config ovhwrapper.Configuration, serviceid, clusterid, poolid, nodeid string, rolling bool, maxUnavailable int, yes bool, wait waitOptions) {
+	if maxUnavailable < 1 {
+		log.Fatalf("--max-unavailable has to be at least 1")
+	}
+	sl, cl, cached := resolveNodepool(serviceid, clusterid, poolid)
+	np, err := ovhwrapper.GetK8SNodepool(ctx, reader, sl.ID, cl.ID, cached.Id)
+	if err != nil {
+		log.Fatalf("Failed to get nodepool %s: %s", cached.Name, explainError(err))
+	}
+	nodes, err := ovhwrapper.GetK8SNodes(ctx, reader, sl.ID, cl.ID)
+	if err != nil {
+		log.Fatalf("Failed to get nodes of cluster %s: %s", cl.Name, explainError(err))
+	}
+
+	var replace []ovhwrapper.K8SNode
+	for _, node := range nodes {
+		switch {
+		case node.NodePoolId != np.Id:
+		case nodeid != "":
+			if node.Id == nodeid || node.Name == nodeid {
+				replace = append(replace, node)
+			}
+		case !node.IsUpToDate || node.Status != "READY":
+			replace = append(replace, node)
+		}
+	}
+	if len(replace) == 0 {
+		if nodeid != "" {
+			log.Fatalf("Node %s not found in nodepool %s", nodeid, np.Name)
+		}
+		fmt.Printf("All nodes of nodepool %s are up to date and READY\n", np.Name)
+		return
+	}
+
+	fmt.Printf("Replacing %d nodes of nodepool %s in cluster %s:\n", len(replace), np.Name, cl.Name)
+	for _, node := range replace {
+		fmt.Printf("    Node: %s\t[%s]\t%s (up2date: %t)\n", node.Name, node.Status, node.Version, node.IsUpToDate)
+	}
+	if !yes && !confirm("Continue? (y/N): ", "y") {
+		log.Fatalf("No nodes have been replaced")
+	}
+
+	if !rolling {
+		maxUnavailable = len(replace)
+	}
+	for i := 0; i < len(replace); i += maxUnavailable {
+		batch := replace[i:min(i+maxUnavailable, len(replace))]
+		var names []string
+		for _, node := range batch {
+			names = append(names, node.Name)
+		}
+		fmt.Printf("Replacing %s (%d of %d)\n", strings.Join(names, ", "), i+len(batch), len(replace))
+		if !replaceNodes(ctx, reader, writer, sl.ID, cl.ID, np, batch, wait) {
+			log.Fatalf("Stopped at %s, the remaining nodes have not been replaced", strings.Join(names, ", "))
+		}
+	}
+	fmt.Printf("%d nodes of nodepool %s replaced\n", len(replace), np.Name)
+	refreshInventory(ctx, reader, config)
+}
+
+// replaceNodes deletes the nodes and waits until they are gone and the nodepool has created their READY
+// replacements. It returns false if a node could not be replaced or waiting did not succeed, see waitUntil.
+func replaceNodes(ctx context.Context, reader, writer ovhwrapper.API, slid, clid string, np ovhwrapper.K8SNodepool, nodes []ovhwrapper.K8SNode, wait waitOptions) bool {
+	for _, node := range nodes {
+		if err := ovhwrapper.DeleteK8SNode(ctx, writer, slid, clid, node.Id); err != nil {
+			log.Printf("Failed to delete node %s: %s", node.Name, explainError(err))
+			return false
+		}
+	}
+	for _, node := range nodes {
+		gone := waitUntil(ctx, "node "+node.Name, wait, untilGone(func(ctx context.Context) (string, error) {
+			cur, err := ovhwrapper.GetK8SNode(ctx, reader, slid, clid, node.Id)
+			if err != nil {
+				return "", err
+			}
+			return fmt.Sprintf("    Node: %s\t[%s]", cur.Name, cur.Status), nil
+		}))
+		if !gone {
+			return false
+		}
+	}
+
+	// deleting a node may lower the desired number of nodes, scale the pool back to get the replacements
+	pool, err := ovhwrapper.GetK8SNodepool(ctx, reader, slid, clid, np.Id)
+	if err != nil {
+		log.Printf("Failed to get nodepool %s: %s", np.Name, explainError(err))
+		return false
+	}
+	if pool.DesiredNodes < np.DesiredNodes {
+		err := ovhwrapper.ResizeK8SNodepool(ctx, writer, slid, clid, np.Id, np.DesiredNodes, pool.MinNodes, pool.MaxNodes)
+		if err != nil {
+			log.Printf("Failed to scale nodepool %s back to %d nodes: %s", np.Name, np.DesiredNodes, explainError(err))
+			return false
+		}
+	}
+	return waitForNodepool(ctx, reader, slid, clid, np.Id, wait)
+}
@@ -0,0 +1,260 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/snafuprinzip/ovhwrapper"
+)
+
+// resolveNodepool returns the serviceline, cluster and nodepool of the selectors or exits.
+func resolveNodepool(serviceid, clusterid, poolid string) (*ovhwrapper.ServiceLine, *ovhwrapper.K8SCluster, *ovhwrapper.K8SNodepool) {
+	resolver := ovhwrapper.NewResolver(GlobalInventory)
+	sl, cl, err := resolver.Cluster(serviceid, clusterid)
+	if err != nil {
+		log.Fatalf("%s\n", explainError(err))
+	}
+	np, err := resolver.Nodepool(cl, poolid)
+	if err != nil {
+		log.Fatalf("%s\n", explainError(err))
+	}
+	return sl, cl, np
+}
+
+// checkCapacity exits if the flavor is not available in the cluster or the additional nodes do not fit into
+// the quota of the serviceline in the region of the cluster.
+func checkCapacity(ctx context.Context, reader ovhwrapper.API, sl *ovhwrapper.ServiceLine, cl *ovhwrapper.K8SCluster, flavorname string, nodes int) {
+	flavors, err := ovhwrapper.GetK8SFlavors(ctx, reader, sl.ID, cl.ID)
+	if err != nil {
+		log.Fatalf("Failed to get flavors of cluster %s: %s", cl.Name, explainError(err))
+	}
+	flavor, ok := flavors[flavorname]
+	if !ok {
+		log.Fatalf("Flavor %s is not offered for cluster %s", flavorname, cl.Name)
+	}
+	if !flavor.Available() {
+		log.Fatalf("Flavor %s is currently not available in region %s", flavorname, cl.Region)
+	}
+	if nodes <= 0 {
+		return
+	}
+
+	quota, err := ovhwrapper.GetOVHQuota(ctx, reader, sl.ID, cl.Region)
+	if errors.Is(err, ovhwrapper.ErrNotFound) {
+		log.Printf("Warning: no quota found for region %s, skipping the quota check", cl.Region)
+		return
+	} else if err != nil {
+		log.Fatalf("Failed to get quota of serviceline %s: %s", sl.SLDetails.Description, explainError(err))
+	}
+	if err := quota.Instance.Fits(flavor, nodes); err != nil {
+		log.Fatalf("Not enough quota in serviceline %s, region %s: %v", sl.SLDetails.Description, cl.Region, err)
+	}
+}
+
+// CreateNodepool creates a nodepool in a cluster and waits until all of its nodes are READY.
+func CreateNodepool(ctx context.Context, reader, writer ovhwrapper.API, config ovhwrapper.Configuration, serviceid, clusterid string, spec ovhwrapper.K8SNodepoolSpec, background bool, wait waitOptions) {
+	sl, cl, err := ovhwrapper.NewResolver(GlobalInventory).Cluster(serviceid, clusterid)
+	if err != nil {
+		log.Fatalf("%s\n", explainError(err))
+	}
+	if err := spec.Validate(); err != nil {
+		log.Fatalf("Invalid nodepool: %v", err)
+	}
+	for _, np := range cl.Nodepools {
+		if np.Name == spec.Name {
+			log.Fatalf("Nodepool %s already exists in cluster %s (%s)", spec.Name, cl.Name, np.Id)
+		}
+	}
+	checkCapacity(ctx, reader, sl, cl, spec.Flavor, spec.DesiredNodes)
+
+	fmt.Printf("Creating nodepool %s with %d %s nodes in cluster %s\n", spec.Name, spec.DesiredNodes, spec.Flavor, cl.Name)
+	np, err := ovhwrapper.CreateK8SNodepool(ctx, writer, sl.ID, cl.ID, spec)
+	if err != nil {
+		log.Fatalf("Failed to create nodepool %s: %s", spec.Name, explainError(err))
+	}
+	if !background && !waitForNodepool(ctx, reader, sl.ID, cl.ID, np.Id, wait) {
+		os.Exit(1)
+	}
+	refreshInventory(ctx, reader, config)
+}
+
+// ScaleNodepool changes the number of nodes of a nodepool, nil values are kept. Before adding nodes, the
+// flavor and the quota are checked. Afterwards it waits until the nodepool has the desired number of
+// READY nodes.
+func ScaleNodepool(ctx context.Context, reader, writer ovhwrapper.API, config ovhwrapper.Configuration, serviceid, clusterid, poolid string, desired, minNodes, maxNodes *int, background bool, wait waitOptions) {
+	sl, cl, cached := resolveNodepool(serviceid, clusterid, poolid)
+	np, err := ovhwrapper.GetK8SNodepool(ctx, reader, sl.ID, cl.ID, cached.Id)
+	if err != nil {
+		log.Fatalf("Failed to get nodepool %s: %s", cached.Name, explainError(err))
+	}
+
+	spec := ovhwrapper.K8SNodepoolSpec{Name: np.Name, Flavor: np.Flavor, DesiredNodes: np.DesiredNodes,
+		MinNodes: np.MinNodes, MaxNodes: np.MaxNodes}
+	if desired != nil {
+		spec.DesiredNodes = *desired
+	}
+	if minNodes != nil {
+		spec.MinNodes = *minNodes
+	}
+	if maxNodes != nil {
+		spec.MaxNodes = *maxNodes
+	}
+	if err := spec.Validate(); err != nil {
+		log.Fatalf("Invalid size: %v", err)
+	}
+	checkCapacity(ctx, reader, sl, cl, np.Flavor, spec.DesiredNodes-np.CurrentNodes)
+
+	fmt.Printf("Scaling nodepool %s of cluster %s from %d to %d nodes (min %d, max %d)\n", np.Name, cl.Name,
+		np.DesiredNodes, spec.DesiredNodes, spec.MinNodes, spec.MaxNodes)
+	err = ovhwrapper.ResizeK8SNodepool(ctx, writer, sl.ID, cl.ID, np.Id, spec.DesiredNodes, spec.MinNodes, spec.MaxNodes)
+	if err != nil {
+		log.Fatalf("Failed to scale nodepool %s: %s", np.Name, explainError(err))
+	}
+	if !background && !waitForNodepool(ctx, reader, sl.ID, cl.ID, np.Id, wait) {
+		os.Exit(1)
+	}
+	refreshInventory(ctx, reader, config)
+}
+
+// AutoscaleNodepool enables or disables the autoscaler of a nodepool and tunes its parameters, nil values
+// are kept.
+func AutoscaleNodepool(ctx context.Context, reader, writer ovhwrapper.API, config ovhwrapper.Configuration, serviceid, clusterid, poolid string, autoscale *bool, threshold *float64, unneeded, unready *time.Duration) {
+	sl, cl, cached := resolveNodepool(serviceid, clusterid, poolid)
+	np, err := ovhwrapper.GetK8SNodepool(ctx, reader, sl.ID, cl.ID, cached.Id)
+	if err != nil {
+		log.Fatalf("Failed to get nodepool %s: %s", cached.Name, explainError(err))
+	}
+
+	enabled, autoscaling := np.Autoscale, np.Autoscaling
+	if autoscale != nil {
+		enabled = *autoscale
+	}
+	if threshold != nil {
+		if *threshold < 0 || *threshold > 1 {
+			log.Fatalf("The utilization threshold has to be between 0 and 1, e.g. 0.5 for 50%%")
+		}
+		autoscaling.ScaleDownUtilizationThreshold = *threshold
+	}
+	if unneeded != nil {
+		autoscaling.ScaleDownUnneededTimeSeconds = int(unneeded.Seconds())
+	}
+	if unready != nil {
+		autoscaling.ScaleDownUnreadyTimeSeconds = int(unready.Seconds())
+	}
+
+	err = ovhwrapper.UpdateK8SNodepoolAutoscaling(ctx, writer, sl.ID, cl.ID, np.Id, enabled, autoscaling)
+	if err != nil {
+		log.Fatalf("Failed to update autoscaling of nodepool %s: %s", np.Name, explainError(err))
+	}
+	fmt.Printf("Nodepool %s of cluster %s: autoscale %t (%d to %d nodes), scale down below %.0f%% utilization "+
+		"after %s, unready nodes after %s\n", np.Name, cl.Name, enabled, np.MinNodes, np.MaxNodes,
+		autoscaling.ScaleDownUtilizationThreshold*100,
+		time.Duration(autoscaling.ScaleDownUnneededTimeSeconds)*time.Second,
+		time.Duration(autoscaling.ScaleDownUnreadyTimeSeconds)*time.Second)
+	refreshInventory(ctx, reader, config)
+}
+
+// DeleteNodepool deletes a nodepool with all of its nodes after the user retyped its name.
+func DeleteNodepool(ctx context.Context, reader, writer ovhwrapper.API, config ovhwrapper.Configuration, serviceid, clusterid, poolid string, background bool, wait waitOptions) {
+	sl, cl, np := resolveNodepool(serviceid, clusterid, poolid)
+	nodes, err := ovhwrapper.GetK8SNodes(ctx, reader, sl.ID, cl.ID)
+	if err != nil {
+		log.Fatalf("Failed to get nodes of cluster %s: %s", cl.Name, explainError(err))
+	}
+
+	fmt.Printf("Nodepool %s (%s) of cluster %s in serviceline %s\n", np.Name, np.Id, cl.Name, sl.SLDetails.Description)
+	for _, node := range nodes {
+		if node.NodePoolId == np.Id {
+			fmt.Printf("    Node: %s\t[%s]\n", node.Name, node.Status)
+		}
+	}
+	if !confirm(fmt.Sprintf("Type the nodepool name %s to confirm the deletion: ", np.Name), np.Name) {
+		log.Fatalf("Nodepool name does not match, %s has not been deleted", np.Name)
+	}
+	if err := ovhwrapper.DeleteK8SNodepool(ctx, writer, sl.ID, cl.ID, np.Id); err != nil {
+		log.Fatalf("Failed to delete nodepool %s: %s", np.Name, explainError(err))
+	}
+	fmt.Printf("Deleting nodepool %s\n", np.Name)
+
+	if !background {
+		deleted := waitUntil(ctx, "nodepool "+np.Name, wait, untilGone(func(ctx context.Context) (string, error) {
+			cur, err := ovhwrapper.GetK8SNodepool(ctx, reader, sl.ID, cl.ID, np.Id)
+			if err != nil {
+				return "", err
+			}
+			return fmt.Sprintf("  Nodepool: %s\t[%s]\t%d nodes", cur.Name, cur.Status, cur.CurrentNodes), nil
+		}))
+		if !deleted {
+			refreshInventory(ctx, reader, config)
+			os.Exit(1)
+		}
+		fmt.Printf("Nodepool %s deleted\n", np.Name)
+	}
+	refreshInventory(ctx, reader, config)
+}
+
+// SetNodepoolTemplate adds and removes labels, annotations and taints of the template of a nodepool. Labels
+// and annotations are given as key=value, taints as key=value:Effect. The changes are shown as a diff and
+// only applied after confirmation, unless yes is set.
+func SetNodepoolTemplate(ctx context.Context, reader, writer ovhwrapper.API, config ovhwrapper.Configuration, serviceid, clusterid, poolid string,
+	labels, removeLabels, annotations, removeAnnotations, taints, removeTaints []string, yes bool) {
+	sl, cl, cached := resolveNodepool(serviceid, clusterid, poolid)
+	np, err := ovhwrapper.GetK8SNodepool(ctx, reader, sl.ID, cl.ID, cached.Id)
+	if err != nil {
+		log.Fatalf("Failed to get nodepool %s: %s", cached.Name, explainError(err))
+	}
+
+	template := np.Template.Clone()
+	if template.Metadata.Labels == nil {
+		template.Metadata.Labels = map[string]string{}
+	}
+	if template.Metadata.Annotations == nil {
+		template.Metadata.Annotations = map[string]string{}
+	}
+	for _, key := range removeLabels {
+		delete(template.Metadata.Labels, key)
+	}
+	for _, label := range labels {
+		key, value, ok := strings.Cut(label, "=")
+		if !ok || key == "" {
+			log.Fatalf("Invalid label %q, use key=value", label)
+		}
+		template.Metadata.Labels[key] = value
+	}
+	for _, key := range removeAnnotations {
+		delete(template.Metadata.Annotations, key)
+	}
+	for _, annotation := range annotations {
+		key, value, ok := strings.Cut(annotation, "=")
+		if !ok || key == "" {
+			log.Fatalf("Invalid annotation %q, use key=value", annotation)
+		}
+		template.Metadata.Annotations[key] = value
+	}
+	for _, taint := range removeTaints {
+		key, effect, _ := strings.Cut(taint, ":")
+		template.RemoveTaint(key, effect)
+	}
+	for _, s := range taints {
+		taint, err := ovhwrapper.ParseTaint(s)
+		if err != nil {
+			log.Fatalf("Invalid taint: %v", err)
+		}
+		template.SetTaint(taint)
+	}
+
+	if !confirmDiff(fmt.Sprintf("template of nodepool %s of cluster %s", np.Name, cl.Name), np.Template.Diff(template), yes) {
+		return
+	}
+
+	if err := ovhwrapper.UpdateK8SNodepoolTemplate(ctx, writer, sl.ID, cl.ID, np.Id, template); err != nil {
+		log.Fatalf("Failed to update template of nodepool %s: %s", np.Name, explainError(err))
+	}
+	fmt.Printf("Template of nodepool %s updated\n", np.Name)
+	refreshInventory(ctx, reader, config)
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/snafuprinzip/ovhwrapper"
+)
+
+// oidcFlags are the settings of the OpenID Connect configuration given on the command line, nil if not set.
+type oidcFlags struct {
+	IssuerURL         *string
+	ClientID          *string
+	UsernameClaim     *string
+	UsernamePrefix    *string
+	GroupsClaim       *[]string
+	GroupsPrefix      *string
+	RequiredClaim     *[]string
+	SigningAlgorithms *[]string
+	CaFile            *string
+}
+
+// apply sets the given settings in the configuration.
+func (f oidcFlags) apply(oidc *ovhwrapper.K8SOpenIDConnect) {
+	if f.IssuerURL != nil {
+		oidc.IssuerURL = *f.IssuerURL
+	}
+	if f.ClientID != nil {
+		oidc.ClientID = *f.ClientID
+	}
+	if f.UsernameClaim != nil {
+		oidc.UsernameClaim = *f.UsernameClaim
+	}
+	if f.UsernamePrefix != nil {
+		oidc.UsernamePrefix = *f.UsernamePrefix
+	}
+	if f.GroupsClaim != nil {
+		oidc.GroupsClaim = *f.GroupsClaim
+	}
+	if f.GroupsPrefix != nil {
+		oidc.GroupsPrefix = *f.GroupsPrefix
+	}
+	if f.RequiredClaim != nil {
+		oidc.RequiredClaim = *f.RequiredClaim
+	}
+	if f.SigningAlgorithms != nil {
+		oidc.SigningAlgorithms = *f.SigningAlgorithms
+	}
+	if f.CaFile != nil {
+		ca, err := os.ReadFile(*f.CaFile)
+		if err != nil {
+			log.Fatalf("Failed to read ca file %s: %v", *f.CaFile, err)
+		}
+		oidc.CaContent = base64.StdEncoding.EncodeToString(ca)
+	}
+}
+
+// getOIDC returns the OpenID Connect configuration of the cluster, or nil if it has none.
+func getOIDC(ctx context.Context, reader ovhwrapper.API, slid string, cluster *ovhwrapper.K8SCluster) *ovhwrapper.K8SOpenIDConnect {
+	oidc, err := ovhwrapper.GetK8SOpenIDConnect(ctx, reader, slid, cluster.ID)
+	if errors.Is(err, ovhwrapper.ErrNotFound) {
+		return nil
+	} else if err != nil {
+		log.Fatalf("Failed to get OpenID Connect configuration of cluster %s: %s", cluster.Name, explainError(err))
+	}
+	return oidc
+}
+
+// ShowOIDC shows the OpenID Connect configuration of a cluster.
+func ShowOIDC(ctx context.Context, reader ovhwrapper.API, serviceid, clusterid, output string) {
+	sl, cluster := resolveCluster(ctx, reader, serviceid, clusterid)
+	oidc := getOIDC(ctx, reader, sl.ID, cluster)
+	if oidc == nil {
+		fmt.Printf("Cluster %s has no OpenID Connect configuration\n", cluster.Name)
+		return
+	}
+
+	switch output {
+	case "yaml":
+		fmt.Println(ovhwrapper.ToYaml(oidc))
+	case "json":
+		fmt.Println(ovhwrapper.ToJSON(oidc))
+	case "text":
+		fallthrough
+	default:
+		fmt.Printf("Cluster: %s\n%s\n", cluster.Name, oidc.Details())
+	}
+}
+
+// SetOIDC creates or updates the OpenID Connect configuration of a cluster. A file replaces the current
+// configuration, the flags are applied on top of it.
+func SetOIDC(ctx context.Context, reader, writer ovhwrapper.API, config ovhwrapper.Configuration, serviceid, clusterid, file string, flags oidcFlags, yes, background bool, wait waitOptions) {
+	sl, cluster := resolveCluster(ctx, reader, serviceid, clusterid)
+	current := getOIDC(ctx, reader, sl.ID, cluster)
+
+	var before ovhwrapper.K8SOpenIDConnect
+	if current != nil {
+		before = *current
+	}
+	oidc := before
+	if file != "" {
+		oidc = ovhwrapper.K8SOpenIDConnect{}
+		if err := ovhwrapper.LoadYaml(&oidc, file); err != nil {
+			log.Fatalf("Failed to read OpenID Connect configuration %s: %v", file, err)
+		}
+	}
+	flags.apply(&oidc)
+	if err := oidc.Validate(); err != nil {
+		log.Fatalf("%v", err)
+	}
+
+	if !confirmDiff("OpenID Connect configuration of cluster "+cluster.Name, before.Diff(oidc), yes) {
+		return
+	}
+
+	var err error
+	if current == nil {
+		err = ovhwrapper.CreateK8SOpenIDConnect(ctx, writer, sl.ID, cluster.ID, oidc)
+	} else {
+		err = ovhwrapper.UpdateK8SOpenIDConnect(ctx, writer, sl.ID, cluster.ID, oidc)
+	}
+	if err != nil {
+		log.Fatalf("Failed to set OpenID Connect configuration of cluster %s: %s", cluster.Name, explainError(err))
+	}
+	fmt.Printf("OpenID Connect configuration of cluster %s set, the api server is redeployed\n", cluster.Name)
+	if !background && !waitForClusterChange(ctx, reader, sl.ID, cluster.ID, wait) {
+		os.Exit(1)
+	}
+	refreshInventory(ctx, reader, config)
+}
+
+// DeleteOIDC removes the OpenID Connect configuration of a cluster after confirmation.
+func DeleteOIDC(ctx context.Context, reader, writer ovhwrapper.API, config ovhwrapper.Configuration, serviceid, clusterid string, yes, background bool, wait waitOptions) {
+	sl, cluster := resolveCluster(ctx, reader, serviceid, clusterid)
+	current := getOIDC(ctx, reader, sl.ID, cluster)
+	if current == nil {
+		fmt.Printf("Cluster %s has no OpenID Connect configuration\n", cluster.Name)
+		return
+	}
+	if !confirmDiff("OpenID Connect configuration of cluster "+cluster.Name, current.Diff(ovhwrapper.K8SOpenIDConnect{}), yes) {
+		return
+	}
+
+	if err := ovhwrapper.DeleteK8SOpenIDConnect(ctx, writer, sl.ID, cluster.ID); err != nil {
+		log.Fatalf("Failed to delete OpenID Connect configuration of cluster %s: %s", cluster.Name, explainError(err))
+	}
+	fmt.Printf("OpenID Connect configuration of cluster %s deleted, OIDC kubeconfigs no longer work\n", cluster.Name)
+	if !background && !waitForClusterChange(ctx, reader, sl.ID, cluster.ID, wait) {
+		os.Exit(1)
+	}
+	refreshInventory(ctx, reader, config)
+}
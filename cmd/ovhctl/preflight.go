@@ -0,0 +1,113 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/snafuprinzip/ovhwrapper"
+)
+
+// DefaultMaxEtcdPercent is the etcd usage in percent of the quota above which a cluster is not updated.
+const DefaultMaxEtcdPercent = 80
+
+// preflightCheck is one check of the pre-flight phase of an update.
+type preflightCheck struct {
+	Name   string
+	Passed bool
+	Detail string
+}
+
+// preflightChecks is the result of the pre-flight phase of an update.
+type preflightChecks []preflightCheck
+
+// Passed reports whether all checks passed.
+func (p preflightChecks) Passed() bool {
+	for _, check := range p {
+		if !check.Passed {
+			return false
+		}
+	}
+	return true
+}
+
+// String returns the checks as a checklist.
+func (p preflightChecks) String() string {
+	var sb strings.Builder
+	for _, check := range p {
+		mark := "[x]"
+		if !check.Passed {
+			mark = "[ ]"
+		}
+		sb.WriteString(fmt.Sprintf("  %s %s", mark, check.Name))
+		if check.Detail != "" {
+			sb.WriteString(": " + check.Detail)
+		}
+		sb.WriteString("\n")
+	}
+	return sb.String()
+}
+
+// preflight checks whether a cluster is healthy enough to be updated: the cluster is READY, all nodepools have
+// their desired number of nodes available, all nodes are READY and the etcd usage is not above maxEtcd percent
+// of the quota.
+func preflight(ctx context.Context, reader ovhwrapper.API, slid, clid string, maxEtcd int) preflightChecks {
+	cluster, err := ovhwrapper.GetK8SCluster(ctx, reader, slid, clid)
+	if err == nil {
+		cluster, err = ovhwrapper.GetK8SClusterDetails(ctx, reader, cluster, slid, clid)
+	}
+	if err != nil {
+		return preflightChecks{{Name: "cluster is reachable", Detail: explainError(err)}}
+	}
+
+	checks := preflightChecks{{
+		Name:   "cluster is READY",
+		Passed: cluster.Status == "READY",
+		Detail: cluster.Status,
+	}}
+
+	for _, np := range cluster.Nodepools {
+		checks = append(checks, preflightCheck{
+			Name:   "nodepool " + np.Name + " has all nodes available",
+			Passed: np.AvailableNodes >= np.DesiredNodes,
+			Detail: fmt.Sprintf("%d of %d", np.AvailableNodes, np.DesiredNodes),
+		})
+	}
+
+	var notReady []string
+	for _, node := range cluster.Nodes {
+		if node.Status != "READY" {
+			notReady = append(notReady, node.Name+" "+node.Status)
+		}
+	}
+	nodes := preflightCheck{Name: "all nodes are READY", Passed: len(notReady) == 0,
+		Detail: fmt.Sprintf("%d nodes", len(cluster.Nodes))}
+	if len(notReady) > 0 {
+		nodes.Detail = strings.Join(notReady, ", ")
+	}
+	checks = append(checks, nodes)
+
+	checks = append(checks, preflightCheck{
+		Name:   fmt.Sprintf("etcd usage is not above %d%%", maxEtcd),
+		Passed: cluster.EtcdUsage.Percent() <= maxEtcd,
+		Detail: fmt.Sprintf("%d%% (%d of %d)", cluster.EtcdUsage.Percent(), cluster.EtcdUsage.Usage, cluster.EtcdUsage.Quota),
+	})
+	return checks
+}
+
+// checkPreflight runs the pre-flight checks of a cluster and prints them as a checklist. It returns the checks
+// and whether the update may be started, which is the case if all checks passed or they are skipped.
+func checkPreflight(ctx context.Context, reader ovhwrapper.API, slid, clid, name string, maxEtcd int, skip bool) (preflightChecks, bool) {
+	checks := preflight(ctx, reader, slid, clid, maxEtcd)
+	fmt.Printf("Pre-flight checks of cluster %s:\n%s", name, checks)
+	if checks.Passed() {
+		return checks, true
+	}
+	if skip {
+		log.Printf("Pre-flight checks of cluster %s failed, updating it anyway because of --skip-preflight", name)
+		return checks, true
+	}
+	log.Printf("Pre-flight checks of cluster %s failed, not updating it (use --skip-preflight to update anyway)", name)
+	return checks, false
+}
@@ -0,0 +1,475 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/snafuprinzip/ovhwrapper"
+)
+
+// Status of a stage of a rollout.
+const (
+	StagePending  = "pending"
+	StageUpdating = "updating"
+	StageSoaking  = "soaking"
+	StageDone     = "done"
+	StageFailed   = "failed"
+)
+
+// rolloutDefinition describes a staged rollout: the clustergroups are updated one after the other, each stage
+// has to pass the health gates after the soak time before the next one is started.
+type rolloutDefinition struct {
+	Name string `yaml:"name" json:"name"`
+	// clustergroups of the inventory in the order they are updated
+	Stages []string `yaml:"stages" json:"stages"`
+	// time to wait after a stage has been updated before its health gates are checked
+	Soak   time.Duration `yaml:"soak" json:"soak"`
+	Latest bool          `yaml:"latest,omitempty" json:"latest,omitempty"`
+	Gates  rolloutGates  `yaml:"gates" json:"gates"`
+}
+
+// rolloutGates are the health checks every cluster of a stage has to pass before the rollout goes on.
+type rolloutGates struct {
+	// all clusters are READY
+	Ready bool `yaml:"ready" json:"ready"`
+	// all nodes of the clusters are up to date
+	NodesUpToDate bool `yaml:"nodesUpToDate" json:"nodesUpToDate"`
+	// the etcd usage of the clusters is not above this percentage of the quota, like in the pre-flight checks,
+	// 0 disables the gate
+	MaxEtcdPercent int `yaml:"maxEtcdPercent,omitempty" json:"maxEtcdPercent,omitempty"`
+}
+
+// maxEtcdPercent returns the etcd gate, which is also used for the pre-flight checks of the updates, or the
+// default if the gate is disabled.
+func (g rolloutGates) maxEtcdPercent() int {
+	if g.MaxEtcdPercent > 0 {
+		return g.MaxEtcdPercent
+	}
+	return DefaultMaxEtcdPercent
+}
+
+// Validate checks the definition against the clustergroups of the inventory.
+func (r rolloutDefinition) Validate(inv Inventory) error {
+	if r.Name == "" {
+		return errors.New("rollout has no name")
+	}
+	if strings.ContainsAny(r.Name, `/\`) {
+		return fmt.Errorf("invalid rollout name %q", r.Name)
+	}
+	if len(r.Stages) == 0 {
+		return fmt.Errorf("rollout %s has no stages", r.Name)
+	}
+	if r.Soak < 0 {
+		return fmt.Errorf("rollout %s has a negative soak time", r.Name)
+	}
+	if r.Gates.MaxEtcdPercent < 0 || r.Gates.MaxEtcdPercent > 100 {
+		return fmt.Errorf("rollout %s: maxEtcdPercent has to be between 0 and 100", r.Name)
+	}
+	for i, stage := range r.Stages {
+		if !slices.ContainsFunc(inv.Clustergroups, func(cg Clustergroup) bool { return cg.Name == stage }) {
+			return fmt.Errorf("rollout %s: clustergroup %s not found in the inventory", r.Name, stage)
+		}
+		if slices.Contains(r.Stages[:i], stage) {
+			return fmt.Errorf("rollout %s: clustergroup %s is listed twice", r.Name, stage)
+		}
+	}
+	return nil
+}
+
+// rolloutStage is the progress of one clustergroup of a rollout.
+type rolloutStage struct {
+	Clustergroup string    `yaml:"clustergroup" json:"clustergroup"`
+	Status       string    `yaml:"status" json:"status"`
+	StartedAt    time.Time `yaml:"startedAt,omitempty" json:"startedAt,omitempty"`
+	SoakUntil    time.Time `yaml:"soakUntil,omitempty" json:"soakUntil,omitempty"`
+	FinishedAt   time.Time `yaml:"finishedAt,omitempty" json:"finishedAt,omitempty"`
+	// the id of the group update run of the clustergroup, see 'ovhctl update resume'
+	UpdateRun string `yaml:"updateRun,omitempty" json:"updateRun,omitempty"`
+	// the gates the stage failed
+	Failures []string `yaml:"failures,omitempty" json:"failures,omitempty"`
+}
+
+// rolloutState is the progress of a rollout, saved after every step so it can be resumed.
+type rolloutState struct {
+	Rollout   rolloutDefinition `yaml:"rollout" json:"rollout"`
+	Inventory string            `yaml:"inventory" json:"inventory"`
+	Force     bool              `yaml:"force,omitempty" json:"force,omitempty"`
+	// update the clusters even if their pre-flight checks fail
+	SkipPreflight bool           `yaml:"skipPreflight,omitempty" json:"skipPreflight,omitempty"`
+	StartedAt     time.Time      `yaml:"startedAt" json:"startedAt"`
+	UpdatedAt     time.Time      `yaml:"updatedAt" json:"updatedAt"`
+	Stages        []rolloutStage `yaml:"stages" json:"stages"`
+}
+
+// Finished reports whether all stages are done.
+func (s rolloutState) Finished() bool {
+	return !slices.ContainsFunc(s.Stages, func(stage rolloutStage) bool { return stage.Status != StageDone })
+}
+
+// Status returns the status of the rollout: the status of the current stage, or done.
+func (s rolloutState) Status() string {
+	for _, stage := range s.Stages {
+		if stage.Status != StageDone {
+			return stage.Clustergroup + " " + stage.Status
+		}
+	}
+	return StageDone
+}
+
+// loadRollout reads the state of a rollout.
+func loadRollout(config ovhwrapper.Configuration, name string) (*rolloutState, error) {
+	var state rolloutState
+	statefile := filepath.Join(stateDir(config, "ovhctl-rollouts"), name+".yaml")
+	if err := ovhwrapper.LoadYaml(&state, statefile); errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("rollout %s not found", name)
+	} else if err != nil {
+		return nil, fmt.Errorf("reading state of rollout %s: %w", name, err)
+	}
+	return &state, nil
+}
+
+// save writes the state of the rollout, failing to do so is fatal as the rollout could not be resumed.
+func (s *rolloutState) save(config ovhwrapper.Configuration) {
+	s.UpdatedAt = time.Now()
+	statefile := filepath.Join(stateDir(config, "ovhctl-rollouts"), s.Rollout.Name+".yaml")
+	if err := ovhwrapper.SaveYaml(s, statefile); err != nil {
+		log.Fatalf("Failed to save state of rollout %s to %s: %v", s.Rollout.Name, statefile, err)
+	}
+}
+
+// RunRollout starts the rollout of the definition file. A rollout of the same name can only be started again
+// once it is finished, otherwise it has to be resumed.
+func RunRollout(ctx context.Context, reader, writer ovhwrapper.API, config ovhwrapper.Configuration, file, inventory string, force, skipPreflight bool) {
+	var def rolloutDefinition
+	if err := ovhwrapper.LoadYaml(&def, file); err != nil {
+		log.Fatalf("Failed to read rollout %s: %v", file, err)
+	}
+	// the rollout may be resumed from another directory
+	inventory, err := filepath.Abs(inventoryPath(config, inventory))
+	if err != nil {
+		log.Fatalf("%v", err)
+	}
+	inv := readInventory(ctx, reader, config, inventory)
+	if err := def.Validate(inv); err != nil {
+		log.Fatalf("%v", err)
+	}
+
+	if state, err := loadRollout(config, def.Name); err == nil && !state.Finished() {
+		log.Fatalf("Rollout %s is not finished yet (%s), see 'ovhctl rollout status -n %s' and "+
+			"continue it with 'ovhctl rollout resume -n %s'", def.Name, state.Status(), def.Name, def.Name)
+	}
+
+	state := &rolloutState{
+		Rollout:       def,
+		Inventory:     inventory,
+		Force:         force,
+		SkipPreflight: skipPreflight,
+		StartedAt:     time.Now(),
+	}
+	for _, cg := range def.Stages {
+		state.Stages = append(state.Stages, rolloutStage{Clustergroup: cg, Status: StagePending})
+	}
+	state.save(config)
+
+	fmt.Printf("Starting rollout %s: %s\n", def.Name, strings.Join(def.Stages, " -> "))
+	if !runRollout(ctx, reader, writer, config, state) {
+		os.Exit(1)
+	}
+}
+
+// ResumeRollout continues a stopped rollout. A stage interrupted during its update continues its update run; a
+// failed stage gets the result of its update and its health gates checked again.
+func ResumeRollout(ctx context.Context, reader, writer ovhwrapper.API, config ovhwrapper.Configuration, name string) {
+	state, err := loadRollout(config, name)
+	if err != nil {
+		log.Fatalf("%v", err)
+	}
+	if state.Finished() {
+		fmt.Printf("Rollout %s is already finished\n", name)
+		return
+	}
+	fmt.Printf("Resuming rollout %s at %s\n", name, state.Status())
+	if !runRollout(ctx, reader, writer, config, state) {
+		os.Exit(1)
+	}
+}
+
+// runRollout runs the stages of the rollout which are not done yet. It returns false if not all clusters of a
+// stage have been updated, a stage failed its health gates or the rollout got interrupted.
+func runRollout(ctx context.Context, reader, writer ovhwrapper.API, config ovhwrapper.Configuration, state *rolloutState) bool {
+	def := state.Rollout
+	for i := range state.Stages {
+		stage := &state.Stages[i]
+		last := i == len(state.Stages)-1
+
+		switch stage.Status {
+		case StageDone:
+			continue
+		case StagePending:
+			fmt.Printf("\nStage %d of %d: updating clustergroup %s\n", i+1, len(state.Stages), stage.Clustergroup)
+			run := startGroupUpdate(ctx, reader, config, stage.Clustergroup, state.Inventory, def.Latest, state.Force, state.SkipPreflight,
+				def.Gates.maxEtcdPercent(), updateWait)
+			stage.Status, stage.StartedAt, stage.UpdateRun = StageUpdating, time.Now(), run.ID
+			state.save(config)
+			runGroupUpdate(ctx, reader, writer, config, run)
+			if !updateFinished(ctx, config, state, stage, run) {
+				return false
+			}
+		case StageUpdating, StageFailed:
+			fmt.Printf("\nStage %d of %d: continuing update run %s of clustergroup %s\n", i+1, len(state.Stages),
+				stage.UpdateRun, stage.Clustergroup)
+			run, err := loadGroupUpdateRun(config, stage.UpdateRun)
+			if err != nil {
+				log.Printf("Rollout %s: %v", def.Name, err)
+				return false
+			}
+			if !run.Finished() {
+				runGroupUpdate(ctx, reader, writer, config, run)
+			}
+			if stage.Status == StageFailed {
+				// the clusters may have been updated by hand since, as the failure message asks for
+				recheckUpdate(ctx, reader, run)
+			}
+			if !updateFinished(ctx, config, state, stage, run) {
+				return false
+			}
+		}
+
+		// the soak starts once, a stage which failed its health gates after soaking is only checked again
+		if !last && (stage.Status == StageSoaking || stage.SoakUntil.IsZero()) {
+			if stage.SoakUntil.IsZero() {
+				// a stage which is already broken after the update is not soaked
+				if def.Soak > 0 && !checkStage(ctx, reader, config, state, stage) {
+					return false
+				}
+				stage.SoakUntil = time.Now().Add(def.Soak)
+			}
+			stage.Status = StageSoaking
+			state.save(config)
+			if wait := time.Until(stage.SoakUntil); wait > 0 {
+				fmt.Printf("Soaking clustergroup %s until %s\n", stage.Clustergroup, stage.SoakUntil.Format(time.RFC1123Z))
+				if !sleepContext(ctx, wait) {
+					log.Printf("Rollout %s interrupted while soaking %s, resume it with 'ovhctl rollout resume -n %s'",
+						def.Name, stage.Clustergroup, def.Name)
+					return false
+				}
+			}
+		}
+		if !checkStage(ctx, reader, config, state, stage) {
+			return false
+		}
+		finishStage(config, state, stage)
+	}
+	fmt.Printf("\nRollout %s finished\n", def.Name)
+	return true
+}
+
+// updateFinished reports whether the update run of the stage is over and updated all of its clusters. It returns
+// false if the rollout got interrupted in the meantime or updateSucceeded failed the stage.
+func updateFinished(ctx context.Context, config ovhwrapper.Configuration, state *rolloutState, stage *rolloutStage, run *groupUpdateRun) bool {
+	if ctx.Err() != nil || !run.Finished() {
+		log.Printf("Rollout %s interrupted while updating %s, resume it with 'ovhctl rollout resume -n %s'",
+			state.Rollout.Name, stage.Clustergroup, state.Rollout.Name)
+		return false
+	}
+	return updateSucceeded(config, state, stage, run)
+}
+
+// recheckUpdate marks the clusters of the run which have not been updated as done if they are READY and up to
+// date by now, with latest set also on the latest version.
+func recheckUpdate(ctx context.Context, reader ovhwrapper.API, run *groupUpdateRun) {
+	for i, c := range run.Clusters {
+		if c.Status == ClusterUpdateDone || c.ClusterID == "" {
+			continue
+		}
+		cluster, err := ovhwrapper.GetK8SCluster(ctx, reader, c.ServicelineID, c.ClusterID)
+		if err != nil {
+			log.Printf("Failed to get cluster %s: %s", c.Cluster, explainError(err))
+			continue
+		}
+		if cluster.Status != "READY" || !cluster.IsUpToDate || (run.Latest && len(cluster.NextUpgradeVersions) > 0) {
+			continue
+		}
+		run.set(i, func(c *groupUpdateCluster) {
+			c.Status, c.FinishedAt = ClusterUpdateDone, time.Now()
+			c.Result = fmt.Sprintf("up to date with version %s after the update run", cluster.Version)
+		})
+	}
+}
+
+// updateSucceeded checks that the update run of the stage updated all of its clusters, otherwise the stage is
+// saved as failed: a cluster which has not been updated may still pass the health gates.
+func updateSucceeded(config ovhwrapper.Configuration, state *rolloutState, stage *rolloutStage, run *groupUpdateRun) bool {
+	stage.Failures = nil
+	for _, c := range run.Clusters {
+		if c.Status != ClusterUpdateDone {
+			stage.Failures = append(stage.Failures, fmt.Sprintf("%s: update %s, %s", c.Cluster, c.Status, c.Result))
+		}
+	}
+	if len(stage.Failures) == 0 {
+		return true
+	}
+	stage.Status = StageFailed
+	state.save(config)
+	fmt.Printf("Rollout %s stopped, not all clusters of clustergroup %s have been updated (update run %s):\n",
+		state.Rollout.Name, stage.Clustergroup, run.ID)
+	for _, failure := range stage.Failures {
+		fmt.Printf("  %s\n", failure)
+	}
+	fmt.Printf("Update the clusters and continue with 'ovhctl rollout resume -n %s'\n", state.Rollout.Name)
+	return false
+}
+
+// checkStage checks the health gates of the stage, a failing stage is saved as failed.
+func checkStage(ctx context.Context, reader ovhwrapper.API, config ovhwrapper.Configuration, state *rolloutState, stage *rolloutStage) bool {
+	fmt.Printf("Checking health gates of clustergroup %s\n", stage.Clustergroup)
+	inv := readInventory(ctx, reader, config, state.Inventory)
+	stage.Failures = checkGates(ctx, reader, inv, stage.Clustergroup, state.Rollout.Gates)
+	if len(stage.Failures) == 0 {
+		return true
+	}
+	stage.Status = StageFailed
+	state.save(config)
+	fmt.Printf("Rollout %s stopped, clustergroup %s failed the health gates:\n", state.Rollout.Name, stage.Clustergroup)
+	for _, failure := range stage.Failures {
+		fmt.Printf("  %s\n", failure)
+	}
+	fmt.Printf("Fix the clusters and continue with 'ovhctl rollout resume -n %s'\n", state.Rollout.Name)
+	return false
+}
+
+// finishStage marks the stage as done.
+func finishStage(config ovhwrapper.Configuration, state *rolloutState, stage *rolloutStage) {
+	stage.Status = StageDone
+	stage.FinishedAt = time.Now()
+	state.save(config)
+	fmt.Printf("Clustergroup %s passed the health gates\n", stage.Clustergroup)
+}
+
+// groupCluster is a cluster of a clustergroup with its serviceline.
+type groupCluster struct {
+	sl *ovhwrapper.ServiceLine
+	cl *ovhwrapper.K8SCluster
+}
+
+// clustergroupClusters resolves the clusters of a clustergroup of the inventory. Clusters which can not be
+// resolved are returned as errors.
+func clustergroupClusters(inv Inventory, clustergroup string) ([]groupCluster, []error) {
+	var clusters []groupCluster
+	var errs []error
+	resolver := ovhwrapper.NewResolver(GlobalInventory)
+	for _, cg := range inv.Clustergroups {
+		if cg.Name != clustergroup {
+			continue
+		}
+		for _, project := range cg.Projects {
+			for _, clustername := range project.Clusters {
+				sl, cl, err := resolver.Cluster(project.Name, clustername)
+				if err != nil {
+					errs = append(errs, err)
+					continue
+				}
+				clusters = append(clusters, groupCluster{sl: sl, cl: cl})
+			}
+		}
+	}
+	return clusters, errs
+}
+
+// checkGates checks the health gates for every cluster of the clustergroup and returns the failures.
+func checkGates(ctx context.Context, reader ovhwrapper.API, inv Inventory, clustergroup string, gates rolloutGates) []string {
+	var failures []string
+	clusters, errs := clustergroupClusters(inv, clustergroup)
+	for _, err := range errs {
+		failures = append(failures, err.Error())
+	}
+
+	for _, c := range clusters {
+		cluster, err := ovhwrapper.GetK8SCluster(ctx, reader, c.sl.ID, c.cl.ID)
+		if err == nil {
+			cluster, err = ovhwrapper.GetK8SClusterDetails(ctx, reader, cluster, c.sl.ID, c.cl.ID)
+		}
+		if err != nil {
+			failures = append(failures, fmt.Sprintf("%s: failed to get cluster: %v", c.cl.Name, err))
+			continue
+		}
+
+		if gates.Ready && cluster.Status != "READY" {
+			failures = append(failures, fmt.Sprintf("%s: cluster is %s", cluster.Name, cluster.Status))
+		}
+		if gates.NodesUpToDate {
+			for _, node := range cluster.Nodes {
+				if !node.IsUpToDate {
+					failures = append(failures, fmt.Sprintf("%s: node %s is not up to date (version %s)",
+						cluster.Name, node.Name, node.Version))
+				}
+			}
+		}
+		if gates.MaxEtcdPercent > 0 && cluster.EtcdUsage.Percent() > gates.MaxEtcdPercent {
+			failures = append(failures, fmt.Sprintf("%s: etcd usage %d%% is above %d%%", cluster.Name,
+				cluster.EtcdUsage.Percent(), gates.MaxEtcdPercent))
+		}
+	}
+	return failures
+}
+
+// RolloutStatus shows the progress of a rollout, or lists all rollouts if no name is given.
+func RolloutStatus(config ovhwrapper.Configuration, name, output string) {
+	if name == "" {
+		files, _ := filepath.Glob(filepath.Join(stateDir(config, "ovhctl-rollouts"), "*.yaml"))
+		if len(files) == 0 {
+			fmt.Println("No rollouts found")
+			return
+		}
+		fmt.Printf("%-30s %-25s %-25s %s\n", "ROLLOUT", "STARTED", "UPDATED", "STATUS")
+		for _, file := range files {
+			state, err := loadRollout(config, strings.TrimSuffix(filepath.Base(file), ".yaml"))
+			if err != nil {
+				log.Printf("%v", err)
+				continue
+			}
+			fmt.Printf("%-30s %-25s %-25s %s\n", state.Rollout.Name, state.StartedAt.Format(time.DateTime),
+				state.UpdatedAt.Format(time.DateTime), state.Status())
+		}
+		return
+	}
+
+	state, err := loadRollout(config, name)
+	if err != nil {
+		log.Fatalf("%v", err)
+	}
+	switch output {
+	case "yaml":
+		fmt.Println(ovhwrapper.ToYaml(state))
+	case "json":
+		fmt.Println(ovhwrapper.ToJSON(state))
+	case "text":
+		fallthrough
+	default:
+		fmt.Printf("Rollout: %s\nStarted: %s\nUpdated: %s\nSoak:    %s\nStatus:  %s\n\n", state.Rollout.Name,
+			state.StartedAt.Format(time.RFC1123Z), state.UpdatedAt.Format(time.RFC1123Z), state.Rollout.Soak,
+			state.Status())
+		for i, stage := range state.Stages {
+			line := fmt.Sprintf("  %d. %-20s %-9s", i+1, stage.Clustergroup, stage.Status)
+			switch {
+			case stage.Status == StageSoaking:
+				line += " until " + stage.SoakUntil.Format(time.DateTime)
+			case !stage.FinishedAt.IsZero():
+				line += " finished " + stage.FinishedAt.Format(time.DateTime)
+			case !stage.StartedAt.IsZero():
+				line += " started " + stage.StartedAt.Format(time.DateTime)
+			}
+			fmt.Println(line)
+			for _, failure := range stage.Failures {
+				fmt.Printf("       %s\n", failure)
+			}
+		}
+	}
+}
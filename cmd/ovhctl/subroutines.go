@@ -1,7 +1,9 @@
 package main
 
 import (
+	"context"
 	"encoding/base64"
+	"errors"
 	"fmt"
 	"log"
 	"math/rand"
@@ -9,21 +11,26 @@ import (
 	"path"
 	"sort"
 	"strings"
-	"sync"
 	"time"
 
-	"github.com/ovh/go-ovh/ovh"
 	"github.com/snafuprinzip/ovhwrapper"
+	"github.com/snafuprinzip/ovhwrapper/inventory"
 	"gopkg.in/yaml.v3"
 )
 
 type Inventory struct {
-	Clustergroups []Clustergroup `yaml:"clustergroups"`
+	// named sets of networks, which are allowed to reach the api servers of the clustergroups using them
+	IPRestrictions map[string][]string `yaml:"ipRestrictions,omitempty"`
+	Clustergroups  []Clustergroup      `yaml:"clustergroups"`
 }
 
 type Clustergroup struct {
-	Name     string      `yaml:"name"`
-	Projects []CGProject `yaml:"servicelines"`
+	Name string `yaml:"name"`
+	// clusters of protected clustergroups like prod can not be deleted with ovhctl
+	Protected bool `yaml:"protected,omitempty"`
+	// names of the ip restriction sets of the inventory applied to the clusters of the group
+	IPRestrictions []string    `yaml:"ipRestrictions,omitempty"`
+	Projects       []CGProject `yaml:"servicelines"`
 }
 
 type CGProject struct {
@@ -35,139 +42,149 @@ type CGProject struct {
 
 var Flavors ovhwrapper.K8SFlavors
 
-func GatherGlobalInventory(client *ovh.Client) {
-	GlobalInventory = []ovhwrapper.ServiceLine{}
-	projectIDs := ovhwrapper.GetServicelines(client)
+// ErrNoInventory is returned by findInventory if no inventory file is configured or found.
+var ErrNoInventory = errors.New("no inventory file found, please specify one with the -i flag")
 
-	projectChannel := make(chan ovhwrapper.ServiceLine)
-	for _, projectID := range projectIDs {
-		go GatherServiceline(client, projectID, projectChannel)
+// LoadInventory fills GlobalInventory and Flavors from the inventory cache. A new snapshot is gathered and
+// cached if the cache is outdated or refresh is set. With offline set only the last snapshot is used.
+func LoadInventory(ctx context.Context, client ovhwrapper.API, config ovhwrapper.Configuration, refresh, offline bool) error {
+	if refresh && offline {
+		return fmt.Errorf("--refresh and --offline cannot be used together")
 	}
-	for len(GlobalInventory) < len(projectIDs) {
-		GlobalInventory = append(GlobalInventory, <-projectChannel)
-	}
-}
 
-func GatherServiceline(client *ovh.Client, projectID string, projectChan chan<- ovhwrapper.ServiceLine) {
-	detailChan := make(chan ovhwrapper.OVHServiceLine)
-	clustersChan := make(chan []ovhwrapper.K8SCluster)
+	cache := inventoryCache(config)
+	endpoint := config.ReaderEndpoint()
 
-	go func(detailChan chan<- ovhwrapper.OVHServiceLine) {
-		servicedetails, err := ovhwrapper.GetServicelineDetails(client, projectID)
-		if err != nil {
-			log.Printf("Failed to get serviceline details: %v", err)
-			detailChan <- ovhwrapper.OVHServiceLine{}
-		} else {
-			detailChan <- servicedetails
+	if !refresh {
+		snapshot, err := cache.Load()
+		switch {
+		case err == nil && (offline || cache.Fresh(snapshot, endpoint)):
+			if verbose || offline {
+				log.Printf("Using inventory snapshot from %s (%s old)\n",
+					snapshot.Created.Format(time.DateTime), snapshot.Age().Round(time.Second))
+			}
+			GlobalInventory = snapshot.Servicelines
+			Flavors = snapshot.Flavors
+			return nil
+		case offline:
+			return fmt.Errorf("no inventory snapshot available for offline use: %w", err)
+		case err != nil && !errors.Is(err, inventory.ErrNoSnapshot):
+			log.Printf("Ignoring inventory cache: %v\n", err)
 		}
-	}(detailChan)
-
-	clusterids, err := ovhwrapper.GetK8SClusterIDs(client, projectID)
-	if err != nil {
-		log.Fatalf("Failed to get cluster IDs: %v", err)
 	}
 
-	go GatherClusters(client, projectID, clusterids, clustersChan)
-
-	serviceline := ovhwrapper.ServiceLine{
-		ID:        projectID,
-		SLDetails: <-detailChan,
-		Cluster:   <-clustersChan,
+	complete := GatherGlobalInventory(ctx, client, config.Concurrency)
+	if slid, clid, ok := firstCluster(); ok {
+		var err error
+		Flavors, err = ovhwrapper.GetK8SFlavors(ctx, client, slid, clid)
+		if err != nil {
+			log.Printf("Error getting available flavors: %s\n", explainError(err))
+			complete = false
+		}
 	}
 
-	projectChan <- serviceline
-}
-
-func GatherClusters(client *ovh.Client, projectID string, clusterids []string, clustersChan chan<- []ovhwrapper.K8SCluster) {
-	var clusters []ovhwrapper.K8SCluster
-	clusterChan := make(chan ovhwrapper.K8SCluster)
-
-	for _, clusterID := range clusterids {
-		go func(projectID string, clusterID string, clusterChan chan<- ovhwrapper.K8SCluster) {
-			GatherCluster(client, projectID, clusterID, clusterChan)
-		}(projectID, clusterID, clusterChan)
+	// an incomplete inventory is not cached, so the missing resources are gathered again next time
+	if complete {
+		snapshot := &inventory.Snapshot{
+			Created:      time.Now(),
+			Endpoint:     endpoint,
+			Servicelines: GlobalInventory,
+			Flavors:      Flavors,
+		}
+		if err := cache.Save(snapshot); err != nil {
+			log.Printf("Error saving inventory cache: %v\n", err)
+		}
 	}
+	return nil
+}
 
-	for range len(clusterids) {
-		cluster := <-clusterChan
-		clusters = append(clusters, cluster)
+// refreshInventory gathers a new inventory snapshot after a change, so the following commands know about it.
+func refreshInventory(ctx context.Context, client ovhwrapper.API, config ovhwrapper.Configuration) {
+	if err := LoadInventory(ctx, client, config, true, false); err != nil {
+		log.Printf("Failed to refresh the inventory: %v", err)
 	}
-	close(clusterChan)
-	clustersChan <- clusters
 }
 
-func GatherCluster(client *ovh.Client, projectID string, clusterID string, clusterChan chan<- ovhwrapper.K8SCluster) {
-	etcdChan := make(chan ovhwrapper.K8SEtcd)
-	nodesChan := make(chan []ovhwrapper.K8SNode)
-	nodepoolsChan := make(chan []ovhwrapper.K8SNodepool)
-
-	cluster := ovhwrapper.GetK8SCluster(client, projectID, clusterID)
-	//fmt.Println(clusterID, cluster.ID, cluster.Name)
-
-	go GatherEtcd(client, projectID, clusterID, etcdChan)
-	go GatherNodes(client, projectID, clusterID, nodesChan)
-	go GatherNodepools(client, projectID, clusterID, nodepoolsChan)
-
-	semaphore := 0
-	for {
-		select {
-		case cluster.EtcdUsage = <-etcdChan:
-			semaphore++
-		case cluster.Nodes = <-nodesChan:
-			semaphore++
-		case cluster.Nodepools = <-nodepoolsChan:
-			semaphore++
-		}
-		if semaphore == 3 {
-			break
+// inventoryCache returns the cache of the inventory snapshots as configured
+func inventoryCache(config ovhwrapper.Configuration) inventory.Cache {
+	cache := inventory.Cache{Path: config.Cache.Path, TTL: config.Cache.TTL}
+	if cache.Path == "" {
+		name := "ovhctl-inventory"
+		if config.ProfileName() != ovhwrapper.DefaultProfileName {
+			// every profile is a different account, so they must not share a snapshot
+			name += "-" + config.ProfileName()
 		}
+		cache.Path = inventory.DefaultCachePath(name)
 	}
+	return cache
+}
 
-	clusterChan <- *cluster
+// GatherGlobalInventory collects all servicelines including their clusters into GlobalInventory.
+// Resources which could not be gathered are logged and left out, only a failing serviceline list is fatal.
+// It returns false if the inventory is incomplete.
+func GatherGlobalInventory(ctx context.Context, client ovhwrapper.API, concurrency int) bool {
+	gatherer := inventory.New(client, inventory.Options{Concurrency: concurrency, Clusters: true})
+	servicelines, err := gatherer.Gather(ctx)
+	if errs, ok := inventory.AsErrors(err); ok {
+		for _, resErr := range errs {
+			log.Printf("Failed to gather %s", explainError(resErr))
+		}
+	} else if err != nil {
+		log.Fatalf("Failed to get servicelines: %s", explainError(err))
+	}
+	GlobalInventory = servicelines
+	return err == nil
 }
 
-func GatherEtcd(client *ovh.Client, projectID string, clusterID string, etcdChan chan<- ovhwrapper.K8SEtcd) {
-	var etcd ovhwrapper.K8SEtcd
-	etcd, err := ovhwrapper.GetK8SEtcd(client, projectID, clusterID)
-	if err != nil {
-		log.Printf("Error getting etcd usage: %q\n", err)
-		etcdChan <- ovhwrapper.K8SEtcd{}
-		return
+// firstCluster returns the serviceline and cluster id of the first cluster in the inventory, used for
+// requests which are the same for all clusters like the list of flavors
+func firstCluster() (string, string, bool) {
+	for _, sl := range GlobalInventory {
+		for _, cl := range sl.Cluster {
+			if cl.Name != "" {
+				return sl.ID, cl.ID, true
+			}
+		}
 	}
-	etcdChan <- etcd
+	return "", "", false
 }
 
-func GatherNodes(client *ovh.Client, projectID string, clusterID string, nodesChan chan<- []ovhwrapper.K8SNode) {
-	var nodes []ovhwrapper.K8SNode
-	nodes, err := ovhwrapper.GetK8SNodes(client, projectID, clusterID)
-	if err != nil {
-		log.Printf("Error getting nodes: %q\n", err)
-		nodesChan <- []ovhwrapper.K8SNode{}
-		return
+// CredentialSources shows the source of the credential values of the active profile, the values itself
+// are never printed.
+func CredentialSources(config ovhwrapper.Configuration, format string) {
+	switch format {
+	case "yaml":
+		fmt.Println(ovhwrapper.ToYaml(config.CredentialSources()))
+	case "json":
+		fmt.Println(ovhwrapper.ToJSON(config.CredentialSources()))
+	case "text":
+		fallthrough
+	default:
+		fmt.Printf("Profile: %s\n\n", config.ProfileName())
+		for _, source := range config.CredentialSources() {
+			fmt.Printf("%-7s %-19s %-19s %s\n", source.Role, source.Name, source.Source, source.Detail)
+		}
 	}
-	nodesChan <- nodes
 }
 
-func GatherNodepools(client *ovh.Client, projectID string, clusterID string, nodepoolsChan chan<- []ovhwrapper.K8SNodepool) {
-	var nodepools []ovhwrapper.K8SNodepool
-	nodepools, err := ovhwrapper.GetK8SNodepools(client, projectID, clusterID)
+// MigrateSecrets moves the plaintext credentials of the configuration file into a secret store.
+func MigrateSecrets(config ovhwrapper.Configuration, store string) {
+	migrated, err := config.MigrateSecrets(store)
 	if err != nil {
-		log.Printf("Error getting nodepools: %q\n", err)
-		nodepoolsChan <- []ovhwrapper.K8SNodepool{}
-		return
+		log.Fatalf("Error migrating credentials after %d values: %v", migrated, err)
 	}
-	nodepoolsChan <- nodepools
+	fmt.Printf("Moved %d credentials of %s into the %s store\n", migrated, config.GetPath(), store)
 }
 
 // Credentials returns information about the reader and writer accounts in different formats (yaml, json or text)
-func Credentials(reader, writer *ovh.Client, format string) {
-	rcred, err := ovhwrapper.GetCredential(reader)
+func Credentials(ctx context.Context, reader, writer ovhwrapper.API, format string) {
+	rcred, err := ovhwrapper.GetCredential(ctx, reader)
 	if err != nil {
-		log.Printf("Error getting reader credentials: %q\n", err)
+		log.Printf("Error getting reader credentials: %s\n", explainError(err))
 	}
-	wcred, err := ovhwrapper.GetCredential(writer)
+	wcred, err := ovhwrapper.GetCredential(ctx, writer)
 	if err != nil {
-		log.Printf("Error getting writer credentials: %q\n", err)
+		log.Printf("Error getting writer credentials: %s\n", explainError(err))
 	}
 
 	switch format {
@@ -196,7 +213,7 @@ func Credentials(reader, writer *ovh.Client, format string) {
 
 // List lists servicelines and their clusters (when -a is set),
 // servicelines (when -s is not set) or clusters (when -s is set)
-func List(client *ovh.Client, all bool, serviceid string) {
+func List(ctx context.Context, client ovhwrapper.API, all bool, serviceid string) {
 	if all { // list all servicelines and their clusters
 		for _, sl := range GlobalInventory {
 			fmt.Printf("%-25s (%s) \t %s \n", ovhwrapper.ShortenName(sl.SLDetails.Description), sl.ID, sl.SLDetails.Description)
@@ -206,18 +223,9 @@ func List(client *ovh.Client, all bool, serviceid string) {
 			fmt.Println()
 		}
 	} else if serviceid != "" { // show clusters for a specific serviceline
-		var sl ovhwrapper.ServiceLine
-
-		for _, s := range GlobalInventory {
-			if MatchItem(s, serviceid) {
-				sl = s
-				break
-			}
-		}
-
-		if sl.ID == "" {
-			log.Printf("Service ID not found: %s", serviceid)
-			return
+		sl, err := ovhwrapper.NewResolver(GlobalInventory).Serviceline(serviceid)
+		if err != nil {
+			log.Fatalf("%s\n", explainError(err))
 		}
 
 		fmt.Printf("%-25s (%s) \t %s \n", ovhwrapper.ShortenName(sl.SLDetails.Description), sl.ID, sl.SLDetails.Description)
@@ -232,14 +240,31 @@ func List(client *ovh.Client, all bool, serviceid string) {
 	}
 }
 
-func GetKubeConfig(reader, writer *ovh.Client, projectID string, clusterID, output, outpath string,
-	globalconfig *ovhwrapper.KubeConfig) {
+func GetKubeConfig(ctx context.Context, reader, writer ovhwrapper.API, projectID string, clusterID, output, outpath string,
+	oidc bool, globalconfig *ovhwrapper.KubeConfig) {
 
-	kc, err := ovhwrapper.GetKubeconfig(writer, projectID, clusterID)
+	// with oidc the users log in at the OpenID Connect provider instead of using the admin client certificate
+	var oidcConfig *ovhwrapper.K8SOpenIDConnect
+	if oidc {
+		var err error
+		oidcConfig, err = ovhwrapper.GetK8SOpenIDConnect(ctx, reader, projectID, clusterID)
+		if errors.Is(err, ovhwrapper.ErrNotFound) {
+			log.Printf("Cluster %s has no OpenID Connect configuration, see 'ovhctl cluster oidc set'", clusterID)
+			return
+		} else if err != nil {
+			log.Printf("Failed to get OpenID Connect configuration: %s", explainError(err))
+			return
+		}
+	}
+
+	kc, err := ovhwrapper.GetKubeconfig(ctx, writer, projectID, clusterID)
 	if err != nil {
-		log.Printf("Failed to get kubeconfig: %s", err)
+		log.Printf("Failed to get kubeconfig: %s", explainError(err))
 		return
 	}
+	if oidcConfig != nil {
+		kc.UseOIDC(*oidcConfig)
+	}
 
 	for _, project := range GlobalInventory {
 		if project.ID == projectID {
@@ -305,9 +330,11 @@ func GetKubeConfig(reader, writer *ovh.Client, projectID string, clusterID, outp
 	}
 }
 
-func DownloadKubeconfig(reader, writer *ovh.Client, all bool, serviceid, clusterid, output, outpath string) {
-	var sls []ovhwrapper.ServiceLine
+func DownloadKubeconfig(ctx context.Context, reader, writer ovhwrapper.API, all bool, serviceid, clusterid, output, outpath string, oidc bool) {
 	var err error
+	if oidc && output == "certs" {
+		log.Fatalf("There are no client certificates in an OpenID Connect kubeconfig, use the file or global output")
+	}
 	globalconfig := ovhwrapper.KubeConfig{
 		APIVersion: "v1",
 		Kind:       "Config",
@@ -321,19 +348,16 @@ func DownloadKubeconfig(reader, writer *ovh.Client, all bool, serviceid, cluster
 		for _, sl := range GlobalInventory {
 			fmt.Println("Processing Serviceline: ", sl.SLDetails.Description)
 			for _, cl := range sl.Cluster {
-				GetKubeConfig(reader, writer, sl.ID, cl.ID, output, outpath, &globalconfig)
+				GetKubeConfig(ctx, reader, writer, sl.ID, cl.ID, output, outpath, oidc, &globalconfig)
 			}
 		}
 	} else if serviceid != "" && clusterid != "" {
-		for _, sl := range sls {
-			if MatchItem(sl, serviceid) {
-				for _, cl := range sl.Cluster {
-					if MatchItem(cl, clusterid) {
-						GetKubeConfig(reader, writer, sl.ID, cl.ID, output, outpath, &globalconfig)
-					}
-				}
-			}
+		sl, cl, err := ovhwrapper.NewResolver(GlobalInventory).Cluster(serviceid, clusterid)
+		if err != nil {
+			log.Printf("%s\n", explainError(err))
+			return
 		}
+		GetKubeConfig(ctx, reader, writer, sl.ID, cl.ID, output, outpath, oidc, &globalconfig)
 	} else {
 		log.Printf("no service id/name or cluster id/name given\n")
 	}
@@ -349,14 +373,10 @@ func DownloadKubeconfig(reader, writer *ovh.Client, all bool, serviceid, cluster
 
 // Status shows the current status of servicelines and their clusters (when -a is set),
 // or a specific cluster (when -s and -c is set)
-func Status(client *ovh.Client, all bool, serviceline, cluster string) {
-	flavors, err := ovhwrapper.GetK8SFlavors(client, GlobalInventory[0].ID, GlobalInventory[0].Cluster[0].ID)
+func Status(ctx context.Context, client ovhwrapper.API, all bool, serviceline, cluster string) {
+	flavors := Flavors
 
 	if all {
-		if err != nil {
-			log.Printf("Error getting available flavors: %q\n", err)
-		}
-
 		for _, sl := range GlobalInventory {
 			fmt.Println(sl.StatusMsg())
 			for _, cl := range sl.Cluster {
@@ -367,123 +387,65 @@ func Status(client *ovh.Client, all bool, serviceline, cluster string) {
 				}
 				fmt.Println()
 			}
-			fmt.Println("-------------------\n")
+			fmt.Print("-------------------\n\n")
 		}
 		return
 	}
 
 	if serviceline != "" { // list serviceline and it's clusters
-		for _, service := range GlobalInventory {
-			if MatchItem(service, serviceline) {
-				fmt.Println(service.StatusMsg())
-				for _, cl := range service.Cluster {
-					if cluster == "" || MatchItem(cl, cluster) {
-						fmt.Println(cl.StatusMsg())
-						for _, n := range cl.Nodes {
-							f := flavors[n.Flavor]
-							fmt.Println(n.StatusMsg(f))
-						}
-						fmt.Println()
-					}
-				}
-			}
+		resolver := ovhwrapper.NewResolver(GlobalInventory)
+		sl, err := resolver.Serviceline(serviceline)
+		if err != nil {
+			log.Fatalf("%s\n", explainError(err))
 		}
-		return
-	}
-}
-
-// statusString returns the current status of a specific cluster
-func statusString(client *ovh.Client, serviceline, cluster string) string {
-	var sls []ovhwrapper.ServiceLine
-	var cl *ovhwrapper.K8SCluster
-	var s string
-	var realslid, realclid string
-	var err error
-
-	if serviceline != "" { // list serviceline and it's clusters
-		services := ovhwrapper.GetServicelines(client)
-		for _, service := range services {
-			sl := GetServiceline(client, service)
-			if MatchItem(*sl, serviceline) {
-				realslid = sl.ID
-				sl.SLDetails, err = ovhwrapper.GetServicelineDetails(client, sl.ID)
-				if err != nil {
-					break
-				}
-
-				clusterids, err := ovhwrapper.GetK8SClusterIDs(client, sl.ID)
-				if err != nil {
-					log.Fatalf("Failed to get cluster IDs: %v", err)
-				}
-
-				var clusterlist []ovhwrapper.K8SCluster
-
-				for _, clid := range clusterids {
-					if cluster != "" { // cluster id is given on the command line
-						cl = ovhwrapper.GetK8SCluster(client, sl.ID, clid)
-						if MatchItem(*cl, cluster) {
-							realclid = cl.ID
-							cl, err = ovhwrapper.GetK8SClusterDetails(client, cl, realslid, realclid)
-							if err == nil && cl != nil {
-								clusterlist = append(clusterlist, *cl)
-								break
-							}
-						}
-					} else { // all clusters
-						cl := CollectCluster(client, sl.ID, clid)
-						if cl != nil {
-							clusterlist = append(clusterlist, *cl)
-						}
-					}
-				}
-
-				sl.Cluster = clusterlist
-
-				sls = append(sls, *sl)
+		clusters := sl.Cluster
+		if cluster != "" {
+			_, cl, err := resolver.Cluster(sl.ID, cluster)
+			if err != nil {
+				log.Fatalf("%s\n", explainError(err))
 			}
+			clusters = []ovhwrapper.K8SCluster{*cl}
 		}
 
-		if len(sls) == 0 {
-			log.Printf("No servicelines found for the given identifier: %s\n", serviceline)
-			return ""
-		}
-
-		if len(sls[0].Cluster) == 0 {
-			log.Printf("No clusters found for the given identifier: %s\n", cluster)
-			return ""
-		}
-
-		flavors, err := ovhwrapper.GetK8SFlavors(client, sls[0].ID, sls[0].Cluster[0].ID)
-		if err != nil {
-			log.Printf("Error getting available flavors: %q\n", err)
-			return ""
-		}
-
-		for _, sl := range sls {
-			if MatchItem(sl, serviceline) {
-				s += sl.StatusMsg() + "\n"
-				for _, cl := range sl.Cluster {
-					if cluster == "" || MatchItem(cl, cluster) {
-						s += cl.StatusMsg() + "\n"
-						for _, n := range cl.Nodes {
-							f := flavors[n.Flavor]
-							s += n.StatusMsg(f) + "\n"
-						}
-						s += "\n"
-						return s
-					}
-				}
+		fmt.Println(sl.StatusMsg())
+		for _, cl := range clusters {
+			fmt.Println(cl.StatusMsg())
+			for _, n := range cl.Nodes {
+				f := flavors[n.Flavor]
+				fmt.Println(n.StatusMsg(f))
 			}
+			fmt.Println()
 		}
+		return
+	}
+}
+
+// statusString returns the current status of a specific cluster, freshly fetched from the api
+func statusString(ctx context.Context, client ovhwrapper.API, slid, clid string) string {
+	cl, err := inventory.New(client, inventory.Options{}).GatherCluster(ctx, slid, clid)
+	if cl == nil {
+		log.Printf("Failed to get cluster %s: %s", clid, explainError(err))
 		return ""
 	}
-	return s
+	if err != nil {
+		log.Printf("Failed to get cluster details: %s", explainError(err))
+	}
+
+	var s string
+	if sl, err := ovhwrapper.NewResolver(GlobalInventory).Serviceline(slid); err == nil {
+		s += sl.StatusMsg() + "\n"
+	}
+	s += cl.StatusMsg() + "\n"
+	for _, n := range cl.Nodes {
+		s += n.StatusMsg(Flavors[n.Flavor]) + "\n"
+	}
+	return s + "\n"
 }
 
 // Describe shows the details of servicelines and their clusters (when only -a is set),
 // a serviceline (when -c is not set), a serviceline and all it's clusters (when -a is set as well)
 // or a specific cluster (when -s and -c is set, including serviceline if -a is set as well)
-func Describe(client *ovh.Client, all bool, serviceid, clusterid, output string) {
+func Describe(ctx context.Context, client ovhwrapper.API, all bool, serviceid, clusterid, output string) {
 	if serviceid == "" && clusterid == "" { // all servicelines and their clusters
 
 		// output
@@ -513,22 +475,13 @@ func Describe(client *ovh.Client, all bool, serviceid, clusterid, output string)
 					}
 					fmt.Println("\n-----")
 				}
-				fmt.Println("\n\n")
+				fmt.Print("\n\n\n")
 			}
 		}
 	} else if serviceid != "" && clusterid == "" { // show all clusters for a specific serviceline
-		var sl ovhwrapper.ServiceLine
-
-		for _, s := range GlobalInventory {
-			if MatchItem(s, serviceid) {
-				sl = s
-				break
-			}
-		}
-
-		if sl.ID == "" {
-			log.Printf("Service ID not found: %s", serviceid)
-			return
+		sl, err := ovhwrapper.NewResolver(GlobalInventory).Serviceline(serviceid)
+		if err != nil {
+			log.Fatalf("%s\n", explainError(err))
 		}
 
 		// output
@@ -560,26 +513,9 @@ func Describe(client *ovh.Client, all bool, serviceid, clusterid, output string)
 			//fmt.Println()
 		}
 	} else if serviceid != "" && clusterid != "" {
-		var sl ovhwrapper.ServiceLine
-
-		for _, s := range GlobalInventory {
-			if MatchItem(s, serviceid) {
-				sl = s
-				break
-			}
-		}
-
-		if sl.ID == "" {
-			log.Printf("Service ID not found: %s", serviceid)
-			return
-		}
-
-		var cluster *ovhwrapper.K8SCluster
-		for _, cl := range sl.Cluster {
-			if MatchItem(cl, clusterid) {
-				cluster = &cl
-				break
-			}
+		sl, cluster, err := ovhwrapper.NewResolver(GlobalInventory).Cluster(serviceid, clusterid)
+		if err != nil {
+			log.Fatalf("%s\n", explainError(err))
 		}
 
 		// output
@@ -599,11 +535,6 @@ func Describe(client *ovh.Client, all bool, serviceid, clusterid, output string)
 		case "text":
 			fallthrough
 		default:
-			if cluster == nil {
-				log.Printf("Cluster ID not found for service %s", serviceid)
-				return
-			}
-
 			if all {
 				fmt.Println(sl.Details())
 				fmt.Println()
@@ -631,7 +562,22 @@ func Describe(client *ovh.Client, all bool, serviceid, clusterid, output string)
 	}
 }
 
-func readInventory(reader *ovh.Client, config ovhwrapper.Configuration, inventory string) Inventory {
+// inventoryPath returns the path of the clustergroups inventory: the given path, the inventory of the profile,
+// ./clustergroups.yaml or /etc/k8s/clustergroups.yaml.
+func inventoryPath(config ovhwrapper.Configuration, inventory string) string {
+	inventory, err := findInventory(config, inventory)
+	if err != nil {
+		log.Fatalf("%v", err)
+	}
+	return inventory
+}
+
+// findInventory is like inventoryPath, but returns ErrNoInventory instead of exiting if there is
+// neither a configured nor a default inventory file.
+func findInventory(config ovhwrapper.Configuration, inventory string) (string, error) {
+	if inventory == "" {
+		inventory = config.Inventory
+	}
 	if inventory == "" {
 		// check if local inventory file "./clustergroups.yaml" exists
 		if fileExists("./clustergroups.yaml") {
@@ -639,13 +585,18 @@ func readInventory(reader *ovh.Client, config ovhwrapper.Configuration, inventor
 		} else if fileExists("/etc/k8s/clustergroups.yaml") {
 			inventory = "/etc/k8s/clustergroups.yaml"
 		} else {
-			log.Fatalf("No inventory file found. Please specify one with the -i flag.")
+			return "", ErrNoInventory
 		}
 	} else {
 		if !fileExists(inventory) {
-			log.Fatalf("Inventory file %s not found", inventory)
+			return "", fmt.Errorf("inventory file %s not found", inventory)
 		}
 	}
+	return inventory, nil
+}
+
+func readInventory(ctx context.Context, reader ovhwrapper.API, config ovhwrapper.Configuration, inventory string) Inventory {
+	inventory = inventoryPath(config, inventory)
 
 	// open inventory file
 	inventoryString, err := os.ReadFile(inventory)
@@ -662,134 +613,78 @@ func readInventory(reader *ovh.Client, config ovhwrapper.Configuration, inventor
 	return inv
 }
 
-func UpdateClusterGroup(reader, writer *ovh.Client, config ovhwrapper.Configuration, clustergroup, inventory string, latest, force bool) {
-	var wg sync.WaitGroup
-	var status chan string
+// UpdateClusterGroup updates all clusters of the clustergroup at once and returns the run with the state of each
+// cluster. The progress is recorded in the state file of the run, so it can be continued with ResumeGroupUpdate
+// if ovhctl dies in the meantime.
+func UpdateClusterGroup(ctx context.Context, reader, writer ovhwrapper.API, config ovhwrapper.Configuration, clustergroup, inventory string, latest, force, skipPreflight bool, maxEtcd int, wait waitOptions) *groupUpdateRun {
+	run := startGroupUpdate(ctx, reader, config, clustergroup, inventory, latest, force, skipPreflight, maxEtcd, wait)
+	runGroupUpdate(ctx, reader, writer, config, run)
+	return run
+}
 
+// startGroupUpdate creates and saves the update run of the clustergroup without updating any cluster yet.
+func startGroupUpdate(ctx context.Context, reader ovhwrapper.API, config ovhwrapper.Configuration, clustergroup, inventory string, latest, force, skipPreflight bool, maxEtcd int, wait waitOptions) *groupUpdateRun {
 	// read inventory file
-	inv := readInventory(reader, config, inventory)
+	inv := readInventory(ctx, reader, config, inventory)
 	fmt.Printf("%s\n", inv)
 
-	// count number of clusters to update
-	var count int
-	for _, cg := range inv.Clustergroups {
-		if cg.Name == clustergroup {
-			for _, project := range cg.Projects {
-				count += len(project.Clusters)
-			}
-			break
-		}
-	}
-
-	// create buffered channel
-	status = make(chan string, count)
-
-	fmt.Printf("Updating %d clusters in group %s\n", count, clustergroup)
-
-	for _, cg := range inv.Clustergroups {
-		// find selected cluster group
-		if cg.Name == clustergroup {
-			// every service line in cluster group
-			for _, project := range cg.Projects {
-				// every cluster in service line
-				for _, clustername := range project.Clusters {
-					realslid := ""
-					realclid := ""
-					slEmail := project.Email
-					slTeamsHook := project.TeamsWebhook
-
-					// determine project and cluster IDs
-					slids := ovhwrapper.GetServicelines(reader)
-					for _, slid := range slids {
-						details := ovhwrapper.GetOVHServiceline(reader, slid)
-						sl := ovhwrapper.ServiceLine{
-							ID:        slid,
-							SLDetails: *details,
-						}
-						if MatchItem(sl, project.Name) {
-							realslid = sl.ID
-							clids, err := ovhwrapper.GetK8SClusterIDs(reader, slid)
-							if err != nil {
-								fmt.Printf("Failed to get cluster IDs: %v\n", err)
-								continue
-							}
-
-							for _, clid := range clids {
-								cl := ovhwrapper.GetK8SCluster(reader, slid, clid)
-								if MatchItem(*cl, clustername) {
-									realclid = cl.ID
-								}
-							}
-						}
-					}
-
-					wg.Add(1)
-					go func(wg *sync.WaitGroup, sl, slid, cl, clid string) {
-						defer wg.Done()
-						fmt.Printf("Updating cluster %25s (%s) in serviceline %25s (%s)\n", cl, clid, sl, slid)
-						err := ovhwrapper.UpdateK8SCluster(writer, slid, clid, latest, force)
-						if err != nil {
-							log.Fatalf("Failed to initiate cluster update: %v", err)
-						}
-						res := CheckCronClusterUpdate(reader, writer, config, sl, slid, cl, clid, slEmail, slTeamsHook)
-						status <- res
-					}(&wg, project.Name, realslid, clustername, realclid)
-				} // cluster
-			} // project
-			break
-		} // cluster group
-	}
-	wg.Wait()
-	close(status)
-	fmt.Printf("\n%d clusters in group %s updated:\n\n", count, clustergroup)
-	for result := range status {
-		fmt.Println(result)
-	}
+	run := newGroupUpdateRun(config, inv, clustergroup, latest, force, skipPreflight, maxEtcd, wait)
+	run.save()
+	fmt.Printf("Updating %d clusters in group %s, update run %s\n", len(run.Clusters), clustergroup, run.ID)
+	return run
 }
 
-func CheckCronClusterUpdate(reader, writer *ovh.Client, config ovhwrapper.Configuration, sl, realslid, cl, realclid, email, teamshook string) string {
-	var curStatus, prevStatus string
+// CheckCronClusterUpdate waits for the update of a cluster of a clustergroup, logs its status to the update log
+// and sends the log as notification. A resumed update is appended to the log of the interrupted one, the
+// notification is only sent once the update is over. It returns the result of waiting and the last status of
+// the cluster.
+func CheckCronClusterUpdate(ctx context.Context, reader, writer ovhwrapper.API, config ovhwrapper.Configuration, sl, realslid, cl, realclid, email, teamshook, checklist string, wait waitOptions, resumed bool) (ovhwrapper.WaitResult, string) {
+	var curStatus string
 
-	logfile, err := os.OpenFile(path.Join("/var/log/k8s/updates", sl+"-"+cl+".log"), os.O_WRONLY|os.O_CREATE, 0660)
+	flags := os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	if resumed {
+		flags = os.O_WRONLY | os.O_CREATE | os.O_APPEND
+	}
+	logfile, err := os.OpenFile(path.Join("/var/log/k8s/updates", sl+"-"+cl+".log"), flags, 0660)
 	if err != nil {
 		log.Printf("Failed to open log file: %v", err)
 	}
 	defer logfile.Close()
 
-	fmt.Fprintf(logfile, "Update for %s started at %s...\n\n", cl, time.Now().Format(time.RFC1123Z))
-
-	// mock sleep to simulate a little bit of update time
-	time.Sleep(time.Second * time.Duration(rand.Intn(30)))
-
-	for {
-		client, err := ovhwrapper.CreateReader(config)
-		if err != nil {
-			log.Fatalf("Error creating OVH API Reader: %q\n", err)
-		}
-
-		cl := ovhwrapper.GetK8SCluster(client, realslid, realclid)
-		if cl != nil {
-			//fmt.Println("\033[2J")  // clear screen
-			curStatus = statusString(client, realslid, realclid)
-			// show status if status has changed since last check
-			if curStatus != prevStatus {
-				fmt.Fprintln(logfile, curStatus)
-				prevStatus = curStatus
-			}
-
-			// end update loop if cluster is in ready state
-			if cl.Status == "READY" {
-				break
-			}
-			time.Sleep(60 * time.Second)
-		}
+	if resumed {
+		fmt.Fprintf(logfile, "\nMonitoring of the update for %s resumed at %s...\n\n", cl, time.Now().Format(time.RFC1123Z))
+	} else {
+		fmt.Fprintf(logfile, "Update for %s started at %s...\n\n", cl, time.Now().Format(time.RFC1123Z))
+		fmt.Fprintf(logfile, "Pre-flight checks:\n%s\n", checklist)
 	}
-	fmt.Fprintf(logfile, "Update for %s finished at %s...\n", cl, time.Now().Format(time.RFC1123Z))
+
+	// give the update time to get triggered and spread the polling of the clusters of the group
+	delay := 10*time.Second + time.Second*time.Duration(rand.Intn(30))
+	result := watchCluster(ctx, reader, realslid, realclid, wait, delay, func(status string) {
+		fmt.Fprintln(logfile, status)
+		curStatus = status
+	})
+	fmt.Fprintf(logfile, "Update for %s %s, finished at %s...\n", cl, result, time.Now().Format(time.RFC1123Z))
 	err = logfile.Close()
 	if err != nil {
 		log.Printf("Failed to close log file: %v", err)
 	}
+	if result.Outcome == ovhwrapper.WaitCancelled {
+		log.Printf("Monitoring of %s aborted, the notification is sent when the update run is resumed\n", cl)
+		return result, curStatus
+	}
 
+	logtext, err := os.ReadFile(path.Join("/var/log/k8s/updates", sl+"-"+cl+".log"))
+	if err != nil {
+		log.Fatalf("Failed to read log file: %v", err)
+	}
+	notifyClusterUpdate(cl, email, teamshook, fmt.Sprintf("k8s Update: %s %s", cl, result.Outcome), string(logtext))
+	return result, curStatus
+}
+
+// notifyClusterUpdate sends a notification about the update of a cluster by mail to the operations team and the
+// email address of the serviceline and to the teams webhook of the serviceline.
+func notifyClusterUpdate(cl, email, teamshook, subject, body string) {
 	recipients := []string{"michael.leimenmeier@gfi.ihk.de", "SLAP.Application-Hosting-Operations@gfi.ihk.de"}
 	if email != "" {
 		recipients = append(recipients, email)
@@ -800,167 +695,85 @@ func CheckCronClusterUpdate(reader, writer *ovh.Client, config ovhwrapper.Config
 		teamshooks = append(teamshooks, teamshook)
 	}
 
-	logtext, err := os.ReadFile(path.Join("/var/log/k8s/updates", sl+"-"+cl+".log"))
+	log.Printf("Sending mail for %s to %s...\n", cl, strings.Join(recipients, ", "))
+	err := SendMail(subject, body, recipients)
 	if err != nil {
-		log.Fatalf("Failed to read log file: %v", err)
-	} else {
-		log.Printf("Sending mail for %s to %s...\n", cl, strings.Join(recipients, ", "))
-		err := SendMail("k8s Update: "+cl, string(logtext), recipients)
-		if err != nil {
-			log.Printf("Failed to send mail: %v", err)
-		}
-		err = TeamsNotify("k8s Update: "+cl, string(logtext), teamshooks)
-		if err != nil {
-			log.Printf("Failed to send teams notification: %v", err)
-		}
+		log.Printf("Failed to send mail: %v", err)
+	}
+	err = TeamsNotify(subject, body, teamshooks)
+	if err != nil {
+		log.Printf("Failed to send teams notification: %v", err)
 	}
-	return curStatus
 }
 
-func MockCheckClusterUpdate(reader, writer *ovh.Client, config ovhwrapper.Configuration, realslid, realclid string) string {
-	time.Sleep(time.Second * time.Duration(rand.Intn(60)))
-	return statusString(reader, realslid, realclid)
+func MockCheckClusterUpdate(ctx context.Context, reader, writer ovhwrapper.API, config ovhwrapper.Configuration, realslid, realclid string) string {
+	sleepContext(ctx, time.Second*time.Duration(rand.Intn(60)))
+	return statusString(ctx, reader, realslid, realclid)
 }
 
-func UpdateCluster(reader, writer *ovh.Client, config ovhwrapper.Configuration, serviceid, clusterid string, background, latest, force bool) {
-	var realslid, realclid string
-	var curStatus, prevStatus string
-
-	slids := ovhwrapper.GetServicelines(reader)
-	for _, slid := range slids {
-		details := ovhwrapper.GetOVHServiceline(reader, slid)
-		sl := ovhwrapper.ServiceLine{
-			ID:        slid,
-			SLDetails: *details,
-		}
-		if MatchItem(sl, serviceid) {
-			realslid = sl.ID
-			clids, err := ovhwrapper.GetK8SClusterIDs(reader, slid)
-			if err != nil {
-				fmt.Printf("Failed to get cluster IDs: %v\n", err)
-				continue
-			}
+func UpdateCluster(ctx context.Context, reader, writer ovhwrapper.API, config ovhwrapper.Configuration, serviceid, clusterid string, background, latest, force, skipPreflight bool, maxEtcd int, wait waitOptions) {
+	sl, cl, err := ovhwrapper.NewResolver(GlobalInventory).Cluster(serviceid, clusterid)
+	if err != nil {
+		log.Fatalf("%s\n", explainError(err))
+	}
+	realslid, realclid := sl.ID, cl.ID
 
-			for _, clid := range clids {
-				cl := ovhwrapper.GetK8SCluster(reader, slid, clid)
-				if MatchItem(*cl, clusterid) {
-					realclid = cl.ID
-				}
-			}
-		}
+	if _, ok := checkPreflight(ctx, reader, realslid, realclid, cl.Name, maxEtcd, skipPreflight); !ok {
+		os.Exit(1)
 	}
 
-	err := ovhwrapper.UpdateK8SCluster(writer, realslid, realclid, latest, force)
+	err = ovhwrapper.UpdateK8SCluster(ctx, writer, realslid, realclid, latest, force)
 	if err != nil {
-		log.Fatalf("Failed to initiate cluster update: %v", err)
+		log.Fatalf("Failed to initiate cluster update: %s", explainError(err))
 	}
 
 	if !background {
-		time.Sleep(10 * time.Second) // give the update 10 seconds to get triggered
-
-		for {
-			client, err := ovhwrapper.CreateReader(config)
-			if err != nil {
-				log.Fatalf("Error creating OVH API Reader: %q\n", err)
-			}
-
-			cl := ovhwrapper.GetK8SCluster(client, realslid, realclid)
-			if cl != nil {
-				//fmt.Println("\033[2J")  // clear screen
-				curStatus = statusString(client, realslid, realclid)
-				// show status if status has changed since last check
-				if curStatus != prevStatus {
-					fmt.Println(curStatus)
-					prevStatus = curStatus
-				}
-
-				// end update loop if cluster is in ready state
-				if cl.Status == "READY" {
-					break
-				}
-				time.Sleep(60 * time.Second)
-			}
+		// give the update 10 seconds to get triggered
+		result := watchCluster(ctx, reader, realslid, realclid, wait, 10*time.Second, printStatus)
+		fmt.Printf("Update of cluster %s %s\n", cl.Name, result)
+		if !result.Succeeded() {
+			os.Exit(1)
 		}
 	}
 }
 
-func ResetKubeconfig(reader, writer *ovh.Client, config ovhwrapper.Configuration, serviceid, clusterid string, background bool) {
-	var realslid, realclid string
-
+func ResetKubeconfig(ctx context.Context, reader, writer ovhwrapper.API, config ovhwrapper.Configuration, serviceid, clusterid string, background bool, wait waitOptions) {
 	//fmt.Printf("Serviceline: %s\n"+
 	//	"Cluster ID: %s\n"+
 	//	"Background: %v\n", serviceid, clusterid, background)
 
-	slids := ovhwrapper.GetServicelines(reader)
-	for _, slid := range slids {
-		details := ovhwrapper.GetOVHServiceline(reader, slid)
-		sl := ovhwrapper.ServiceLine{
-			ID:        slid,
-			SLDetails: *details,
-		}
-		if MatchItem(sl, serviceid) {
-			realslid = sl.ID
-			clids, err := ovhwrapper.GetK8SClusterIDs(reader, slid)
-			if err != nil {
-				fmt.Printf("Failed to get cluster IDs: %v\n", err)
-				continue
-			}
-
-			for _, clid := range clids {
-				cl := ovhwrapper.GetK8SCluster(reader, slid, clid)
-				if MatchItem(*cl, clusterid) {
-					realclid = cl.ID
-				}
-			}
-		}
-	}
-
-	if realslid == "" {
-		log.Fatalf("Service line not found: %s\n", serviceid)
-	}
-	if realclid == "" {
-		log.Fatalf("Cluster not found: %s\n", clusterid)
+	sl, cl, err := ovhwrapper.NewResolver(GlobalInventory).Cluster(serviceid, clusterid)
+	if err != nil {
+		log.Fatalf("%s\n", explainError(err))
 	}
+	realslid, realclid := sl.ID, cl.ID
 
 	fmt.Printf("Resetting kubeconfig for serviceline %s (%s) cluster %s(%s)\n", serviceid, realslid, clusterid, realclid)
-	kc, err := ovhwrapper.ResetKubeconfig(writer, realslid, realclid)
+	kc, err := ovhwrapper.ResetKubeconfig(ctx, writer, realslid, realclid)
 	if err != nil {
-		log.Fatalf("Failed to initiate kubeconfig reset: %v", err)
+		log.Fatalf("Failed to initiate kubeconfig reset: %s", explainError(err))
 	}
 	fmt.Println(kc)
 
 	if !background {
-		time.Sleep(10 * time.Second) // give the reset 10 seconds to get triggered
-
-		for {
-			client, err := ovhwrapper.CreateReader(config)
-			if err != nil {
-				log.Fatalf("Error creating OVH API Reader: %q\n", err)
-			}
-
-			cl := ovhwrapper.GetK8SCluster(client, realslid, realclid)
-			if cl != nil {
-				//fmt.Println("\033[2J")  // clear screen
-				Status(client, false, realslid, realclid)
-
-				if cl.Status == "READY" {
-					break
-				}
-				time.Sleep(60 * time.Second)
-			}
+		// give the reset 10 seconds to get triggered
+		result := watchCluster(ctx, reader, realslid, realclid, wait, 10*time.Second, printStatus)
+		fmt.Printf("Kubeconfig reset of cluster %s %s\n", cl.Name, result)
+		if !result.Succeeded() {
+			os.Exit(1)
 		}
 	}
 }
 
-func Logout(writer *ovh.Client, config ovhwrapper.Configuration) {
+func Logout(ctx context.Context, writer ovhwrapper.API, config ovhwrapper.Configuration) {
 	var result []byte
-	if err := writer.Post("/auth/logout", nil, &result); err != nil {
-		fmt.Printf("Error revoking consumer key: %q\n", err)
+	if err := writer.PostWithContext(ctx, "/auth/logout", nil, &result); err != nil {
+		fmt.Printf("Error revoking consumer key: %s\n", explainError(err))
 	}
 	fmt.Println(string(result))
 	config.Writer.ConsumerKey = ""
 
-	err := ovhwrapper.SaveYaml(config, config.GetPath())
+	err := config.Save()
 	if err != nil {
 		log.Printf("Error saving configuration: %v", err)
 	}
@@ -981,7 +794,7 @@ func (i Inventory) String() string {
 }
 
 // ListFlavors lists available nodepool flavors
-func ListFlavors(client *ovh.Client, flavors ovhwrapper.K8SFlavors) {
+func ListFlavors(ctx context.Context, client ovhwrapper.API, flavors ovhwrapper.K8SFlavors) {
 
 	var flavorList []ovhwrapper.K8SFlavor
 	for _, flavor := range flavors {
@@ -997,7 +810,7 @@ func ListFlavors(client *ovh.Client, flavors ovhwrapper.K8SFlavors) {
 	}
 }
 
-func ListOVHVolumes(reader, writer *ovh.Client, all bool, serviceid, clusterid string, unattachedOnly bool) {
+func ListOVHVolumes(ctx context.Context, reader, writer ovhwrapper.API, all bool, serviceid, clusterid string, unattachedOnly bool) {
 	volumes := ovhwrapper.ReadVolumesFromFile()
 	var filteredVolumes []ovhwrapper.OVHVolume
 
@@ -1012,7 +825,7 @@ func ListOVHVolumes(reader, writer *ovh.Client, all bool, serviceid, clusterid s
 	ovhwrapper.ListOVHVolumes(volumes)
 }
 
-func DescribeOVHVolumes(reader, writer *ovh.Client, all bool, serviceid, clusterid string, unattachedOnly bool, output string) {
+func DescribeOVHVolumes(ctx context.Context, reader, writer ovhwrapper.API, all bool, serviceid, clusterid string, unattachedOnly bool, output string) {
 	volumes := ovhwrapper.ReadVolumesFromFile()
 	var filteredVolumes []ovhwrapper.OVHVolume
 
@@ -1030,6 +843,6 @@ func DescribeOVHVolumes(reader, writer *ovh.Client, all bool, serviceid, cluster
 	}
 }
 
-func DeleteOVHVolume(reader, writer *ovh.Client, serviceid, clusterid string, force bool) {
+func DeleteOVHVolume(ctx context.Context, reader, writer ovhwrapper.API, serviceid, clusterid string, force bool) {
 
 }
@@ -0,0 +1,297 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/snafuprinzip/ovhwrapper"
+)
+
+// Status of a cluster in a group update run.
+const (
+	ClusterUpdatePending   = "pending"
+	ClusterUpdateTriggered = "triggered"
+	ClusterUpdateUpdating  = "updating"
+	ClusterUpdateDone      = "done"
+	ClusterUpdateFailed    = "failed"
+)
+
+// groupUpdateCluster is the progress of the update of one cluster of a group update run.
+type groupUpdateCluster struct {
+	Serviceline   string `yaml:"serviceline"`
+	Cluster       string `yaml:"cluster"`
+	ServicelineID string `yaml:"servicelineId,omitempty"`
+	ClusterID     string `yaml:"clusterId,omitempty"`
+	Email         string `yaml:"email,omitempty"`
+	TeamsWebhook  string `yaml:"teamsWebhook,omitempty"`
+	Status        string `yaml:"status"`
+	// the pre-flight checks as checklist, included in the notification
+	Preflight   string    `yaml:"preflight,omitempty"`
+	TriggeredAt time.Time `yaml:"triggeredAt,omitempty"`
+	FinishedAt  time.Time `yaml:"finishedAt,omitempty"`
+	// why the cluster has not been updated or how waiting for the update ended
+	Result string `yaml:"result,omitempty"`
+
+	// the last status of the cluster and its nodes shown in the summary
+	lastStatus string
+}
+
+// groupUpdateRun is the state of an update of a clustergroup. It is saved whenever the state of a cluster
+// changes, so the run can be resumed if ovhctl dies while the clusters are updating.
+type groupUpdateRun struct {
+	ID           string `yaml:"id"`
+	Clustergroup string `yaml:"clustergroup"`
+	Latest       bool   `yaml:"latest,omitempty"`
+	Force        bool   `yaml:"force,omitempty"`
+	// update the clusters even if their pre-flight checks fail
+	SkipPreflight bool                 `yaml:"skipPreflight,omitempty"`
+	MaxEtcd       int                  `yaml:"maxEtcd"`
+	Wait          waitOptions          `yaml:"wait"`
+	StartedAt     time.Time            `yaml:"startedAt"`
+	UpdatedAt     time.Time            `yaml:"updatedAt"`
+	Clusters      []groupUpdateCluster `yaml:"clusters"`
+
+	// a pointer, as the run is marshalled while the lock is held
+	mu     *sync.Mutex
+	config ovhwrapper.Configuration
+}
+
+// Finished reports whether the updates of all clusters are done or failed.
+func (r *groupUpdateRun) Finished() bool {
+	for _, c := range r.Clusters {
+		if c.Status != ClusterUpdateDone && c.Status != ClusterUpdateFailed {
+			return false
+		}
+	}
+	return true
+}
+
+// count returns the number of clusters with the status.
+func (r *groupUpdateRun) count(status string) int {
+	var n int
+	for _, c := range r.Clusters {
+		if c.Status == status {
+			n++
+		}
+	}
+	return n
+}
+
+// updateRunDir returns the directory the group update runs of the profile are kept in.
+func updateRunDir(config ovhwrapper.Configuration) string {
+	return stateDir(config, "ovhctl-updates")
+}
+
+// loadGroupUpdateRun reads the state of a group update run.
+func loadGroupUpdateRun(config ovhwrapper.Configuration, id string) (*groupUpdateRun, error) {
+	run := &groupUpdateRun{mu: &sync.Mutex{}, config: config}
+	statefile := filepath.Join(updateRunDir(config), id+".yaml")
+	if err := ovhwrapper.LoadYaml(run, statefile); errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("update run %s not found", id)
+	} else if err != nil {
+		return nil, fmt.Errorf("reading state of update run %s: %w", id, err)
+	}
+	return run, nil
+}
+
+// set changes the state of a cluster and saves the run, failing to do so is fatal as the run could not be resumed.
+func (r *groupUpdateRun) set(i int, change func(c *groupUpdateCluster)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	change(&r.Clusters[i])
+	r.save()
+}
+
+func (r *groupUpdateRun) save() {
+	r.UpdatedAt = time.Now()
+	statefile := filepath.Join(updateRunDir(r.config), r.ID+".yaml")
+	if err := ovhwrapper.SaveYaml(r, statefile); err != nil {
+		log.Fatalf("Failed to save state of update run %s to %s: %v", r.ID, statefile, err)
+	}
+}
+
+// newGroupUpdateRun creates the run for updating all clusters of the clustergroup of the inventory. Clusters
+// which can not be resolved are recorded as failed.
+func newGroupUpdateRun(config ovhwrapper.Configuration, inv Inventory, clustergroup string, latest, force, skipPreflight bool, maxEtcd int, wait waitOptions) *groupUpdateRun {
+	now := time.Now()
+	run := &groupUpdateRun{
+		ID:            clustergroup + "-" + now.Format("20060102-150405"),
+		Clustergroup:  clustergroup,
+		Latest:        latest,
+		Force:         force,
+		SkipPreflight: skipPreflight,
+		MaxEtcd:       maxEtcd,
+		Wait:          wait,
+		StartedAt:     now,
+		mu:            &sync.Mutex{},
+		config:        config,
+	}
+
+	resolver := ovhwrapper.NewResolver(GlobalInventory)
+	for _, cg := range inv.Clustergroups {
+		if cg.Name != clustergroup {
+			continue
+		}
+		for _, project := range cg.Projects {
+			for _, clustername := range project.Clusters {
+				c := groupUpdateCluster{
+					Serviceline:  project.Name,
+					Cluster:      clustername,
+					Email:        project.Email,
+					TeamsWebhook: project.TeamsWebhook,
+					Status:       ClusterUpdatePending,
+				}
+				sl, cl, err := resolver.Cluster(project.Name, clustername)
+				if err != nil {
+					log.Printf("Skipping cluster: %s\n", explainError(err))
+					c.Status, c.Result = ClusterUpdateFailed, "not updated: "+err.Error()
+				} else {
+					c.ServicelineID, c.ClusterID = sl.ID, cl.ID
+				}
+				run.Clusters = append(run.Clusters, c)
+			}
+		}
+		break
+	}
+	return run
+}
+
+// runGroupUpdate triggers the updates of the pending clusters of the run after their pre-flight checks and
+// waits for them and for the clusters which have been triggered or were updating when the run got interrupted.
+// A cluster is marked as triggered before its update is started, so it is never updated twice.
+func runGroupUpdate(ctx context.Context, reader, writer ovhwrapper.API, config ovhwrapper.Configuration, run *groupUpdateRun) {
+	var wg sync.WaitGroup
+
+	for i := range run.Clusters {
+		c := run.Clusters[i]
+		resumed := c.Status == ClusterUpdateTriggered || c.Status == ClusterUpdateUpdating
+
+		switch {
+		case c.Status == ClusterUpdatePending:
+			if ctx.Err() != nil {
+				continue
+			}
+			checks, ok := checkPreflight(ctx, reader, c.ServicelineID, c.ClusterID, c.Cluster, run.MaxEtcd, run.SkipPreflight)
+			if !ok {
+				run.set(i, func(c *groupUpdateCluster) {
+					c.Status, c.Preflight, c.Result = ClusterUpdateFailed, checks.String(),
+						"not updated, pre-flight checks failed"
+				})
+				notifyClusterUpdate(c.Cluster, c.Email, c.TeamsWebhook, fmt.Sprintf("k8s Update: %s not started", c.Cluster),
+					fmt.Sprintf("Update for %s not started at %s, the pre-flight checks failed:\n\n%s", c.Cluster,
+						time.Now().Format(time.RFC1123Z), checks))
+				continue
+			}
+			run.set(i, func(c *groupUpdateCluster) {
+				c.Status, c.Preflight, c.TriggeredAt = ClusterUpdateTriggered, checks.String(), time.Now()
+			})
+
+			fmt.Printf("Updating cluster %25s (%s) in serviceline %25s (%s)\n", c.Cluster, c.ClusterID, c.Serviceline,
+				c.ServicelineID)
+			if err := ovhwrapper.UpdateK8SCluster(ctx, writer, c.ServicelineID, c.ClusterID, run.Latest, run.Force); err != nil {
+				log.Printf("Failed to initiate update of cluster %s: %s", c.Cluster, explainError(err))
+				run.set(i, func(c *groupUpdateCluster) {
+					c.Status, c.FinishedAt = ClusterUpdateFailed, time.Now()
+					c.Result = "not updated, failed to initiate the update: " + err.Error()
+				})
+				continue
+			}
+		case resumed:
+			fmt.Printf("Resuming monitoring of cluster %25s (%s) in serviceline %25s (%s)\n", c.Cluster, c.ClusterID,
+				c.Serviceline, c.ServicelineID)
+		default:
+			continue
+		}
+
+		wg.Add(1)
+		go func(i int, c groupUpdateCluster, resumed bool) {
+			defer wg.Done()
+			run.set(i, func(c *groupUpdateCluster) { c.Status = ClusterUpdateUpdating })
+			result, curStatus := CheckCronClusterUpdate(ctx, reader, writer, config, c.Serviceline, c.ServicelineID,
+				c.Cluster, c.ClusterID, c.Email, c.TeamsWebhook, c.Preflight, run.Wait, resumed)
+			run.set(i, func(c *groupUpdateCluster) {
+				c.lastStatus = curStatus
+				if result.Outcome == ovhwrapper.WaitCancelled {
+					// still updating, resume the run to wait for it
+					return
+				}
+				c.Status, c.FinishedAt, c.Result = ClusterUpdateFailed, time.Now(), result.String()
+				if result.Succeeded() {
+					c.Status = ClusterUpdateDone
+				}
+			})
+		}(i, c, resumed)
+	}
+	wg.Wait()
+
+	fmt.Printf("\n%d clusters in group %s updated:\n\n", len(run.Clusters), run.Clustergroup)
+	for _, c := range run.Clusters {
+		fmt.Printf("Cluster %s in serviceline %s: %s", c.Cluster, c.Serviceline, c.Status)
+		if c.Result != "" {
+			fmt.Printf(", %s", c.Result)
+		}
+		fmt.Println()
+		if c.Status == ClusterUpdateFailed && c.Preflight != "" && c.TriggeredAt.IsZero() {
+			fmt.Print(c.Preflight)
+		}
+		fmt.Print(c.lastStatus)
+	}
+	fmt.Printf("Update run %s of group %s: %d done, %d failed, %d still updating, %d pending\n", run.ID,
+		run.Clustergroup, run.count(ClusterUpdateDone), run.count(ClusterUpdateFailed),
+		run.count(ClusterUpdateTriggered)+run.count(ClusterUpdateUpdating), run.count(ClusterUpdatePending))
+	if !run.Finished() {
+		fmt.Printf("Continue with 'ovhctl update resume %s'\n", run.ID)
+	}
+}
+
+// ResumeGroupUpdate continues an interrupted group update run: the clusters which were updating are monitored
+// again, the pending ones are triggered. Without a run id the unfinished runs are listed.
+func ResumeGroupUpdate(ctx context.Context, reader, writer ovhwrapper.API, config ovhwrapper.Configuration, id string) {
+	if id == "" {
+		ListGroupUpdateRuns(config)
+		return
+	}
+	run, err := loadGroupUpdateRun(config, id)
+	if err != nil {
+		log.Fatalf("%v", err)
+	}
+	if run.Finished() {
+		fmt.Printf("Update run %s is already finished\n", id)
+		return
+	}
+	fmt.Printf("Resuming update run %s of group %s\n", run.ID, run.Clustergroup)
+	runGroupUpdate(ctx, reader, writer, config, run)
+}
+
+// ListGroupUpdateRuns lists the group update runs which are not finished yet.
+func ListGroupUpdateRuns(config ovhwrapper.Configuration) {
+	files, _ := filepath.Glob(filepath.Join(updateRunDir(config), "*.yaml"))
+	var unfinished []*groupUpdateRun
+	for _, file := range files {
+		run, err := loadGroupUpdateRun(config, strings.TrimSuffix(filepath.Base(file), ".yaml"))
+		if err != nil {
+			log.Printf("%v", err)
+			continue
+		}
+		if !run.Finished() {
+			unfinished = append(unfinished, run)
+		}
+	}
+	if len(unfinished) == 0 {
+		fmt.Println("No unfinished update runs")
+		return
+	}
+	fmt.Printf("%-35s %-20s %-20s %s\n", "RUN", "CLUSTERGROUP", "STARTED", "CLUSTERS")
+	for _, run := range unfinished {
+		fmt.Printf("%-35s %-20s %-20s %d done, %d failed, %d updating, %d pending\n", run.ID, run.Clustergroup,
+			run.StartedAt.Format(time.DateTime), run.count(ClusterUpdateDone), run.count(ClusterUpdateFailed),
+			run.count(ClusterUpdateTriggered)+run.count(ClusterUpdateUpdating), run.count(ClusterUpdatePending))
+	}
+}
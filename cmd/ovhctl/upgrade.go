@@ -0,0 +1,186 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/snafuprinzip/ovhwrapper"
+)
+
+// UpgradeCluster upgrades a cluster to the target version, one minor version after the other, if it passes the
+// pre-flight checks. Before each hop the version has to be offered in the NextUpgradeVersions of the cluster, for
+// the first one before anything is checked or started; after each hop the cluster has to be READY with the new
+// version, otherwise the upgrade stops and the remaining hops are reported.
+func UpgradeCluster(ctx context.Context, reader, writer ovhwrapper.API, config ovhwrapper.Configuration, serviceid, clusterid, target string, background, force, skipPreflight bool, maxEtcd int, wait waitOptions) {
+	sl, cluster := resolveCluster(ctx, reader, serviceid, clusterid)
+
+	hops, err := ovhwrapper.UpgradePath(cluster.Version, target)
+	if err != nil {
+		log.Fatalf("Cluster %s: %v", cluster.Name, err)
+	}
+	if len(hops) == 0 {
+		fmt.Printf("Cluster %s already runs version %s\n", cluster.Name, cluster.Version)
+		return
+	}
+	// the path is counted up from the current version, the first hop has to be offered for the cluster right now
+	if !slices.Contains(cluster.NextUpgradeVersions, hops[0]) {
+		log.Fatalf("Cluster %s can not be upgraded from %s to %s: version %s is not offered (available: %v)",
+			cluster.Name, cluster.Version, target, hops[0], cluster.NextUpgradeVersions)
+	}
+	if background && len(hops) > 1 {
+		log.Fatalf("Upgrading cluster %s to %s takes %d upgrades, which can only be run one after another without --background",
+			cluster.Name, target, len(hops))
+	}
+	if _, ok := checkPreflight(ctx, reader, sl.ID, cluster.ID, cluster.Name, maxEtcd, skipPreflight); !ok {
+		os.Exit(1)
+	}
+	fmt.Printf("Upgrading cluster %s from %s to %s: %s -> %s\n", cluster.Name, cluster.Version, target,
+		cluster.Version, strings.Join(hops, " -> "))
+
+	for i, version := range hops {
+		if !slices.Contains(cluster.NextUpgradeVersions, version) {
+			log.Fatalf("Stopped before step %d: version %s is not offered for cluster %s at version %s (available: %v), "+
+				"not upgraded to %s", i+1, version, cluster.Name, cluster.Version, cluster.NextUpgradeVersions,
+				strings.Join(hops[i:], ", "))
+		}
+
+		fmt.Printf("Step %d of %d: upgrading cluster %s from %s to %s\n", i+1, len(hops), cluster.Name, cluster.Version, version)
+		if err := ovhwrapper.UpdateK8SCluster(ctx, writer, sl.ID, cluster.ID, false, force); err != nil {
+			log.Fatalf("Failed to initiate upgrade of cluster %s to %s: %s", cluster.Name, version, explainError(err))
+		}
+		if background {
+			return
+		}
+
+		result := watchCluster(ctx, reader, sl.ID, cluster.ID, wait, 10*time.Second, printStatus)
+		switch {
+		case result.Outcome == ovhwrapper.WaitCancelled:
+			log.Printf("Monitoring of cluster %s aborted: %s\n", cluster.Name, result.Reason)
+			return
+		case !result.Succeeded():
+			log.Fatalf("Stopped at step %d: upgrade of cluster %s %s, not upgraded to %s", i+1, cluster.Name,
+				result, strings.Join(hops[i:], ", "))
+		}
+		cluster = result.Cluster
+		if !ovhwrapper.SameMinorVersion(cluster.Version, version) {
+			log.Fatalf("Stopped at step %d: cluster %s is READY but runs version %s instead of %s, not upgraded to %s",
+				i+1, cluster.Name, cluster.Version, version, strings.Join(hops[i:], ", "))
+		}
+	}
+	fmt.Printf("Cluster %s upgraded to version %s\n", cluster.Name, cluster.Version)
+	refreshInventory(ctx, reader, config)
+}
+
+// clusterPlan is what an update of a clustergroup would do with one of its clusters.
+type clusterPlan struct {
+	Clustergroup           string   `json:"clustergroup" yaml:"clustergroup"`
+	Serviceline            string   `json:"serviceline" yaml:"serviceline"`
+	Cluster                string   `json:"cluster" yaml:"cluster"`
+	ClusterID              string   `json:"clusterId,omitempty" yaml:"clusterId,omitempty"`
+	Status                 string   `json:"status,omitempty" yaml:"status,omitempty"`
+	Version                string   `json:"version,omitempty" yaml:"version,omitempty"`
+	NextUpgradeVersions    []string `json:"nextUpgradeVersions" yaml:"nextUpgradeVersions"`
+	Strategy               string   `json:"strategy" yaml:"strategy"`
+	TargetVersion          string   `json:"targetVersion,omitempty" yaml:"targetVersion,omitempty"`
+	UpdatePolicy           string   `json:"updatePolicy,omitempty" yaml:"updatePolicy,omitempty"`
+	IsUpToDate             bool     `json:"isUpToDate" yaml:"isUpToDate"`
+	ControlPlaneIsUpToDate bool     `json:"controlPlaneIsUpToDate" yaml:"controlPlaneIsUpToDate"`
+	Blockers               []string `json:"blockers" yaml:"blockers"`
+}
+
+// planCluster returns the plan for updating the cluster with the strategy, with the reasons why the update
+// would fail or do nothing as blockers.
+func planCluster(ctx context.Context, reader ovhwrapper.API, resolver *ovhwrapper.Resolver, clustergroup, serviceline, clustername string, latest bool) clusterPlan {
+	plan := clusterPlan{
+		Clustergroup:        clustergroup,
+		Serviceline:         serviceline,
+		Cluster:             clustername,
+		Strategy:            ovhwrapper.UpdateStrategy(latest),
+		NextUpgradeVersions: []string{},
+		Blockers:            []string{},
+	}
+	sl, cl, err := resolver.Cluster(serviceline, clustername)
+	if err != nil {
+		plan.Blockers = append(plan.Blockers, err.Error())
+		return plan
+	}
+	cluster, err := ovhwrapper.GetK8SCluster(ctx, reader, sl.ID, cl.ID)
+	if err != nil {
+		plan.Blockers = append(plan.Blockers, "failed to get cluster: "+err.Error())
+		return plan
+	}
+
+	plan.ClusterID = cluster.ID
+	plan.Status = cluster.Status
+	plan.Version = cluster.Version
+	plan.UpdatePolicy = cluster.UpdatePolicy
+	plan.IsUpToDate = cluster.IsUpToDate
+	plan.ControlPlaneIsUpToDate = cluster.ControlPlaneIsUpToDate
+	if cluster.NextUpgradeVersions != nil {
+		plan.NextUpgradeVersions = cluster.NextUpgradeVersions
+	}
+
+	if cluster.Status != "READY" {
+		plan.Blockers = append(plan.Blockers, "cluster is "+cluster.Status+", not READY")
+	}
+	switch {
+	case plan.Strategy == ovhwrapper.UpdateStrategyLatestPatch && cluster.IsUpToDate:
+		plan.Blockers = append(plan.Blockers, "already on the latest patch version")
+	case plan.Strategy == ovhwrapper.UpdateStrategyLatestPatch:
+		plan.TargetVersion = cluster.Version
+	case len(cluster.NextUpgradeVersions) == 0:
+		plan.Blockers = append(plan.Blockers, "no newer minor version available")
+	default:
+		plan.TargetVersion = cluster.NextUpgradeVersions[0]
+	}
+	return plan
+}
+
+// PlanClusterGroup shows what an update of the clustergroup would do with each of its clusters, without
+// changing anything.
+func PlanClusterGroup(ctx context.Context, reader ovhwrapper.API, config ovhwrapper.Configuration, clustergroup, inventory string, latest bool, output string) {
+	inv := readInventory(ctx, reader, config, inventory)
+	i := slices.IndexFunc(inv.Clustergroups, func(cg Clustergroup) bool { return cg.Name == clustergroup })
+	if i < 0 {
+		log.Fatalf("Clustergroup %s not found in the inventory", clustergroup)
+	}
+	resolver := ovhwrapper.NewResolver(GlobalInventory)
+
+	plans := []clusterPlan{}
+	for _, project := range inv.Clustergroups[i].Projects {
+		for _, clustername := range project.Clusters {
+			plans = append(plans, planCluster(ctx, reader, resolver, clustergroup, project.Name, clustername, latest))
+		}
+	}
+
+	switch output {
+	case "yaml":
+		fmt.Println(ovhwrapper.ToYaml(plans))
+	case "json":
+		fmt.Println(ovhwrapper.ToJSON(plans))
+	case "text":
+		fallthrough
+	default:
+		var blocked int
+		fmt.Printf("Update plan for clustergroup %s with strategy %s:\n\n", clustergroup, ovhwrapper.UpdateStrategy(latest))
+		fmt.Printf("%-25s %-25s %-12s %-8s %-10s %-8s %-8s %-8s %s\n", "SERVICELINE", "CLUSTER", "STATUS", "VERSION",
+			"NEXT", "TARGET", "UP2DATE", "CP-UP2D", "POLICY")
+		for _, plan := range plans {
+			fmt.Printf("%-25s %-25s %-12s %-8s %-10s %-8s %-8t %-8t %s\n", plan.Serviceline, plan.Cluster, plan.Status,
+				plan.Version, strings.Join(plan.NextUpgradeVersions, ","), plan.TargetVersion, plan.IsUpToDate,
+				plan.ControlPlaneIsUpToDate, plan.UpdatePolicy)
+			for _, blocker := range plan.Blockers {
+				fmt.Printf("    blocker: %s\n", blocker)
+			}
+			if len(plan.Blockers) > 0 {
+				blocked++
+			}
+		}
+		fmt.Printf("\n%d of %d clusters would be updated, %d are blocked\n", len(plans)-blocked, len(plans), blocked)
+	}
+}
@@ -6,39 +6,15 @@ import (
 	"github.com/ovh/go-ovh/ovh"
 	"github.com/snafuprinzip/ovhwrapper"
 
+	"context"
+	"errors"
+	"fmt"
 	"log"
 	"net/smtp"
 	"os"
 	"strings"
 )
 
-// CollectInformation collects the information of all service lines, including their clusters down to the nodes.
-func CollectInformation(client *ovh.Client) []ovhwrapper.ServiceLine {
-	var servicelines []ovhwrapper.ServiceLine
-
-	//services := ovhwrapper.GetServicelines(client)
-	//for _, service := range services {
-	//serviceline := CollectServiceline(client, service)
-	//servicelines = append(servicelines, *serviceline)
-	//}
-
-	return servicelines
-}
-
-// GetServiceline asks the API for 'shallow' information about a specific serviceline, excluding the clusters.
-func GetServiceline(client *ovh.Client, serviceid string) *ovhwrapper.ServiceLine {
-	servicedetails, err := ovhwrapper.GetServicelineDetails(client, serviceid)
-	if err != nil {
-		log.Fatalf("Failed to get serviceline details: %v", err)
-	}
-	serviceline := ovhwrapper.ServiceLine{
-		ID:        serviceid,
-		SLDetails: servicedetails,
-		Cluster:   []ovhwrapper.K8SCluster{},
-	}
-	return &serviceline
-}
-
 // MatchItem will check if the id or the (abbreviated) name matches with the identifier and returns true or false
 func MatchItem[T ovhwrapper.ServiceLine | ovhwrapper.OVHDatabase](object T, identifier string) bool {
 	match := false
@@ -55,6 +31,87 @@ func MatchItem[T ovhwrapper.ServiceLine | ovhwrapper.OVHDatabase](object T, iden
 	return match
 }
 
+// connect reads the configuration of the given profile and creates the api clients. If the writer has no
+// consumer key yet, a new one is created and saved and the program exits, as the key has to be validated first.
+func connect(ctx context.Context, profile string) (ovhwrapper.Configuration, *ovh.Client, *ovh.Client) {
+	config, err := ovhwrapper.ReadProfile(profile)
+	if err != nil {
+		log.Fatalf("Error loading configuration, no valid config found: %v", err)
+	}
+	for _, warning := range config.Warnings() {
+		log.Printf("Warning: %s", warning)
+	}
+
+	reader, err := ovhwrapper.CreateReader(config)
+	if err != nil {
+		log.Fatalf("Error creating OVH API Reader: %q\n", err)
+	}
+
+	writer, err := ovhwrapper.CreateWriter(config)
+	if err != nil {
+		log.Fatalf("Error creating OVH API Writer: %q\n", err)
+	}
+	debugTransport(reader, writer)
+
+	// create Writer ConsumerKey if necessary
+	if config.Writer.ConsumerKey == "" {
+		consumerkey, validationURL, err := ovhwrapper.CreateConsumerKey(ctx, reader, writer)
+		if err != nil {
+			log.Fatalf("Error creating consumer key: %s\n", explainError(err))
+		}
+		fmt.Printf("Generated consumer key for profile %s: %s\n", config.ProfileName(), consumerkey)
+		fmt.Printf("Please visit %s to validate it\n", validationURL)
+		config.Writer.ConsumerKey = consumerkey
+
+		if err := config.Save(); err != nil {
+			log.Fatalf("Error saving config file %s: %q\n", config.GetPath(), err)
+		}
+		os.Exit(0)
+	}
+
+	return config, reader, writer
+}
+
+// explainError returns the error message together with a hint on how to solve the most common api errors
+func explainError(err error) string {
+	switch {
+	case errors.Is(err, ovhwrapper.ErrUnauthorized):
+		return fmt.Sprintf("%v\nthe consumer key is invalid or has expired, run 'ovhdbctl logout' to create a new one", err)
+	case errors.Is(err, ovhwrapper.ErrForbidden):
+		return fmt.Sprintf("%v\nyour writer key has no rights on this resource (e.g. a database created after the key), "+
+			"run 'ovhdbctl logout' to create a new one", err)
+	case errors.Is(err, ovhwrapper.ErrNotFound):
+		return fmt.Sprintf("%v\nthe resource does not exist (anymore)", err)
+	case errors.Is(err, ovhwrapper.ErrRateLimited):
+		return fmt.Sprintf("%v\ntoo many requests, please try again later", err)
+	}
+	return err.Error()
+}
+
+// debugTransport logs every retry of the api clients once debug output is enabled
+func debugTransport(clients ...*ovh.Client) {
+	for _, client := range clients {
+		if transport, ok := ovhwrapper.GetRetryTransport(client); ok {
+			transport.Debugf = func(format string, args ...any) {
+				if debug {
+					log.Printf(format, args...)
+				}
+			}
+		}
+	}
+}
+
+// printTransportStats prints the request and retry counters of an api client
+func printTransportStats(name string, client *ovh.Client) {
+	transport, ok := ovhwrapper.GetRetryTransport(client)
+	if !ok {
+		return
+	}
+	stats := transport.Stats()
+	log.Printf("%s: %d requests, %d retries, %d rate limited, %d server errors, %d failed\n", name,
+		stats.Requests, stats.Retries, stats.RateLimited, stats.ServerError, stats.Failed)
+}
+
 // fileExists returns true if a file exists, false if not
 func fileExists(filename string) bool {
 	_, err := os.Stat(filename)
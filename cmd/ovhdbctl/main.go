@@ -4,6 +4,8 @@ import (
 	"context"
 	"log"
 	"os"
+	"os/signal"
+	"syscall"
 
 	"github.com/ovh/go-ovh/ovh"
 	"github.com/snafuprinzip/ovhwrapper"
@@ -21,40 +23,16 @@ func main() {
 	var writer *ovh.Client
 	var config ovhwrapper.Configuration
 
-	config, err := ovhwrapper.ReadConfiguration()
-	if err != nil {
-		log.Fatalf("Error loading configuration, no valid config found: %v", err)
-	}
-
-	reader, err = ovhwrapper.CreateReader(config)
-	if err != nil {
-		log.Fatalf("Error creating OVH API Reader: %q\n", err)
-	}
-
-	writer, err = ovhwrapper.CreateWriter(config)
-	if err != nil {
-		log.Fatalf("Error creating OVH API Writer: %q\n", err)
-	}
-
-	// create Writer ConsumerKey if necessary
-	if config.Writer.ConsumerKey == "" {
-		// consumer key erzeugen
-		consumerkey, err := ovhwrapper.CreateConsumerKey(reader, writer)
-		if err != nil {
-			log.Fatalf("Error: %q\n", err)
-		}
-		config.Writer.ConsumerKey = consumerkey
-
-		err = ovhwrapper.SaveYaml(config, config.GetPath())
-		if err != nil {
-			log.Fatalf("Error saving config file %s: %q\n", config.GetPath(), err)
-		}
-		os.Exit(0)
-	}
-
-	GatherGlobalInventory(reader)
+	// cancel running api requests and update monitoring on ctrl-c
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
 	globalFlags := []cli.Flag{
+		&cli.StringFlag{
+			Name:    "profile",
+			Usage:   "use the named profile of the configuration file",
+			Sources: cli.EnvVars("OVH_PROFILE"),
+		},
 		&cli.BoolFlag{
 			Name:  "debug",
 			Usage: "enable debug output",
@@ -81,6 +59,21 @@ func main() {
 		Usage:     "cli tool for the ovh database service api ",
 		UsageText: "ovhdbctl <command> [subcommand] [options]",
 		Flags:     globalFlags,
+		Before: func(ctx context.Context, cmd *cli.Command) (context.Context, error) {
+			// flag actions run after the before hooks, set the global options here for the setup
+			debug = cmd.Bool("debug")
+			verbose = cmd.Bool("verbose")
+			config, reader, writer = connect(ctx, cmd.String("profile"))
+			GatherGlobalInventory(ctx, reader, config.Concurrency)
+			return ctx, nil
+		},
+		After: func(ctx context.Context, cmd *cli.Command) error {
+			if debug {
+				printTransportStats("reader", reader)
+				printTransportStats("writer", writer)
+			}
+			return nil
+		},
 		Commands: []*cli.Command{
 			{
 				Name:    "list",
@@ -91,7 +84,7 @@ func main() {
 					&cli.StringFlag{Name: "serviceline", Aliases: []string{"s"}, Usage: "list databases of given serviceline"},
 				},
 				Action: func(ctx context.Context, cmd *cli.Command) error {
-					List(reader, cmd.Bool("all"), cmd.String("serviceline"))
+					List(ctx, reader, cmd.Bool("all"), cmd.String("serviceline"))
 					return nil
 				},
 			},
@@ -105,7 +98,7 @@ func main() {
 					&cli.StringFlag{Name: "database", Aliases: []string{"d"}, Usage: "specific database of a given serviceline"},
 				},
 				Action: func(ctx context.Context, cmd *cli.Command) error {
-					Status(reader, cmd.Bool("all"), cmd.String("serviceline"), cmd.String("db"))
+					Status(ctx, reader, cmd.Bool("all"), cmd.String("serviceline"), cmd.String("db"))
 					return nil
 				},
 			},
@@ -120,7 +113,7 @@ func main() {
 					&cli.StringFlag{Name: "output", Aliases: []string{"o"}, Usage: "set output format [yaml, json, text]"},
 				},
 				Action: func(ctx context.Context, cmd *cli.Command) error {
-					Describe(reader, cmd.Bool("all"), cmd.String("serviceline"), cmd.String("database"), cmd.String("output"))
+					Describe(ctx, reader, cmd.Bool("all"), cmd.String("serviceline"), cmd.String("database"), cmd.String("output"))
 					return nil
 				},
 			},
@@ -144,7 +137,7 @@ func main() {
 							//		"if background is set the program will exit immediately after starting the upgrade"},
 						},
 						Action: func(ctx context.Context, cmd *cli.Command) error {
-							UpdateDatabase(reader, writer, config, cmd.String("serviceline"), cmd.String("database"))
+							UpdateDatabase(ctx, reader, writer, config, cmd.String("serviceline"), cmd.String("database"))
 							return nil
 						},
 					},
@@ -156,9 +149,14 @@ func main() {
 				Usage:   "shows the credentials used for api access",
 				Flags: []cli.Flag{
 					&cli.StringFlag{Name: "output", Aliases: []string{"o"}, Usage: "set output format [yaml, json, text]"},
+					&cli.BoolFlag{Name: "source", Usage: "show where each credential value came from instead of the tokens"},
 				},
 				Action: func(ctx context.Context, cmd *cli.Command) error {
-					Credentials(reader, writer, cmd.String("output"))
+					if cmd.Bool("source") {
+						CredentialSources(config, cmd.String("output"))
+						return nil
+					}
+					Credentials(ctx, reader, writer, cmd.String("output"))
 					return nil
 				},
 			},
@@ -167,14 +165,14 @@ func main() {
 				Aliases: []string{"o"},
 				Usage:   "revoke consumer key, next time the command will be run it will create a new consumer key",
 				Action: func(ctx context.Context, cmd *cli.Command) error {
-					Logout(writer, config)
+					Logout(ctx, writer, config)
 					return nil
 				},
 			},
 		},
 	}
 
-	if err := cmd.Run(context.Background(), os.Args); err != nil {
+	if err := cmd.Run(ctx, os.Args); err != nil {
 		log.Fatal(err)
 	}
 }
@@ -1,92 +1,56 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"log"
 
-	"github.com/google/uuid"
-	"github.com/ovh/go-ovh/ovh"
 	"github.com/snafuprinzip/ovhwrapper"
+	"github.com/snafuprinzip/ovhwrapper/inventory"
 )
 
-func GatherGlobalInventory(client *ovh.Client) {
-	GlobalInventory = []ovhwrapper.ServiceLine{}
-	projectIDs := ovhwrapper.GetServicelines(client)
-
-	projectChannel := make(chan ovhwrapper.ServiceLine)
-	for _, projectID := range projectIDs {
-		go GatherServiceline(client, projectID, projectChannel)
-	}
-	for len(GlobalInventory) < len(projectIDs) {
-		GlobalInventory = append(GlobalInventory, <-projectChannel)
-	}
-}
-
-func GatherServiceline(client *ovh.Client, projectID string, projectChan chan<- ovhwrapper.ServiceLine) {
-	detailChan := make(chan ovhwrapper.OVHServiceLine)
-	dbsChan := make(chan []ovhwrapper.Database)
-
-	go func(detailChan chan<- ovhwrapper.OVHServiceLine) {
-		servicedetails, err := ovhwrapper.GetServicelineDetails(client, projectID)
-		if err != nil {
-			log.Printf("Failed to get serviceline details: %v", err)
-			detailChan <- ovhwrapper.OVHServiceLine{}
-		} else {
-			detailChan <- servicedetails
+// GatherGlobalInventory collects all servicelines including their databases into GlobalInventory.
+// Resources which could not be gathered are logged and left out, only a failing serviceline list is fatal.
+func GatherGlobalInventory(ctx context.Context, client ovhwrapper.API, concurrency int) {
+	gatherer := inventory.New(client, inventory.Options{Concurrency: concurrency, Databases: true})
+	servicelines, err := gatherer.Gather(ctx)
+	if errs, ok := inventory.AsErrors(err); ok {
+		for _, resErr := range errs {
+			log.Printf("Failed to gather %s", explainError(resErr))
 		}
-	}(detailChan)
-
-	dbIDs, err := ovhwrapper.GetDatabaseIDs(client, projectID)
-	if err != nil {
-		log.Fatalf("Failed to get database IDs: %v", err)
+	} else if err != nil {
+		log.Fatalf("Failed to get servicelines: %s", explainError(err))
 	}
-
-	go GatherDatabases(client, projectID, dbIDs, dbsChan)
-
-	serviceline := ovhwrapper.ServiceLine{
-		ID:        projectID,
-		SLDetails: <-detailChan,
-		Databases: <-dbsChan,
-	}
-
-	projectChan <- serviceline
+	GlobalInventory = servicelines
 }
 
-func GatherDatabases(client *ovh.Client, projectID string, dbIDs []uuid.UUID, dbsChan chan<- []ovhwrapper.OVHDatabase) {
-	var databases []ovhwrapper.OVHDatabase
-	dbChan := make(chan ovhwrapper.OVHDatabase)
-
-	for _, databaseID := range dbIDs {
-		go func(projectID string, databaseID uuid.UUID, dbsChan chan<- ovhwrapper.OVHDatabase) {
-			GatherDatabase(client, projectID, databaseID, dbsChan)
-		}(projectID, databaseID, dbChan)
-	}
-
-	for range len(dbIDs) {
-		database := <-dbChan
-		databases = append(databases, database)
-	}
-	close(dbChan)
-	dbsChan <- databases
-}
-
-func GatherDatabase(client *ovh.Client, projectID string, databaseID uuid.UUID, dbsChan chan<- ovhwrapper.OVHDatabase) {
-	database := ovhwrapper.GetDatabase(client, projectID, databaseID)
-
-	if database != nil {
-		dbsChan <- *database
+// CredentialSources shows the source of the credential values of the active profile, the values itself
+// are never printed.
+func CredentialSources(config ovhwrapper.Configuration, format string) {
+	switch format {
+	case "yaml":
+		fmt.Println(ovhwrapper.ToYaml(config.CredentialSources()))
+	case "json":
+		fmt.Println(ovhwrapper.ToJSON(config.CredentialSources()))
+	case "text":
+		fallthrough
+	default:
+		fmt.Printf("Profile: %s\n\n", config.ProfileName())
+		for _, source := range config.CredentialSources() {
+			fmt.Printf("%-7s %-19s %-19s %s\n", source.Role, source.Name, source.Source, source.Detail)
+		}
 	}
 }
 
 // Credentials returns information about the reader and writer accounts in different formats (yaml, json or text)
-func Credentials(reader, writer *ovh.Client, format string) {
-	rcred, err := ovhwrapper.GetCredential(reader)
+func Credentials(ctx context.Context, reader, writer ovhwrapper.API, format string) {
+	rcred, err := ovhwrapper.GetCredential(ctx, reader)
 	if err != nil {
-		log.Printf("Error getting reader credentials: %q\n", err)
+		log.Printf("Error getting reader credentials: %s\n", explainError(err))
 	}
-	wcred, err := ovhwrapper.GetCredential(writer)
+	wcred, err := ovhwrapper.GetCredential(ctx, writer)
 	if err != nil {
-		log.Printf("Error getting writer credentials: %q\n", err)
+		log.Printf("Error getting writer credentials: %s\n", explainError(err))
 	}
 
 	switch format {
@@ -113,15 +77,15 @@ func Credentials(reader, writer *ovh.Client, format string) {
 	}
 }
 
-func Logout(writer *ovh.Client, config ovhwrapper.Configuration) {
+func Logout(ctx context.Context, writer ovhwrapper.API, config ovhwrapper.Configuration) {
 	var result []byte
-	if err := writer.Post("/auth/logout", nil, &result); err != nil {
-		fmt.Printf("Error revoking consumer key: %q\n", err)
+	if err := writer.PostWithContext(ctx, "/auth/logout", nil, &result); err != nil {
+		fmt.Printf("Error revoking consumer key: %s\n", explainError(err))
 	}
 	fmt.Println(string(result))
 	config.Writer.ConsumerKey = ""
 
-	err := ovhwrapper.SaveYaml(config, config.GetPath())
+	err := config.Save()
 	if err != nil {
 		log.Printf("Error saving configuration: %v", err)
 	}
@@ -129,7 +93,7 @@ func Logout(writer *ovh.Client, config ovhwrapper.Configuration) {
 
 // List lists servicelines and their databases (when -a is set),
 // servicelines (when -s is not set) or databases (when -s is set)
-func List(client *ovh.Client, all bool, serviceid string) {
+func List(ctx context.Context, client ovhwrapper.API, all bool, serviceid string) {
 	if all { // list all servicelines and their databases
 		for _, sl := range GlobalInventory {
 			fmt.Printf("%-25s (%s)\n", sl.SLDetails.Description, sl.ID)
@@ -177,13 +141,13 @@ func List(client *ovh.Client, all bool, serviceid string) {
 
 // Status shows the current status of servicelines and their databases (when -a is set),
 // or a specific database (when -s and -d is set)
-func Status(client *ovh.Client, all bool, serviceline, db string) {
+func Status(ctx context.Context, client ovhwrapper.API, all bool, serviceline, db string) {
 }
 
 // Describe shows the details of a specific databases (when -a is not set),
 // a serviceline and all it's databases (when -s and -a are set)
 // or all servicelines and their databases (when only -a is set)
-func Describe(client *ovh.Client, all bool, serviceid, databaseid, output string) {
+func Describe(ctx context.Context, client ovhwrapper.API, all bool, serviceid, databaseid, output string) {
 	if !all { // describe one specific database
 		var sl ovhwrapper.ServiceLine
 		var db ovhwrapper.OVHDatabase
@@ -247,5 +211,5 @@ func Describe(client *ovh.Client, all bool, serviceid, databaseid, output string
 	}
 }
 
-func UpdateDatabase(reader, writer *ovh.Client, config ovhwrapper.Configuration, serviceid, db string) {
+func UpdateDatabase(ctx context.Context, reader, writer ovhwrapper.API, config ovhwrapper.Configuration, serviceid, db string) {
 }
@@ -0,0 +1,247 @@
+package ovhwrapper
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// Roles of the api clients.
+const (
+	RoleReader = "reader"
+	RoleWriter = "writer"
+)
+
+// Names of the credential values of a role.
+const (
+	CredApplicationKey    = "application_key"
+	CredApplicationSecret = "application_secret"
+	CredConsumerKey       = "consumer_key"
+)
+
+// Sources of credential values, in the order of precedence.
+const (
+	SourceEnvironment = "environment"
+	SourceFile        = "file"
+	SourceProcess     = "credential_process"
+	SourceKeyring     = "keyring"
+	SourceSecretFile  = "secret file"
+	SourceNone        = "not set"
+)
+
+var credentialNames = []string{CredApplicationKey, CredApplicationSecret, CredConsumerKey}
+
+// CredentialSource describes where a credential value of the active profile came from.
+type CredentialSource struct {
+	Role   string `yaml:"role" json:"role"`
+	Name   string `yaml:"name" json:"name"`
+	Source string `yaml:"source" json:"source"`
+	// Detail is the environment variable, the configuration file or the command the value was read from.
+	Detail string `yaml:"detail,omitempty" json:"detail,omitempty"`
+
+	value string
+}
+
+// ProcessCredentials are the credential values printed by a credential process.
+type ProcessCredentials struct {
+	ApplicationKey    string `json:"application_key"`
+	ApplicationSecret string `json:"application_secret"`
+	ConsumerKey       string `json:"consumer_key"`
+}
+
+func (p *ProcessCredentials) get(name string) string {
+	switch name {
+	case CredApplicationKey:
+		return p.ApplicationKey
+	case CredApplicationSecret:
+		return p.ApplicationSecret
+	case CredConsumerKey:
+		return p.ConsumerKey
+	}
+	return ""
+}
+
+// CredentialProcessOutput is the JSON a credential process has to print on stdout. The values on the top level
+// are used for both roles, the reader and writer objects override them for a single role:
+//
+//	{"application_key": "...", "application_secret": "...", "writer": {"consumer_key": "..."}}
+type CredentialProcessOutput struct {
+	ProcessCredentials
+	Reader *ProcessCredentials `json:"reader,omitempty"`
+	Writer *ProcessCredentials `json:"writer,omitempty"`
+}
+
+// value returns the credential of the given role, a role specific value wins over the common one.
+func (o *CredentialProcessOutput) value(role, name string) string {
+	override := o.Reader
+	if role == RoleWriter {
+		override = o.Writer
+	}
+	if override != nil && override.get(name) != "" {
+		return override.get(name)
+	}
+	return o.get(name)
+}
+
+// field returns a pointer to the named credential of the endpoint.
+func (e *Endpoint) field(name string) *string {
+	switch name {
+	case CredApplicationKey:
+		return &e.AppKey
+	case CredApplicationSecret:
+		return &e.AppSecret
+	default:
+		return &e.ConsumerKey
+	}
+}
+
+// role returns a pointer to the credentials of the given role of the active profile.
+func (c *Configuration) role(role string) *Endpoint {
+	if role == RoleWriter {
+		return &c.Writer
+	}
+	return &c.Reader
+}
+
+// CredentialEnvVars returns the environment variables checked for a credential of a role, the role specific
+// one (e.g. OVH_WRITER_CONSUMER_KEY) first, followed by the common one (e.g. OVH_CONSUMER_KEY).
+func CredentialEnvVars(role, name string) []string {
+	name = strings.ToUpper(name)
+	return []string{"OVH_" + strings.ToUpper(role) + "_" + name, "OVH_" + name}
+}
+
+// ResolveCredentials fills the reader and writer credentials of the active profile. The first source
+// providing a value wins:
+//  1. the role specific environment variable, e.g. OVH_READER_APPLICATION_KEY
+//  2. the common environment variable, e.g. OVH_APPLICATION_KEY, used for both roles
+//  3. the configuration file, references like keyring:<key> or secretfile:<key> are read from the secret store
+//  4. the output of the credential_process command of the profile
+//
+// The credential process is only run if a value is still missing. The source of every value can be
+// listed with CredentialSources.
+func (c *Configuration) ResolveCredentials() error {
+	c.sources = nil
+	var missing bool
+	for _, role := range []string{RoleReader, RoleWriter} {
+		for _, name := range credentialNames {
+			value := c.role(role).field(name)
+			source := CredentialSource{Role: role, Name: name, Source: SourceNone}
+			if env, v, ok := lookupEnv(CredentialEnvVars(role, name)); ok {
+				*value = v
+				source.Source, source.Detail = SourceEnvironment, env
+			} else if isSecretRef(*value) {
+				ref := *value
+				secret, err := c.readSecret(ref)
+				if err != nil {
+					return err
+				}
+				*value = secret
+				source.Source, source.Detail = SourceKeyring, ref
+				if strings.HasPrefix(ref, secretFilePrefix) {
+					source.Source = SourceSecretFile
+				}
+			} else if *value != "" {
+				source.Source, source.Detail = SourceFile, c.fileDetail()
+			} else {
+				missing = true
+			}
+			source.value = *value
+			c.sources = append(c.sources, source)
+		}
+	}
+
+	if !missing || c.CredentialProcess == "" {
+		return nil
+	}
+	output, err := runCredentialProcess(c.CredentialProcess)
+	if err != nil {
+		return err
+	}
+	for i := range c.sources {
+		source := &c.sources[i]
+		if source.Source != SourceNone {
+			continue
+		}
+		if v := output.value(source.Role, source.Name); v != "" {
+			*c.role(source.Role).field(source.Name) = v
+			source.Source, source.Detail, source.value = SourceProcess, c.CredentialProcess, v
+		}
+	}
+	return nil
+}
+
+// CredentialSources returns the source of every credential value of the active profile.
+func (c *Configuration) CredentialSources() []CredentialSource {
+	return c.sources
+}
+
+func (c *Configuration) fileDetail() string {
+	if c.profile == "" {
+		return c.fpath
+	}
+	return fmt.Sprintf("%s (profile %s)", c.fpath, c.profile)
+}
+
+// persisted returns the credentials of a role as they have to be written to the configuration file. Unchanged
+// values taken from the environment, the credential process or a secret store are replaced by the value of the
+// file. Changed values, e.g. a new consumer key, are saved in the secret store they were read from or in the
+// configured secret_store, otherwise they are written to the file.
+func (c *Configuration) persisted(role string, file Endpoint) (Endpoint, error) {
+	current := *c.role(role)
+	for _, source := range c.sources {
+		if source.Role != role {
+			continue
+		}
+		value, fileValue := current.field(source.Name), *file.field(source.Name)
+		switch {
+		case *value == source.value && source.Source != SourceFile:
+			*value = fileValue
+		case isSecretRef(fileValue):
+			ref, err := c.writeSecret(fileValue, *value)
+			if err != nil {
+				return current, err
+			}
+			*value = ref
+		case c.SecretStore != "" && *value != "" && *value != fileValue:
+			ref := secretRef(c.SecretStore, c.secretKey(c.ProfileName(), role, source.Name))
+			ref, err := c.writeSecret(ref, *value)
+			if err != nil {
+				return current, err
+			}
+			*value = ref
+		}
+	}
+	return current, nil
+}
+
+func lookupEnv(names []string) (string, string, bool) {
+	for _, name := range names {
+		if v, ok := os.LookupEnv(name); ok && v != "" {
+			return name, v, true
+		}
+	}
+	return "", "", false
+}
+
+// runCredentialProcess runs the command with the shell and decodes its output. Stdin and stderr are passed
+// through, so password managers can ask for a passphrase.
+func runCredentialProcess(command string) (*CredentialProcessOutput, error) {
+	var stdout bytes.Buffer
+	cmd := exec.Command("/bin/sh", "-c", command)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = &stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("running credential process %q: %w", command, err)
+	}
+
+	var output CredentialProcessOutput
+	if err := json.Unmarshal(stdout.Bytes(), &output); err != nil {
+		// the output is not part of the error, as it most likely contains secrets
+		return nil, fmt.Errorf("decoding output of credential process %q: invalid json", command)
+	}
+	return &output, nil
+}
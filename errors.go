@@ -0,0 +1,98 @@
+package ovhwrapper
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/ovh/go-ovh/ovh"
+)
+
+// Sentinel errors for the most common OVH API failures. Errors returned by the library functions can be
+// matched against them with errors.Is, e.g. errors.Is(err, ovhwrapper.ErrNotFound).
+var (
+	// ErrNotFound is matched if the requested resource does not exist (HTTP 404).
+	ErrNotFound = errors.New("resource not found")
+	// ErrForbidden is matched if the consumer key has no rights on the requested resource (HTTP 403).
+	ErrForbidden = errors.New("access forbidden")
+	// ErrUnauthorized is matched if the application or consumer key is invalid or expired
+	// (HTTP 401, or HTTP 403 with an invalid credential).
+	ErrUnauthorized = errors.New("invalid or expired credentials")
+	// ErrRateLimited is matched if the API rejected the request because of too many requests (HTTP 429).
+	ErrRateLimited = errors.New("rate limited")
+)
+
+// RequestError describes a failed request against the OVH API.
+// It keeps the method and path of the request and wraps the original error, so errors.As can still be
+// used to get the underlying *ovh.APIError, while errors.Is matches the sentinel errors above.
+type RequestError struct {
+	Method string
+	Path   string
+	Err    error
+}
+
+func (e *RequestError) Error() string {
+	return fmt.Sprintf("%s %s: %v", e.Method, e.Path, e.Err)
+}
+
+func (e *RequestError) Unwrap() error {
+	return e.Err
+}
+
+// Is maps the HTTP status code of the wrapped API error onto the sentinel errors.
+func (e *RequestError) Is(target error) bool {
+	apiErr, ok := AsAPIError(e.Err)
+	if !ok {
+		return false
+	}
+
+	switch target {
+	case ErrNotFound:
+		return apiErr.Code == http.StatusNotFound
+	case ErrUnauthorized:
+		return apiErr.Code == http.StatusUnauthorized || (apiErr.Code == http.StatusForbidden && invalidCredential(apiErr))
+	case ErrForbidden:
+		return apiErr.Code == http.StatusForbidden && !invalidCredential(apiErr)
+	case ErrRateLimited:
+		return apiErr.Code == http.StatusTooManyRequests
+	}
+	return false
+}
+
+// IsPermanent reports whether the error does not go away by repeating the request: the resource does not exist,
+// or the credentials are invalid or lack the rights for it.
+func IsPermanent(err error) bool {
+	return errors.Is(err, ErrNotFound) || errors.Is(err, ErrForbidden) || errors.Is(err, ErrUnauthorized)
+}
+
+// AsAPIError returns the *ovh.APIError wrapped in err, if any.
+func AsAPIError(err error) (*ovh.APIError, bool) {
+	var apiErrPtr *ovh.APIError
+	if errors.As(err, &apiErrPtr) {
+		return apiErrPtr, true
+	}
+	var apiErr ovh.APIError
+	if errors.As(err, &apiErr) {
+		return &apiErr, true
+	}
+	return nil, false
+}
+
+// invalidCredential checks if a 403 response was caused by an invalid or expired key instead of missing rights.
+// The OVH API uses 403 for both cases and only distinguishes them in the message.
+func invalidCredential(apiErr *ovh.APIError) bool {
+	msg := strings.ToLower(apiErr.Message)
+	return strings.Contains(msg, "credential is not valid") ||
+		strings.Contains(msg, "credential does not exist") ||
+		strings.Contains(msg, "invalid application key") ||
+		strings.Contains(msg, "invalid credential")
+}
+
+// wrapError wraps an error returned by the API client into a RequestError for the given request.
+func wrapError(method, path string, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &RequestError{Method: method, Path: path, Err: err}
+}
@@ -2,6 +2,7 @@ package ovhwrapper
 
 import (
 	"encoding/json"
+	"fmt"
 	"gopkg.in/yaml.v3"
 	"log"
 	"os"
@@ -34,14 +35,20 @@ func SaveYaml[T any](object T, fpath string) error {
 		return err
 	}
 
-	// create directory if necessaru
+	// create directory if necessary
 	dir := path.Dir(fpath)
 	err = os.MkdirAll(dir, 0700)
 	if err != nil {
-		log.Fatalf("Error creating %s directory: %v", dir, err)
+		return fmt.Errorf("creating %s directory: %w", dir, err)
 	}
 
-	err = os.WriteFile(fpath, y, 0644)
+	// the files contain credentials or kubeconfigs, so they are only readable by the user
+	err = os.WriteFile(fpath, y, 0600)
+	if err != nil {
+		return err
+	}
+	// WriteFile keeps the mode of an existing file
+	err = os.Chmod(fpath, 0600)
 	if err != nil {
 		return err
 	}
@@ -51,13 +58,12 @@ func SaveYaml[T any](object T, fpath string) error {
 func LoadYaml[T any](object T, fpath string) error {
 	srcFile, err := os.ReadFile(fpath)
 	if err != nil {
-		log.Printf("Can't read from %s: %v", fpath, err)
 		return err
 	}
 
 	err = yaml.Unmarshal(srcFile, &object)
 	if err != nil {
-		log.Fatalf("Unmarshal: %v", err)
+		return fmt.Errorf("unmarshaling %s: %w", fpath, err)
 	}
 	return nil
 }
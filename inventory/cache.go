@@ -0,0 +1,106 @@
+package inventory
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/snafuprinzip/ovhwrapper"
+)
+
+// DefaultCacheTTL is the time a snapshot is used before the inventory is gathered again.
+const DefaultCacheTTL = 15 * time.Minute
+
+// ErrNoSnapshot is returned by Cache.Load if no snapshot has been saved yet.
+var ErrNoSnapshot = errors.New("no inventory snapshot found")
+
+// Snapshot is the gathered inventory as stored in the cache.
+type Snapshot struct {
+	Created time.Time `json:"created"`
+	// Endpoint is the API endpoint the inventory was gathered from, a snapshot of a different endpoint
+	// is never used.
+	Endpoint     string                   `json:"endpoint"`
+	Servicelines []ovhwrapper.ServiceLine `json:"servicelines"`
+	Flavors      ovhwrapper.K8SFlavors    `json:"flavors,omitempty"`
+}
+
+// Age returns the time since the snapshot was created.
+func (s *Snapshot) Age() time.Duration {
+	return time.Since(s.Created)
+}
+
+// Cache stores inventory snapshots in a file.
+type Cache struct {
+	Path string
+	TTL  time.Duration
+}
+
+// DefaultCachePath returns the path of the cache file for the given name in the user cache directory,
+// e.g. ~/.cache/ovhwrapper/<name>.json.
+func DefaultCachePath(name string) string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		dir = os.TempDir()
+	}
+	return filepath.Join(dir, "ovhwrapper", name+".json")
+}
+
+// Load reads the snapshot from the cache file. It returns ErrNoSnapshot if the file does not exist.
+func (c Cache) Load() (*Snapshot, error) {
+	data, err := os.ReadFile(c.Path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrNoSnapshot
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading inventory cache %s: %w", c.Path, err)
+	}
+
+	var snapshot Snapshot
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		return nil, fmt.Errorf("decoding inventory cache %s: %w", c.Path, err)
+	}
+	return &snapshot, nil
+}
+
+// Fresh returns true if the snapshot was gathered from the given endpoint and is younger than the TTL.
+func (c Cache) Fresh(snapshot *Snapshot, endpoint string) bool {
+	ttl := c.TTL
+	if ttl == 0 {
+		ttl = DefaultCacheTTL
+	}
+	return snapshot != nil && snapshot.Endpoint == endpoint && snapshot.Age() < ttl
+}
+
+// Save writes the snapshot to the cache file, replacing the previous one atomically.
+// The file is only readable by the user, as the inventory contains internal addresses and names.
+func (c Cache) Save(snapshot *Snapshot) error {
+	data, err := json.Marshal(snapshot)
+	if err != nil {
+		return fmt.Errorf("encoding inventory cache: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(c.Path), 0o700); err != nil {
+		return fmt.Errorf("creating inventory cache directory: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(c.Path), filepath.Base(c.Path)+".*")
+	if err != nil {
+		return fmt.Errorf("creating inventory cache %s: %w", c.Path, err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("writing inventory cache %s: %w", c.Path, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("writing inventory cache %s: %w", c.Path, err)
+	}
+	if err := os.Rename(tmp.Name(), c.Path); err != nil {
+		return fmt.Errorf("writing inventory cache %s: %w", c.Path, err)
+	}
+	return nil
+}
@@ -0,0 +1,351 @@
+// Package inventory gathers the servicelines of an OVH account together with their kubernetes clusters
+// and databases. All API requests are run by a bounded pool of workers, errors of single resources are
+// collected instead of aborting the whole run, so a broken cluster only leaves a gap in the inventory.
+package inventory
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/snafuprinzip/ovhwrapper"
+)
+
+// DefaultConcurrency is the number of parallel API requests used if Options.Concurrency is not set.
+const DefaultConcurrency = 8
+
+// Resource kinds used in ResourceError.
+const (
+	KindServiceline = "serviceline"
+	KindCluster     = "cluster"
+	KindEtcd        = "etcd"
+	KindNodes       = "nodes"
+	KindNodepools   = "nodepools"
+	KindDatabase    = "database"
+)
+
+// Options configures a Gatherer.
+type Options struct {
+	// Concurrency limits the number of parallel API requests.
+	Concurrency int
+	// Clusters enables gathering the kubernetes clusters including etcd usage, nodes and nodepools.
+	Clusters bool
+	// Databases enables gathering the managed databases.
+	Databases bool
+}
+
+// ResourceError describes a resource which could not be gathered.
+type ResourceError struct {
+	Kind        string
+	Serviceline string
+	ID          string
+	Err         error
+}
+
+func (e *ResourceError) Error() string {
+	if e.Kind == KindServiceline {
+		return fmt.Sprintf("%s %s: %v", e.Kind, e.Serviceline, e.Err)
+	}
+	return fmt.Sprintf("%s %s in serviceline %s: %v", e.Kind, e.ID, e.Serviceline, e.Err)
+}
+
+func (e *ResourceError) Unwrap() error {
+	return e.Err
+}
+
+// Errors is the aggregated list of resource errors of a gather run.
+type Errors []*ResourceError
+
+func (e Errors) Error() string {
+	msgs := make([]string, len(e))
+	for i, err := range e {
+		msgs[i] = err.Error()
+	}
+	return fmt.Sprintf("%d resources could not be gathered:\n%s", len(e), strings.Join(msgs, "\n"))
+}
+
+// Unwrap allows errors.Is and errors.As to match any of the resource errors.
+func (e Errors) Unwrap() []error {
+	errs := make([]error, len(e))
+	for i, err := range e {
+		errs[i] = err
+	}
+	return errs
+}
+
+// AsErrors returns the resource errors contained in err, if any.
+func AsErrors(err error) (Errors, bool) {
+	var errs Errors
+	ok := errors.As(err, &errs)
+	return errs, ok
+}
+
+// Gatherer collects the inventory of an OVH account.
+type Gatherer struct {
+	client ovhwrapper.API
+	opts   Options
+}
+
+// New returns a Gatherer using the given client and options.
+func New(client ovhwrapper.API, opts Options) *Gatherer {
+	if opts.Concurrency <= 0 {
+		opts.Concurrency = DefaultConcurrency
+	}
+	return &Gatherer{client: client, opts: opts}
+}
+
+// Gather returns the inventory of all servicelines the client has access to.
+// If only single resources fail, the partial inventory is returned together with an Errors value listing
+// them. Clusters and databases which could not be fetched are left out of the inventory.
+// If the serviceline list itself cannot be fetched, a nil inventory and the error are returned.
+func (g *Gatherer) Gather(ctx context.Context) ([]ovhwrapper.ServiceLine, error) {
+	ids, err := ovhwrapper.GetServicelines(ctx, g.client)
+	if err != nil {
+		return nil, fmt.Errorf("getting serviceline list: %w", err)
+	}
+	return g.GatherServicelines(ctx, ids)
+}
+
+// GatherServicelines returns the inventory of the given servicelines, see Gather.
+func (g *Gatherer) GatherServicelines(ctx context.Context, ids []string) ([]ovhwrapper.ServiceLine, error) {
+	run := newRun(ctx, g)
+	servicelines := make([]ovhwrapper.ServiceLine, len(ids))
+	for i, id := range ids {
+		servicelines[i].ID = id
+		run.serviceline(&servicelines[i])
+	}
+	run.wait()
+
+	for i := range servicelines {
+		sl := &servicelines[i]
+		sl.Cluster = slices.DeleteFunc(sl.Cluster, func(cl ovhwrapper.K8SCluster) bool {
+			return run.failed(KindCluster, sl.ID, cl.ID)
+		})
+		sl.Databases = slices.DeleteFunc(sl.Databases, func(db ovhwrapper.OVHDatabase) bool {
+			return run.failed(KindDatabase, sl.ID, db.Id.String())
+		})
+	}
+
+	return servicelines, run.err()
+}
+
+// GatherCluster returns a single cluster including its etcd usage, nodes and nodepools.
+// If only the details fail, the cluster is returned together with an Errors value, if the cluster itself
+// cannot be fetched, nil is returned.
+func (g *Gatherer) GatherCluster(ctx context.Context, serviceline, clusterID string) (*ovhwrapper.K8SCluster, error) {
+	run := newRun(ctx, g)
+	cluster := ovhwrapper.K8SCluster{ID: clusterID}
+	run.cluster(serviceline, &cluster)
+	run.wait()
+
+	if run.failed(KindCluster, serviceline, clusterID) {
+		return nil, run.err()
+	}
+	return &cluster, run.err()
+}
+
+// run holds the state of one gather call: the worker pool, the task queue and the collected errors.
+type run struct {
+	*Gatherer
+	ctx context.Context
+
+	mu      sync.Mutex
+	cond    *sync.Cond
+	queue   []func()
+	pending int
+
+	errMu sync.Mutex
+	errs  Errors
+}
+
+func newRun(ctx context.Context, g *Gatherer) *run {
+	r := &run{Gatherer: g, ctx: ctx}
+	r.cond = sync.NewCond(&r.mu)
+	return r
+}
+
+// submit queues a task, tasks may submit further tasks while they are running.
+func (r *run) submit(task func()) {
+	r.mu.Lock()
+	r.queue = append(r.queue, task)
+	r.pending++
+	r.mu.Unlock()
+	r.cond.Signal()
+}
+
+// wait starts the workers and blocks until the queue is drained and all tasks are finished.
+func (r *run) wait() {
+	var wg sync.WaitGroup
+	for range r.opts.Concurrency {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			r.work()
+		}()
+	}
+	wg.Wait()
+}
+
+func (r *run) work() {
+	for {
+		r.mu.Lock()
+		for len(r.queue) == 0 && r.pending > 0 {
+			r.cond.Wait()
+		}
+		if r.pending == 0 {
+			r.mu.Unlock()
+			r.cond.Broadcast()
+			return
+		}
+		task := r.queue[0]
+		r.queue = r.queue[1:]
+		r.mu.Unlock()
+
+		task()
+
+		r.mu.Lock()
+		r.pending--
+		done := r.pending == 0
+		r.mu.Unlock()
+		if done {
+			r.cond.Broadcast()
+		}
+	}
+}
+
+// fail records the error of a single resource.
+func (r *run) fail(kind, serviceline, id string, err error) {
+	r.errMu.Lock()
+	r.errs = append(r.errs, &ResourceError{Kind: kind, Serviceline: serviceline, ID: id, Err: err})
+	r.errMu.Unlock()
+}
+
+// failed returns true if gathering the given resource itself failed.
+func (r *run) failed(kind, serviceline, id string) bool {
+	for _, err := range r.errs {
+		if err.Kind == kind && err.Serviceline == serviceline && err.ID == id {
+			return true
+		}
+	}
+	return false
+}
+
+// err returns the sorted resource errors or nil.
+func (r *run) err() error {
+	if len(r.errs) == 0 {
+		return nil
+	}
+	slices.SortFunc(r.errs, func(a, b *ResourceError) int {
+		return cmp.Or(
+			cmp.Compare(a.Serviceline, b.Serviceline),
+			cmp.Compare(a.Kind, b.Kind),
+			cmp.Compare(a.ID, b.ID),
+		)
+	})
+	return r.errs
+}
+
+// Every task below writes only into the fields of the resource it was given, so the tasks of one run
+// never write the same memory and no further locking of the inventory is needed.
+
+func (r *run) serviceline(sl *ovhwrapper.ServiceLine) {
+	r.submit(func() {
+		details, err := ovhwrapper.GetServicelineDetails(r.ctx, r.client, sl.ID)
+		if err != nil {
+			r.fail(KindServiceline, sl.ID, sl.ID, err)
+			return
+		}
+		sl.SLDetails = details
+	})
+
+	if r.opts.Clusters {
+		r.submit(func() {
+			ids, err := ovhwrapper.GetK8SClusterIDs(r.ctx, r.client, sl.ID)
+			if err != nil {
+				r.fail(KindCluster, sl.ID, "list", err)
+				return
+			}
+			sl.Cluster = make([]ovhwrapper.K8SCluster, len(ids))
+			for i, id := range ids {
+				sl.Cluster[i].ID = id
+				r.cluster(sl.ID, &sl.Cluster[i])
+			}
+		})
+	}
+
+	if r.opts.Databases {
+		r.submit(func() {
+			ids, err := ovhwrapper.GetDatabaseIDs(r.ctx, r.client, sl.ID)
+			if err != nil {
+				r.fail(KindDatabase, sl.ID, "list", err)
+				return
+			}
+			sl.Databases = make(ovhwrapper.OVHDatabases, len(ids))
+			for i, id := range ids {
+				sl.Databases[i].Id = id
+				r.database(sl.ID, &sl.Databases[i])
+			}
+		})
+	}
+}
+
+func (r *run) cluster(serviceline string, cluster *ovhwrapper.K8SCluster) {
+	r.submit(func() {
+		id := cluster.ID
+		details, err := ovhwrapper.GetK8SCluster(r.ctx, r.client, serviceline, id)
+		if err != nil || details == nil {
+			if err == nil {
+				err = ovhwrapper.ErrNotFound
+			}
+			r.fail(KindCluster, serviceline, id, err)
+			return
+		}
+		*cluster = *details
+		cluster.ID = id
+
+		r.submit(func() {
+			etcd, err := ovhwrapper.GetK8SEtcd(r.ctx, r.client, serviceline, id)
+			if err != nil {
+				r.fail(KindEtcd, serviceline, id, err)
+				return
+			}
+			cluster.EtcdUsage = etcd
+		})
+		r.submit(func() {
+			nodes, err := ovhwrapper.GetK8SNodes(r.ctx, r.client, serviceline, id)
+			if err != nil {
+				r.fail(KindNodes, serviceline, id, err)
+				return
+			}
+			cluster.Nodes = nodes
+		})
+		r.submit(func() {
+			nodepools, err := ovhwrapper.GetK8SNodepools(r.ctx, r.client, serviceline, id)
+			if err != nil {
+				r.fail(KindNodepools, serviceline, id, err)
+				return
+			}
+			cluster.Nodepools = nodepools
+		})
+	})
+}
+
+func (r *run) database(serviceline string, database *ovhwrapper.OVHDatabase) {
+	r.submit(func() {
+		id := database.Id
+		details, err := ovhwrapper.GetDatabase(r.ctx, r.client, serviceline, id)
+		if err != nil || details == nil {
+			if err == nil {
+				err = ovhwrapper.ErrNotFound
+			}
+			r.fail(KindDatabase, serviceline, id.String(), err)
+			return
+		}
+		*database = *details
+		database.Id = id
+	})
+}
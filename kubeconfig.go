@@ -1,11 +1,12 @@
 package ovhwrapper
 
 import (
+	"context"
 	"fmt"
-	"github.com/ovh/go-ovh/ovh"
 	"golang.org/x/term"
 	"gopkg.in/yaml.v3"
 	"log"
+	"net/http"
 	"os"
 	"slices"
 	"sort"
@@ -41,8 +42,17 @@ type Contexts struct {
 type Preferences struct {
 }
 type User struct {
-	ClientCertificateData string `yaml:"client-certificate-data"`
-	ClientKeyData         string `yaml:"client-key-data"`
+	ClientCertificateData string      `yaml:"client-certificate-data,omitempty"`
+	ClientKeyData         string      `yaml:"client-key-data,omitempty"`
+	Exec                  *ExecConfig `yaml:"exec,omitempty"`
+}
+
+// ExecConfig is a credential plugin called by kubectl to get a token for the user.
+type ExecConfig struct {
+	APIVersion      string   `yaml:"apiVersion"`
+	Command         string   `yaml:"command"`
+	Args            []string `yaml:"args,omitempty"`
+	InteractiveMode string   `yaml:"interactiveMode,omitempty"`
 }
 type Users struct {
 	Name string `yaml:"name"`
@@ -53,6 +63,7 @@ type Users struct {
 func ShortenName(name string) string {
 	shortname := name
 	shortname = strings.TrimPrefix(shortname, "kubernetes-admin@")
+	shortname = strings.TrimPrefix(shortname, "oidc@")
 	shortname = strings.TrimPrefix(shortname, "sl_")
 	shortname = strings.TrimPrefix(shortname, "ovh-k8s-")
 	shortname = strings.TrimPrefix(shortname, "sl-")
@@ -132,9 +143,21 @@ func (c *KubeConfig) AddContext(newConfig KubeConfig) {
 		log.Printf("Kontext %s existiert bereits in der globalen config und wurde daher nicht hinzugefuegt.",
 			newConfig.Contexts[0].Name)
 	} else {
-		c.Clusters = append(c.Clusters, newConfig.Clusters[0])
-		c.Contexts = append(c.Contexts, newConfig.Contexts[0])
-		c.Users = append(c.Users, newConfig.Users[0])
+		// take the cluster and user entries the context refers to, the config may contain other users
+		con := newConfig.Contexts[0]
+		for _, cl := range newConfig.Clusters {
+			if cl.Name == con.Context.Cluster {
+				c.Clusters = append(c.Clusters, cl)
+				break
+			}
+		}
+		c.Contexts = append(c.Contexts, con)
+		for _, user := range newConfig.Users {
+			if user.Name == con.Context.User {
+				c.Users = append(c.Users, user)
+				break
+			}
+		}
 	}
 }
 
@@ -147,7 +170,9 @@ func (c *KubeConfig) GetContext(contextname string) (int, *Contexts) {
 	return -1, nil
 }
 
-func (c *KubeConfig) RemoveContext(contextname string) {
+// RemoveContext removes the context and its cluster and user entries from the config.
+// It returns an error if the context does not exist.
+func (c *KubeConfig) RemoveContext(contextname string) error {
 	var confound, clfound, userfound bool
 	for conidx, con := range c.Contexts {
 		if con.Name == contextname {
@@ -179,11 +204,65 @@ func (c *KubeConfig) RemoveContext(contextname string) {
 		}
 	}
 	if !confound {
-		log.Fatalf("Kann Kontext %s nicht in Config finden.", contextname)
+		return fmt.Errorf("kann Kontext %s nicht in Config finden: %w", contextname, ErrNotFound)
+	}
+	return nil
+}
+
+// ClusterContexts returns the contexts of the config pointing to the given cluster, either by the server url
+// of their cluster entry or by the context name as created by GetKubeconfig and AddContext.
+func (c *KubeConfig) ClusterContexts(cluster K8SCluster) []Contexts {
+	servers := map[string]string{}
+	for _, cl := range c.Clusters {
+		servers[cl.Name] = cl.Cluster.Server
+	}
+
+	var contexts []Contexts
+	for _, con := range c.Contexts {
+		server := servers[con.Context.Cluster]
+		if (cluster.URL != "" && strings.Contains(server, cluster.URL)) ||
+			con.Name == "kubernetes-admin@"+cluster.Name || con.Name == ShortenName(cluster.Name) {
+			contexts = append(contexts, con)
+		}
 	}
+	return contexts
+}
+
+// UseOIDC points the contexts to users with the kubelogin exec plugin (kubectl oidc-login), which logs in at
+// the OpenID Connect provider of the cluster. Every context gets its own user, the users and contexts are named
+// oidc@<cluster>. Users no context refers to anymore, like the admin client certificate, are removed.
+func (c *KubeConfig) UseOIDC(oidc K8SOpenIDConnect) {
+	args := []string{"oidc-login", "get-token", "--oidc-issuer-url=" + oidc.IssuerURL, "--oidc-client-id=" + oidc.ClientID}
+	if oidc.CaContent != "" {
+		args = append(args, "--certificate-authority-data="+oidc.CaContent)
+	}
+
+	for i, con := range c.Contexts {
+		user := Users{Name: "oidc@" + con.Context.Cluster, User: User{Exec: &ExecConfig{
+			APIVersion:      "client.authentication.k8s.io/v1beta1",
+			Command:         "kubectl",
+			Args:            slices.Clone(args),
+			InteractiveMode: "IfAvailable",
+		}}}
+		if j := slices.IndexFunc(c.Users, func(u Users) bool { return u.Name == user.Name }); j >= 0 {
+			c.Users[j] = user
+		} else {
+			c.Users = append(c.Users, user)
+		}
+		c.Contexts[i].Context.User = user.Name
+
+		if c.CurrentContext == con.Name {
+			c.CurrentContext = "oidc@" + con.Context.Cluster
+		}
+		c.Contexts[i].Name = "oidc@" + con.Context.Cluster
+	}
+
+	c.Users = slices.DeleteFunc(c.Users, func(u Users) bool {
+		return !slices.ContainsFunc(c.Contexts, func(con Contexts) bool { return con.Context.User == u.Name })
+	})
 }
 
-func GetKubeconfig(client *ovh.Client, service, clusterid string) (KubeConfig, error) {
+func GetKubeconfig(ctx context.Context, client API, service, clusterid string) (KubeConfig, error) {
 	type kcresponse struct {
 		Content string `json:"content"`
 	}
@@ -192,25 +271,23 @@ func GetKubeconfig(client *ovh.Client, service, clusterid string) (KubeConfig, e
 	var kubeconfig KubeConfig
 
 	url := fmt.Sprintf("/cloud/project/%s/kube/%s/kubeconfig", service, clusterid)
-	if err := client.Post(url, nil, &response); err != nil {
-		fmt.Printf("Error recieving kubeconfig (url: %s): %q\n", url, err)
-		return kubeconfig, err
+	if err := client.PostWithContext(ctx, url, nil, &response); err != nil {
+		return kubeconfig, wrapError(http.MethodPost, url, err)
 	}
 
 	err := yaml.Unmarshal([]byte(response.Content), &kubeconfig)
 	if err != nil {
-		fmt.Printf("Error unmarshaling kubeconfig: %q\n", err)
-		return kubeconfig, err
+		return kubeconfig, fmt.Errorf("unmarshaling kubeconfig of cluster %s: %w", clusterid, err)
 	}
 	return kubeconfig, nil
 }
 
-func ResetKubeconfig(client *ovh.Client, service, clusterid string) (KubeConfig, error) {
+func ResetKubeconfig(ctx context.Context, client API, service, clusterid string) (KubeConfig, error) {
 	var kubeconfig KubeConfig
 
-	if err := client.Post("/cloud/project/"+service+"/kube/"+clusterid+"/kubeconfig/reset", nil, &kubeconfig); err != nil {
-		fmt.Printf("Error resetting kubeconfig: %q\n", err)
-		return kubeconfig, err
+	path := "/cloud/project/" + service + "/kube/" + clusterid + "/kubeconfig/reset"
+	if err := client.PostWithContext(ctx, path, nil, &kubeconfig); err != nil {
+		return kubeconfig, wrapError(http.MethodPost, path, err)
 	}
 
 	//fmt.Println("Description updated")
@@ -1,19 +1,27 @@
 package ovhwrapper
 
 import (
+	"context"
 	"fmt"
 	"github.com/ovh/go-ovh/ovh"
 	"gopkg.in/ini.v1"
 	"log"
+	"net/http"
 	"os"
 	"path"
+	"strings"
 	"time"
 )
 
+// DefaultEndpoint is the OVH API endpoint used if no endpoint is configured.
+const DefaultEndpoint = "ovh-eu"
+
 type Endpoint struct {
 	AppKey      string `ini:"application_key" yaml:"application_key"`
 	AppSecret   string `ini:"application_secret" yaml:"application_secret"`
 	ConsumerKey string `ini:"consumer_key" yaml:"consumer_key"`
+	// APIEndpoint overrides the endpoint of the configuration for this role only.
+	APIEndpoint string `ini:"-" yaml:"endpoint,omitempty"`
 }
 
 type OVHConfig struct {
@@ -23,10 +31,30 @@ type OVHConfig struct {
 	EU Endpoint `ini:"ovh-eu"`
 }
 
+// Configuration is the content of the ovhcredentials.conf file. The settings on the top level form the
+// default profile, further OVH accounts can be configured as named profiles, see ReadProfile.
 type Configuration struct {
-	fpath  string
-	Reader Endpoint `yaml:"reader" json:"reader"`
-	Writer Endpoint `yaml:"writer" json:"writer"`
+	fpath   string
+	profile string
+	// base keeps the default profile as read from the file, while Profile holds the active profile
+	base Profile
+	// sources records where the credentials of the active profile came from
+	sources []CredentialSource
+	// stores are the secret stores opened while resolving or saving credentials
+	stores *secretStores
+	// warnings are problems found while reading the configuration which do not prevent using it
+	warnings []string
+
+	Profile        `yaml:",inline"`
+	DefaultProfile string             `yaml:"default_profile,omitempty" json:"default_profile,omitempty"`
+	Profiles       map[string]Profile `yaml:"profiles,omitempty" json:"profiles,omitempty"`
+}
+
+// CacheConfig configures where the gathered inventory is cached and for how long it is used.
+// An empty path uses the default location in the user cache directory.
+type CacheConfig struct {
+	Path string        `yaml:"path,omitempty" json:"path,omitempty"`
+	TTL  time.Duration `yaml:"ttl,omitempty" json:"ttl,omitempty"`
 }
 
 // GetPath returns the path of the config file used previously.
@@ -35,6 +63,29 @@ func (c *Configuration) GetPath() string {
 	return c.fpath
 }
 
+// ReaderEndpoint returns the API endpoint used by the reader client.
+func (c *Configuration) ReaderEndpoint() string {
+	return c.endpoint(c.Reader)
+}
+
+// WriterEndpoint returns the API endpoint used by the writer client.
+func (c *Configuration) WriterEndpoint() string {
+	return c.endpoint(c.Writer)
+}
+
+// endpoint returns the endpoint of the given role, falling back to the endpoint of the configuration
+// and finally to DefaultEndpoint. Trailing slashes are removed, as the OVH client does not accept them.
+func (c *Configuration) endpoint(role Endpoint) string {
+	endpoint := role.APIEndpoint
+	if endpoint == "" {
+		endpoint = c.Endpoint
+	}
+	if endpoint == "" {
+		return DefaultEndpoint
+	}
+	return strings.TrimRight(endpoint, "/")
+}
+
 func ReadConfiguration() (Configuration, error) {
 	var config Configuration
 	homedir := os.Getenv("HOME")
@@ -51,87 +102,84 @@ func ReadConfiguration() (Configuration, error) {
 			if !os.IsNotExist(err) {
 				err := LoadYaml(&config, location)
 				if err != nil {
-					log.Printf("Error loading configuration file %s: %v", location, err)
-					return config, err
+					return config, fmt.Errorf("loading configuration file %s: %w", location, err)
 				}
 				config.fpath = location
 				break
 			}
 		}
 	}
+	config.base = config.Profile
 	return config, nil
 }
 
-func CreateClient() (*ovh.Client, error) {
-	client, err := ovh.NewEndpointClient("ovh-eu")
+// CreateClient creates a client for the given endpoint name or URL, reading the credentials from the
+// environment or the ovh.conf files of the go-ovh library.
+func CreateClient(endpoint string) (*ovh.Client, error) {
+	if endpoint == "" {
+		endpoint = DefaultEndpoint
+	}
+	client, err := ovh.NewEndpointClient(strings.TrimRight(endpoint, "/"))
 	if err != nil {
-		fmt.Printf("Error creating new endpoint client: %q\n", err)
-		return nil, err
+		return nil, fmt.Errorf("creating new endpoint client: %w", err)
 	}
 
 	return client, nil
 }
 
 func CreateReader(config Configuration) (*ovh.Client, error) {
-	client, err := ovh.NewClient("ovh-eu", config.Reader.AppKey, config.Reader.AppSecret,
+	client, err := ovh.NewClient(config.ReaderEndpoint(), config.Reader.AppKey, config.Reader.AppSecret,
 		config.Reader.ConsumerKey)
 	if err != nil {
-		fmt.Printf("Error creating new endpoint reader client: %q\n", err)
-		return nil, err
+		return nil, fmt.Errorf("creating new endpoint reader client: %w", err)
 	}
+	client.Client.Transport = NewRetryTransport(client.Client.Transport, config.Retry)
 
 	return client, nil
 }
 
 func CreateWriter(config Configuration) (*ovh.Client, error) {
-	client, err := ovh.NewClient("ovh-eu", config.Writer.AppKey, config.Writer.AppSecret,
+	client, err := ovh.NewClient(config.WriterEndpoint(), config.Writer.AppKey, config.Writer.AppSecret,
 		config.Writer.ConsumerKey)
 	if err != nil {
-		fmt.Printf("Error creating new endpoint writer client: %q\n", err)
-		return nil, err
+		return nil, fmt.Errorf("creating new endpoint writer client: %w", err)
 	}
+	client.Client.Transport = NewRetryTransport(client.Client.Transport, config.Retry)
 
 	return client, nil
 }
 
 // CreateConsumerKey generates a consumer key for the writer by making multiple API requests to set
 // up appropriate rules using the ovh.Client objects for reading and writing operations.
-// It uses the GetServicelines function to fetch a list of available services,
-// then calls the GetK8SClusterIDs function to retrieve a list of cluster IDs for each service.
-// For each cluster, it adds the necessary read and write rules to the ckReq object.
-// After running the request, it prints the validation URL and the generated consumer key for the writer.
-// It returns the writer's consumer key and any errors encountered during the process.
-func CreateConsumerKey(reader, writer *ovh.Client) (string, error) {
+// It uses the GetServicelines function to fetch a list of available services and grants the writer
+// GET, POST, PUT and DELETE on the kubernetes routes of each service, so it can create, change and delete
+// clusters, nodepools and nodes, including clusters created after the key.
+// It returns the writer's consumer key, the validation URL the user has to visit to validate the key
+// and any errors encountered during the process.
+// The writer has to be a concrete *ovh.Client, as the consumer key request is not part of the API interface.
+func CreateConsumerKey(ctx context.Context, reader API, writer *ovh.Client) (string, string, error) {
 	ckReq := writer.NewCkRequest()
 
 	// Allow GET method on /cloud and all its sub routes
 	//ckReq.AddRecursiveRules(ovh.ReadOnly, "/cloud")
 
-	for _, service := range GetServicelines(reader) {
-		clusterList, err := GetK8SClusterIDs(reader, service)
-		if err != nil {
-			fmt.Printf("Error getting cluster list: %q\n", err)
-			continue
-		}
+	services, err := GetServicelines(ctx, reader)
+	if err != nil {
+		return "", "", fmt.Errorf("getting serviceline list: %w", err)
+	}
 
-		for _, cluster := range clusterList {
-			ckReq.AddRecursiveRules(ovh.ReadWriteSafe, fmt.Sprintf("/cloud/project/%s/kube/%s/kubeconfig", service, cluster))
-			ckReq.AddRules(ovh.ReadWriteSafe, fmt.Sprintf("/cloud/project/%s/kube/%s/update", service, cluster))
-		}
+	for _, service := range services {
+		// /kube for creating clusters and /kube/* for everything below, e.g. DELETE /kube/{id}
+		ckReq.AddRecursiveRules(ovh.ReadWrite, fmt.Sprintf("/cloud/project/%s/kube", service))
 	}
 
 	// Run the request
 	response, err := ckReq.Do()
 	if err != nil {
-		fmt.Printf("Error: %q\n", err)
-		return "", err
+		return "", "", wrapError(http.MethodPost, "/auth/credential", err)
 	}
 
-	// Print the validation URL and the Consumer key
-	fmt.Printf("Generated consumer key: %s\n", response.ConsumerKey)
-	fmt.Printf("Please visit %s to validate it\n", response.ValidationURL)
-
-	return response.ConsumerKey, nil
+	return response.ConsumerKey, response.ValidationURL, nil
 }
 
 func ReadConfig(path string) *OVHConfig {
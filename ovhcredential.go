@@ -1,8 +1,9 @@
 package ovhwrapper
 
 import (
+	"context"
 	"fmt"
-	"github.com/ovh/go-ovh/ovh"
+	"net/http"
 	"time"
 )
 
@@ -42,10 +43,10 @@ func PrintCredential(cred *OVHCredential) {
 	fmt.Println(str)
 }
 
-func GetCredential(client *ovh.Client) (OVHCredential, error) {
+func GetCredential(ctx context.Context, client API) (OVHCredential, error) {
 	cred := OVHCredential{}
-	if err := client.Get("/auth/currentCredential", &cred); err != nil {
-		return cred, err
+	if err := client.GetWithContext(ctx, "/auth/currentCredential", &cred); err != nil {
+		return cred, wrapError(http.MethodGet, "/auth/currentCredential", err)
 	}
 	return cred, nil
 }
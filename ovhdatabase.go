@@ -1,10 +1,10 @@
 package ovhwrapper
 
 import (
-	"fmt"
+	"context"
+	"net/http"
 
 	"github.com/google/uuid"
-	"github.com/ovh/go-ovh/ovh"
 
 	"time"
 )
@@ -202,23 +202,22 @@ func (db OVHDatabase) DBEngine() string { return db.Engine }
 
 func (db OVHDatabase) DBVersion() string { return db.Version }
 
-func GetDatabaseIDs(client *ovh.Client, service string) ([]uuid.UUID, error) {
+func GetDatabaseIDs(ctx context.Context, client API, service string) ([]uuid.UUID, error) {
 	var dblist []uuid.UUID
 
-	if err := client.Get("/cloud/project/"+service+"/database/service", &dblist); err != nil {
-		fmt.Printf("Error getting database list: %q\n", err)
-		return dblist, err
+	path := "/cloud/project/" + service + "/database/service"
+	if err := client.GetWithContext(ctx, path, &dblist); err != nil {
+		return dblist, wrapError(http.MethodGet, path, err)
 	}
 
 	return dblist, nil
 }
 
-func GetDatabase(client *ovh.Client, service string, databaseID uuid.UUID) *OVHDatabase {
+func GetDatabase(ctx context.Context, client API, service string, databaseID uuid.UUID) (*OVHDatabase, error) {
 	var db OVHDatabase
-	id := databaseID.String()
-	if err := client.Get("/cloud/project/"+service+"/database/service/"+id, &db); err != nil {
-		fmt.Printf("Error getting database for %s in sl %s: %q\n", databaseID, service, err)
-		return nil
+	path := "/cloud/project/" + service + "/database/service/" + databaseID.String()
+	if err := client.GetWithContext(ctx, path, &db); err != nil {
+		return nil, wrapError(http.MethodGet, path, err)
 	}
-	return &db
+	return &db, nil
 }
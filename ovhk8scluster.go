@@ -1,9 +1,11 @@
 package ovhwrapper
 
 import (
+	"context"
 	"fmt"
-	"github.com/ovh/go-ovh/ovh"
-	"log"
+	"net/http"
+	"slices"
+	"strings"
 	"time"
 )
 
@@ -59,7 +61,7 @@ type K8SCluster struct {
 
 type AdmissionPlugins struct {
 	Enabled  []string `json:"enabled"`
-	Disabled []any    `json:"disabled"`
+	Disabled []string `json:"disabled"`
 }
 
 type APIServer struct {
@@ -124,80 +126,353 @@ func (pnc PrivateNetworkConfiguration) Details() string {
 }
 
 func (etcd K8SEtcd) Details() string {
-	return fmt.Sprintf(" etcd usage: %d%% (%d of %d)", etcd.Usage*100/etcd.Quota, etcd.Usage, etcd.Quota)
+	return fmt.Sprintf(" etcd usage: %d%% (%d of %d)", etcd.Percent(), etcd.Usage, etcd.Quota)
 }
 
-func GetK8SClusterIDs(client *ovh.Client, service string) ([]string, error) {
+func GetK8SClusterIDs(ctx context.Context, client API, service string) ([]string, error) {
 	var clusterlist []string
 
-	if err := client.Get("/cloud/project/"+service+"/kube", &clusterlist); err != nil {
-		fmt.Printf("Error getting k8s cluster list: %q\n", err)
-		return clusterlist, err
+	path := "/cloud/project/" + service + "/kube"
+	if err := client.GetWithContext(ctx, path, &clusterlist); err != nil {
+		return clusterlist, wrapError(http.MethodGet, path, err)
 	}
 
 	return clusterlist, nil
 }
 
-func GetK8SCluster(client *ovh.Client, service, clusterid string) *K8SCluster {
+func GetK8SCluster(ctx context.Context, client API, service, clusterid string) (*K8SCluster, error) {
 	var cluster K8SCluster
-	if err := client.Get("/cloud/project/"+service+"/kube/"+clusterid, &cluster); err != nil {
-		fmt.Printf("Error getting k8s cluster details for %s in sl %s: %q\n", clusterid, service, err)
-		return nil
+	path := "/cloud/project/" + service + "/kube/" + clusterid
+	if err := client.GetWithContext(ctx, path, &cluster); err != nil {
+		return nil, wrapError(http.MethodGet, path, err)
 	}
-	return &cluster
+	return &cluster, nil
 }
 
-func GetK8SClusterDetails(client *ovh.Client, cluster *K8SCluster, serviceid, clusterid string) (*K8SCluster, error) {
+func GetK8SClusterDetails(ctx context.Context, client API, cluster *K8SCluster, serviceid, clusterid string) (*K8SCluster, error) {
 	var err error
 
-	cluster.EtcdUsage, err = GetK8SEtcd(client, serviceid, clusterid)
+	cluster.EtcdUsage, err = GetK8SEtcd(ctx, client, serviceid, clusterid)
 	if err != nil {
-		log.Printf("Error getting etcd usage of cluster %s in SL %s: %v\n", serviceid, clusterid, err)
-		return nil, err
+		return nil, fmt.Errorf("getting etcd usage of cluster %s in SL %s: %w", clusterid, serviceid, err)
 	}
 
-	cluster.Nodepools, err = GetK8SNodepools(client, serviceid, clusterid)
+	cluster.Nodepools, err = GetK8SNodepools(ctx, client, serviceid, clusterid)
 	if err != nil {
-		log.Printf("Error getting nodepools of cluster %s in SL %s: %v\n", serviceid, clusterid, err)
-		return nil, err
+		return nil, fmt.Errorf("getting nodepools of cluster %s in SL %s: %w", clusterid, serviceid, err)
 	}
 
-	cluster.Nodes, err = GetK8SNodes(client, serviceid, clusterid)
+	cluster.Nodes, err = GetK8SNodes(ctx, client, serviceid, clusterid)
 	if err != nil {
-		log.Printf("Error getting nodes of cluster %s in SL %s: %v\n", serviceid, clusterid, err)
-		return nil, err
+		return nil, fmt.Errorf("getting nodes of cluster %s in SL %s: %w", clusterid, serviceid, err)
 	}
 
 	return cluster, nil
 }
 
-func UpdateK8SCluster(client *ovh.Client, service, clusterid string, latest, force bool) error {
+// Strategies of a cluster update.
+const (
+	UpdateStrategyNextMinor   = "NEXT_MINOR"
+	UpdateStrategyLatestPatch = "LATEST_PATCH"
+)
+
+// UpdateStrategy returns the strategy used by UpdateK8SCluster.
+func UpdateStrategy(latest bool) string {
+	if latest {
+		return UpdateStrategyLatestPatch
+	}
+	return UpdateStrategyNextMinor
+}
+
+func UpdateK8SCluster(ctx context.Context, client API, service, clusterid string, latest, force bool) error {
 	type UpdatePostParams struct {
 		Strategy string `json:"strategy"`
 		Force    bool   `json:"force"`
 	}
 	var params *UpdatePostParams
 
-	if latest {
-		params = &UpdatePostParams{Strategy: "LATEST_PATCH"}
-	} else {
-		params = &UpdatePostParams{Strategy: "NEXT_MINOR"}
-	}
+	params = &UpdatePostParams{Strategy: UpdateStrategy(latest)}
 
 	if params != nil {
 		params.Force = force
 	}
 
-	if err := client.Post("/cloud/project/"+service+"/kube/"+clusterid+"/update", &params, nil); err != nil {
-		fmt.Printf("Error updating cluster %s in SL %s: %q\n", service, clusterid, err)
+	path := "/cloud/project/" + service + "/kube/" + clusterid + "/update"
+	if err := client.PostWithContext(ctx, path, &params, nil); err != nil {
+		return wrapError(http.MethodPost, path, err)
+	}
+
+	return nil
+}
+
+// Update policies of a cluster.
+const (
+	UpdatePolicyAlways          = "ALWAYS_UPDATE"
+	UpdatePolicyMinimalDowntime = "MINIMAL_DOWNTIME"
+	UpdatePolicyNever           = "NEVER_UPDATE"
+)
+
+// K8SClusterSpec describes a cluster to create with CreateK8SCluster, it is usually read from a yaml file:
+//
+//	name: prod-a
+//	region: GRA7
+//	version: "1.31"
+//	privateNetwork:
+//	  id: 2a1b...
+//	  nodesSubnetId: 7c3d...
+//	updatePolicy: MINIMAL_DOWNTIME
+//	kubeProxyMode: ipvs
+//	admissionPlugins:
+//	  enabled: [NodeRestriction]
+//	  disabled: [AlwaysPullImages]
+//	nodepools:
+//	  - name: default
+//	    flavor: b3-8
+//	    desiredNodes: 3
+type K8SClusterSpec struct {
+	// Serviceline optionally names the serviceline (project) the cluster is created in.
+	Serviceline      string               `yaml:"serviceline,omitempty"`
+	Name             string               `yaml:"name"`
+	Region           string               `yaml:"region"`
+	Version          string               `yaml:"version,omitempty"`
+	PrivateNetwork   *K8SPrivateNetwork   `yaml:"privateNetwork,omitempty"`
+	UpdatePolicy     string               `yaml:"updatePolicy,omitempty"`
+	KubeProxyMode    string               `yaml:"kubeProxyMode,omitempty"`
+	AdmissionPlugins *AdmissionPluginSpec `yaml:"admissionPlugins,omitempty"`
+	// Nodepools are the initial nodepools, the first one is created together with the cluster, the others
+	// can only be added once the cluster is READY.
+	Nodepools []K8SNodepoolSpec `yaml:"nodepools,omitempty"`
+	// Clustergroup optionally names the clustergroup the cluster is registered in after its creation.
+	Clustergroup string `yaml:"clustergroup,omitempty"`
+}
+
+// K8SPrivateNetwork attaches a new cluster to a vRack private network.
+type K8SPrivateNetwork struct {
+	ID                    string `yaml:"id"`
+	NodesSubnetID         string `yaml:"nodesSubnetId,omitempty"`
+	LoadBalancersSubnetID string `yaml:"loadBalancersSubnetId,omitempty"`
+	DefaultVrackGateway   string `yaml:"defaultVrackGateway,omitempty"`
+	RoutingAsDefault      bool   `yaml:"routingAsDefault,omitempty"`
+}
+
+// AdmissionPluginSpec lists the admission plugins of the api server to enable or disable.
+type AdmissionPluginSpec struct {
+	Enabled  []string `yaml:"enabled,omitempty" json:"enabled,omitempty"`
+	Disabled []string `yaml:"disabled,omitempty" json:"disabled,omitempty"`
+}
+
+// Enable moves the plugin to the enabled plugins.
+func (s *AdmissionPluginSpec) Enable(plugin string) {
+	s.Disabled = slices.DeleteFunc(s.Disabled, func(p string) bool { return p == plugin })
+	if !slices.Contains(s.Enabled, plugin) {
+		s.Enabled = append(s.Enabled, plugin)
+	}
+}
+
+// Disable moves the plugin to the disabled plugins.
+func (s *AdmissionPluginSpec) Disable(plugin string) {
+	s.Enabled = slices.DeleteFunc(s.Enabled, func(p string) bool { return p == plugin })
+	if !slices.Contains(s.Disabled, plugin) {
+		s.Disabled = append(s.Disabled, plugin)
+	}
+}
+
+// Validate checks the spec for missing required fields and unknown values.
+func (s K8SClusterSpec) Validate() error {
+	switch {
+	case s.Name == "":
+		return fmt.Errorf("cluster name missing")
+	case s.Region == "":
+		return fmt.Errorf("cluster %s: region missing", s.Name)
+	case s.PrivateNetwork != nil && s.PrivateNetwork.ID == "":
+		return fmt.Errorf("cluster %s: private network id missing", s.Name)
+	}
+	if s.UpdatePolicy != "" {
+		if err := ValidateUpdatePolicy(s.UpdatePolicy); err != nil {
+			return fmt.Errorf("cluster %s: %w", s.Name, err)
+		}
+	}
+	switch s.KubeProxyMode {
+	case "", "iptables", "ipvs":
+	default:
+		return fmt.Errorf("cluster %s: unknown kube-proxy mode %s", s.Name, s.KubeProxyMode)
+	}
+
+	names := map[string]bool{}
+	for _, np := range s.Nodepools {
+		if err := np.Validate(); err != nil {
+			return fmt.Errorf("cluster %s: %w", s.Name, err)
+		}
+		if names[np.Name] {
+			return fmt.Errorf("cluster %s: nodepool %s defined twice", s.Name, np.Name)
+		}
+		names[np.Name] = true
+	}
+	return nil
+}
+
+// CreateK8SCluster creates a new cluster together with the first nodepool of the spec and returns it.
+// The cluster is INSTALLING afterwards, the remaining nodepools have to be created with CreateK8SNodepool
+// once it is READY.
+func CreateK8SCluster(ctx context.Context, client API, service string, spec K8SClusterSpec) (*K8SCluster, error) {
+	if err := spec.Validate(); err != nil {
+		return nil, err
+	}
+
+	type privateNetworkConfiguration struct {
+		DefaultVrackGateway            string `json:"defaultVrackGateway"`
+		PrivateNetworkRoutingAsDefault bool   `json:"privateNetworkRoutingAsDefault"`
+	}
+	type createParams struct {
+		Name                        string                       `json:"name"`
+		Region                      string                       `json:"region"`
+		Version                     string                       `json:"version,omitempty"`
+		PrivateNetworkID            string                       `json:"privateNetworkId,omitempty"`
+		NodesSubnetID               string                       `json:"nodesSubnetId,omitempty"`
+		LoadBalancersSubnetID       string                       `json:"loadBalancersSubnetId,omitempty"`
+		PrivateNetworkConfiguration *privateNetworkConfiguration `json:"privateNetworkConfiguration,omitempty"`
+		UpdatePolicy                string                       `json:"updatePolicy,omitempty"`
+		KubeProxyMode               string                       `json:"kubeProxyMode,omitempty"`
+		Customization               map[string]any               `json:"customization,omitempty"`
+		Nodepool                    *K8SNodepoolSpec             `json:"nodepool,omitempty"`
+	}
+
+	params := createParams{
+		Name:          spec.Name,
+		Region:        spec.Region,
+		Version:       spec.Version,
+		UpdatePolicy:  spec.UpdatePolicy,
+		KubeProxyMode: spec.KubeProxyMode,
+	}
+	if pn := spec.PrivateNetwork; pn != nil {
+		params.PrivateNetworkID = pn.ID
+		params.NodesSubnetID = pn.NodesSubnetID
+		params.LoadBalancersSubnetID = pn.LoadBalancersSubnetID
+		params.PrivateNetworkConfiguration = &privateNetworkConfiguration{
+			DefaultVrackGateway:            pn.DefaultVrackGateway,
+			PrivateNetworkRoutingAsDefault: pn.RoutingAsDefault,
+		}
+	}
+	if spec.AdmissionPlugins != nil {
+		params.Customization = map[string]any{
+			"apiServer": map[string]any{"admissionPlugins": spec.AdmissionPlugins},
+		}
+	}
+	if len(spec.Nodepools) > 0 {
+		params.Nodepool = &spec.Nodepools[0]
+	}
+
+	var cluster K8SCluster
+	path := "/cloud/project/" + service + "/kube"
+	if err := client.PostWithContext(ctx, path, &params, &cluster); err != nil {
+		return nil, wrapError(http.MethodPost, path, err)
+	}
+	return &cluster, nil
+}
+
+// ValidateUpdatePolicy checks that policy is one of UpdatePolicyAlways, UpdatePolicyMinimalDowntime or
+// UpdatePolicyNever.
+func ValidateUpdatePolicy(policy string) error {
+	switch policy {
+	case UpdatePolicyAlways, UpdatePolicyMinimalDowntime, UpdatePolicyNever:
+		return nil
+	}
+	return fmt.Errorf("unknown update policy %s, use %s, %s or %s", policy,
+		UpdatePolicyAlways, UpdatePolicyMinimalDowntime, UpdatePolicyNever)
+}
+
+// UpdateK8SClusterPolicy sets the update policy of a cluster to UpdatePolicyAlways, UpdatePolicyMinimalDowntime
+// or UpdatePolicyNever.
+func UpdateK8SClusterPolicy(ctx context.Context, client API, service, clusterid, policy string) error {
+	if err := ValidateUpdatePolicy(policy); err != nil {
 		return err
 	}
+	params := struct {
+		UpdatePolicy string `json:"updatePolicy"`
+	}{policy}
+
+	path := "/cloud/project/" + service + "/kube/" + clusterid + "/updatePolicy"
+	if err := client.PutWithContext(ctx, path, &params, nil); err != nil {
+		return wrapError(http.MethodPut, path, err)
+	}
+	return nil
+}
+
+// UpdateK8SClusterCustomization sets the enabled and disabled admission plugins of the api server of a cluster.
+// The api server is redeployed afterwards.
+func UpdateK8SClusterCustomization(ctx context.Context, client API, service, clusterid string, plugins AdmissionPluginSpec) error {
+	// both lists have to be sent, even if empty
+	params := Customization{APIServer: APIServer{AdmissionPlugins: AdmissionPlugins{
+		Enabled:  append([]string{}, plugins.Enabled...),
+		Disabled: append([]string{}, plugins.Disabled...),
+	}}}
+
+	path := "/cloud/project/" + service + "/kube/" + clusterid + "/customization"
+	if err := client.PutWithContext(ctx, path, &params, nil); err != nil {
+		return wrapError(http.MethodPut, path, err)
+	}
+	return nil
+}
 
+// DeleteK8SCluster deletes a cluster including all of its nodepools and nodes. The cluster is DELETING
+// afterwards until OVH has removed it.
+func DeleteK8SCluster(ctx context.Context, client API, service, clusterid string) error {
+	path := "/cloud/project/" + service + "/kube/" + clusterid
+	if err := client.DeleteWithContext(ctx, path, nil); err != nil {
+		return wrapError(http.MethodDelete, path, err)
+	}
 	return nil
 }
 
+// What happens to the worker nodes of a cluster on a reset.
+const (
+	WorkerNodesDelete    = "delete"
+	WorkerNodesReinstall = "reinstall"
+)
+
+// K8SClusterReset are the settings of a cluster after a reset. Empty values are left to OVH.
+type K8SClusterReset struct {
+	Name              string `json:"name,omitempty"`
+	Version           string `json:"version,omitempty"`
+	UpdatePolicy      string `json:"updatePolicy,omitempty"`
+	KubeProxyMode     string `json:"kubeProxyMode,omitempty"`
+	WorkerNodesPolicy string `json:"workerNodesPolicy,omitempty"`
+}
+
+// ResetK8SCluster reinstalls the control plane of a cluster with the given version, erasing all kubernetes
+// resources. The worker nodes are deleted or reinstalled depending on the WorkerNodesPolicy.
+func ResetK8SCluster(ctx context.Context, client API, service, clusterid string, reset K8SClusterReset) error {
+	switch reset.WorkerNodesPolicy {
+	case "", WorkerNodesDelete, WorkerNodesReinstall:
+	default:
+		return fmt.Errorf("unknown worker nodes policy %s, use %s or %s", reset.WorkerNodesPolicy,
+			WorkerNodesDelete, WorkerNodesReinstall)
+	}
+	path := "/cloud/project/" + service + "/kube/" + clusterid + "/reset"
+	if err := client.PostWithContext(ctx, path, &reset, nil); err != nil {
+		return wrapError(http.MethodPost, path, err)
+	}
+	return nil
+}
+
+// RestartK8SCluster restarts the control plane of a cluster, the worker nodes and workloads keep running.
+func RestartK8SCluster(ctx context.Context, client API, service, clusterid string) error {
+	path := "/cloud/project/" + service + "/kube/" + clusterid + "/restart"
+	if err := client.PostWithContext(ctx, path, nil, nil); err != nil {
+		return wrapError(http.MethodPost, path, err)
+	}
+	return nil
+}
+
+// Failed reports whether the cluster is in one of the error states like ERROR or USER_QUOTA_ERROR, or in another
+// state like SUSPENDED or USER_NODE_NOT_FOUND_OR_NOT_READY, from which it does not get READY on its own.
+func (cluster K8SCluster) Failed() bool {
+	return strings.HasSuffix(cluster.Status, "ERROR") || strings.HasPrefix(cluster.Status, "USER_") ||
+		cluster.Status == "SUSPENDED" || cluster.Status == "DELETED"
+}
+
 func (cluster K8SCluster) StatusMsg() string {
 	return fmt.Sprintf("  Cluster: %s\t[%s]\n  Version: %s (available: %v)\n  etcd: %d%% (%d of %d)",
 		cluster.Name, cluster.Status, cluster.Version, cluster.NextUpgradeVersions,
-		(cluster.EtcdUsage.Usage * 100 / cluster.EtcdUsage.Quota), cluster.EtcdUsage.Usage, cluster.EtcdUsage.Quota)
+		cluster.EtcdUsage.Percent(), cluster.EtcdUsage.Usage, cluster.EtcdUsage.Quota)
 }
@@ -1,8 +1,8 @@
 package ovhwrapper
 
 import (
-	"fmt"
-	"github.com/ovh/go-ovh/ovh"
+	"context"
+	"net/http"
 )
 
 type K8SEtcd struct {
@@ -10,11 +10,19 @@ type K8SEtcd struct {
 	Usage int `json:"usage"`
 }
 
-func GetK8SEtcd(client *ovh.Client, service, clusterid string) (K8SEtcd, error) {
+// Percent returns the etcd usage in percent of the quota, or 0 if the quota is unknown.
+func (etcd K8SEtcd) Percent() int {
+	if etcd.Quota <= 0 {
+		return 0
+	}
+	return etcd.Usage * 100 / etcd.Quota
+}
+
+func GetK8SEtcd(ctx context.Context, client API, service, clusterid string) (K8SEtcd, error) {
 	etcd := K8SEtcd{}
-	if err := client.Get("/cloud/project/"+service+"/kube/"+clusterid+"/metrics/etcdUsage", &etcd); err != nil {
-		fmt.Printf("Error getting k8s etcd usage: %q\n", err)
-		return etcd, err
+	path := "/cloud/project/" + service + "/kube/" + clusterid + "/metrics/etcdUsage"
+	if err := client.GetWithContext(ctx, path, &etcd); err != nil {
+		return etcd, wrapError(http.MethodGet, path, err)
 	}
 	return etcd, nil
 }
@@ -0,0 +1,69 @@
+package ovhwrapper
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/netip"
+	"net/url"
+)
+
+// ParseCIDR validates an ip restriction and returns it in canonical form. A single address is returned as /32
+// or /128 network.
+func ParseCIDR(s string) (string, error) {
+	if addr, err := netip.ParseAddr(s); err == nil {
+		return netip.PrefixFrom(addr, addr.BitLen()).String(), nil
+	}
+	prefix, err := netip.ParsePrefix(s)
+	if err != nil {
+		return "", fmt.Errorf("invalid ip restriction %q, use an address or a network like 192.0.2.0/24", s)
+	}
+	return prefix.Masked().String(), nil
+}
+
+// GetK8SIPRestrictions returns the networks which are allowed to reach the api server of a cluster. An empty list
+// means the api server is reachable from everywhere.
+func GetK8SIPRestrictions(ctx context.Context, client API, service, clusterid string) ([]string, error) {
+	var ips []string
+	path := "/cloud/project/" + service + "/kube/" + clusterid + "/ipRestrictions"
+	if err := client.GetWithContext(ctx, path, &ips); err != nil {
+		return nil, wrapError(http.MethodGet, path, err)
+	}
+	return ips, nil
+}
+
+// AddK8SIPRestrictions appends networks to the ip restrictions of the api server of a cluster.
+func AddK8SIPRestrictions(ctx context.Context, client API, service, clusterid string, ips []string) error {
+	params := struct {
+		IPs []string `json:"ips"`
+	}{ips}
+
+	path := "/cloud/project/" + service + "/kube/" + clusterid + "/ipRestrictions"
+	if err := client.PostWithContext(ctx, path, &params, nil); err != nil {
+		return wrapError(http.MethodPost, path, err)
+	}
+	return nil
+}
+
+// ReplaceK8SIPRestrictions replaces the ip restrictions of the api server of a cluster. An empty list removes all
+// restrictions.
+func ReplaceK8SIPRestrictions(ctx context.Context, client API, service, clusterid string, ips []string) error {
+	params := struct {
+		IPs []string `json:"ips"`
+	}{append([]string{}, ips...)}
+
+	path := "/cloud/project/" + service + "/kube/" + clusterid + "/ipRestrictions"
+	if err := client.PutWithContext(ctx, path, &params, nil); err != nil {
+		return wrapError(http.MethodPut, path, err)
+	}
+	return nil
+}
+
+// DeleteK8SIPRestriction removes a network from the ip restrictions of the api server of a cluster.
+func DeleteK8SIPRestriction(ctx context.Context, client API, service, clusterid, ip string) error {
+	path := "/cloud/project/" + service + "/kube/" + clusterid + "/ipRestrictions/" + url.PathEscape(ip)
+	if err := client.DeleteWithContext(ctx, path, nil); err != nil {
+		return wrapError(http.MethodDelete, path, err)
+	}
+	return nil
+}
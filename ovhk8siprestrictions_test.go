@@ -0,0 +1,35 @@
+package ovhwrapper
+
+import "testing"
+
+func TestParseCIDR(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    string
+		wantErr bool
+	}{
+		{"192.0.2.0/24", "192.0.2.0/24", false},
+		{"192.0.2.17/24", "192.0.2.0/24", false},
+		{"198.51.100.10", "198.51.100.10/32", false},
+		{"0.0.0.0/0", "0.0.0.0/0", false},
+		{"2001:db8::1", "2001:db8::1/128", false},
+		{"2001:db8:0:0::/32", "2001:db8::/32", false},
+		{"2001:db8::1/48", "2001:db8::/48", false},
+		{"192.0.2.0/33", "", true},
+		{"192.0.2.256", "", true},
+		{"192.0.2.0/", "", true},
+		{"office", "", true},
+		{"", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := ParseCIDR(tt.in)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseCIDR(%q) error = %v, want error %t", tt.in, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ParseCIDR(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
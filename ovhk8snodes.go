@@ -1,8 +1,9 @@
 package ovhwrapper
 
 import (
+	"context"
 	"fmt"
-	"github.com/ovh/go-ovh/ovh"
+	"net/http"
 	"time"
 )
 
@@ -36,41 +37,33 @@ type K8SNode struct {
 }
 
 type K8SNodepool struct {
-	Id             string    `json:"id"`
-	ProjectId      string    `json:"projectId"`
-	Name           string    `json:"name"`
-	Flavor         string    `json:"flavor"`
-	Status         string    `json:"status"`
-	SizeStatus     string    `json:"sizeStatus"`
-	Autoscale      bool      `json:"autoscale"`
-	MonthlyBilled  bool      `json:"monthlyBilled"`
-	AntiAffinity   bool      `json:"antiAffinity"`
-	DesiredNodes   int       `json:"desiredNodes"`
-	MinNodes       int       `json:"minNodes"`
-	MaxNodes       int       `json:"maxNodes"`
-	CurrentNodes   int       `json:"currentNodes"`
-	AvailableNodes int       `json:"availableNodes"`
-	UpToDateNodes  int       `json:"upToDateNodes"`
-	CreatedAt      time.Time `json:"createdAt"`
-	UpdatedAt      time.Time `json:"updatedAt"`
-	Autoscaling    struct {
-		ScaleDownUtilizationThreshold float64 `json:"scaleDownUtilizationThreshold"`
-		ScaleDownUnneededTimeSeconds  int     `json:"scaleDownUnneededTimeSeconds"`
-		ScaleDownUnreadyTimeSeconds   int     `json:"scaleDownUnreadyTimeSeconds"`
-	} `json:"autoscaling"`
-	Template struct {
-		Metadata struct {
-			Labels struct {
-			} `json:"labels"`
-			Annotations struct {
-			} `json:"annotations"`
-			Finalizers []interface{} `json:"finalizers"`
-		} `json:"metadata"`
-		Spec struct {
-			Unschedulable bool          `json:"unschedulable"`
-			Taints        []interface{} `json:"taints"`
-		} `json:"spec"`
-	} `json:"template"`
+	Id             string              `json:"id"`
+	ProjectId      string              `json:"projectId"`
+	Name           string              `json:"name"`
+	Flavor         string              `json:"flavor"`
+	Status         string              `json:"status"`
+	SizeStatus     string              `json:"sizeStatus"`
+	Autoscale      bool                `json:"autoscale"`
+	MonthlyBilled  bool                `json:"monthlyBilled"`
+	AntiAffinity   bool                `json:"antiAffinity"`
+	DesiredNodes   int                 `json:"desiredNodes"`
+	MinNodes       int                 `json:"minNodes"`
+	MaxNodes       int                 `json:"maxNodes"`
+	CurrentNodes   int                 `json:"currentNodes"`
+	AvailableNodes int                 `json:"availableNodes"`
+	UpToDateNodes  int                 `json:"upToDateNodes"`
+	CreatedAt      time.Time           `json:"createdAt"`
+	UpdatedAt      time.Time           `json:"updatedAt"`
+	Autoscaling    K8SAutoscaling      `json:"autoscaling"`
+	Template       K8SNodepoolTemplate `json:"template"`
+}
+
+// K8SAutoscaling tunes the cluster autoscaler of a nodepool: nodes with a lower utilization than the threshold
+// are removed after the unneeded time, nodes which are not ready after the unready time.
+type K8SAutoscaling struct {
+	ScaleDownUtilizationThreshold float64 `json:"scaleDownUtilizationThreshold"`
+	ScaleDownUnneededTimeSeconds  int     `json:"scaleDownUnneededTimeSeconds"`
+	ScaleDownUnreadyTimeSeconds   int     `json:"scaleDownUnreadyTimeSeconds"`
 }
 
 // K8SFlavor represents the flavor of a Kubernetes node.
@@ -81,6 +74,7 @@ type K8SNodepool struct {
 // - VCPUs: the number of virtual CPUs allocated to the flavor.
 // - GPUs: the number of GPUs allocated to the flavor.
 // - RAM: the amount of RAM allocated to the flavor.
+// - State: available or unavailable, no new nodes can be created with an unavailable flavor.
 
 type K8SFlavor struct {
 	Name     string `json:"name" yaml:"name"`
@@ -88,6 +82,12 @@ type K8SFlavor struct {
 	VCPUs    int    `json:"vCPUs" yaml:"vcpus"`
 	GPUs     int    `json:"gpus" yaml:"gpus"`
 	RAM      int    `json:"ram" yaml:"ram"`
+	State    string `json:"state,omitempty" yaml:"state,omitempty"`
+}
+
+// Available returns false if OVH can currently not provide new nodes of the flavor.
+func (f K8SFlavor) Available() bool {
+	return f.State != "unavailable"
 }
 
 // K8sNodes is a type that represents a list of K8SNode objects. It is used to store information about Kubernetes nodes.
@@ -103,81 +103,81 @@ type K8SNodepools []K8SNodepool
 type K8SFlavors map[string]K8SFlavor
 
 // GetK8SNodes retrieves the list of Kubernetes nodes in a given service and cluster ID.
-// It takes in an OVH client, the service name, and the cluster ID as parameters.
+// It takes in a context, an OVH API client, the service name, and the cluster ID as parameters.
 // It returns a K8sNodes slice representing the list of nodes and an error if any occurred.
 //
 // The K8sNodes slice is a collection of K8SNode structs. Each K8SNode struct contains information
 // about a node such as its ID, project ID, instance ID, node pool ID, name, flavor, status,
 // update status, version, creation timestamp, update timestamp, and deployment timestamp.
-func GetK8SNodes(client *ovh.Client, service, clusterid string) (K8sNodes, error) {
+func GetK8SNodes(ctx context.Context, client API, service, clusterid string) (K8sNodes, error) {
 	var nodelist K8sNodes
 	//	nodelist:=  make(K8sNodes, 3)
-	if err := client.Get("/cloud/project/"+service+"/kube/"+clusterid+"/node", &nodelist); err != nil {
-		fmt.Printf("Error getting k8s node list: %q\n", err)
-		return nodelist, err
+	path := "/cloud/project/" + service + "/kube/" + clusterid + "/node"
+	if err := client.GetWithContext(ctx, path, &nodelist); err != nil {
+		return nodelist, wrapError(http.MethodGet, path, err)
 	}
 
 	return nodelist, nil
 }
 
 // GetK8SNode retrieves the details of a specific Kubernetes node in a given service and cluster ID.
-// It takes in an OVH client, the service name, the cluster ID, and the node ID as parameters.
+// It takes in a context, an OVH API client, the service name, the cluster ID, and the node ID as parameters.
 // It returns a K8SNode struct representing the node and an error if any occurred.
 // The K8SNode struct contains information about the node such as its ID, project ID, instance ID,
 // node pool ID, name, flavor, status, update status, version, creation timestamp, update timestamp, and deployment timestamp.
-func GetK8SNode(client *ovh.Client, service, clusterid, nodeid string) (K8SNode, error) {
+func GetK8SNode(ctx context.Context, client API, service, clusterid, nodeid string) (K8SNode, error) {
 	var node K8SNode
 	//	nodelist:=  make(K8sNodes, 3)
-	if err := client.Get("/cloud/project/"+service+"/kube/"+clusterid+"/node/"+nodeid, &node); err != nil {
-		fmt.Printf("Error getting k8s node %s: %q\n", nodeid, err)
-		return node, err
+	path := "/cloud/project/" + service + "/kube/" + clusterid + "/node/" + nodeid
+	if err := client.GetWithContext(ctx, path, &node); err != nil {
+		return node, wrapError(http.MethodGet, path, err)
 	}
 
 	return node, nil
 }
 
 // GetK8SNodepools retrieves the list of Kubernetes node pools for a given service and cluster ID.
-// It takes in an OVH client, the service name, and the cluster ID as parameters.
+// It takes in a context, an OVH API client, the service name, and the cluster ID as parameters.
 // It returns a K8SNodepools struct and an error.
-func GetK8SNodepools(client *ovh.Client, service, clusterid string) (K8SNodepools, error) {
+func GetK8SNodepools(ctx context.Context, client API, service, clusterid string) (K8SNodepools, error) {
 	var nodepoollist K8SNodepools
 	//	nodelist:=  make(K8sNodes, 3)
-	if err := client.Get("/cloud/project/"+service+"/kube/"+clusterid+"/nodepool", &nodepoollist); err != nil {
-		fmt.Printf("Error getting k8s nodepool list: %q\n", err)
-		return nodepoollist, err
+	path := "/cloud/project/" + service + "/kube/" + clusterid + "/nodepool"
+	if err := client.GetWithContext(ctx, path, &nodepoollist); err != nil {
+		return nodepoollist, wrapError(http.MethodGet, path, err)
 	}
 
 	return nodepoollist, nil
 }
 
 // GetK8SNodepool retrieves information about a specific Kubernetes node pool for a given service, cluster, and pool ID.
-// It takes in an OVH client, the service name, the cluster ID, and the pool ID as parameters.
+// It takes in a context, an OVH API client, the service name, the cluster ID, and the pool ID as parameters.
 // It returns a K8SNodepool struct and an error. If there was an error retrieving the node pool information,
 // the returned error will contain a description of the problem.
 // Example usage:
 //
-//	nodepool, err := GetK8SNodepool(client, "my-service", "my-cluster", "my-pool")
+//	nodepool, err := GetK8SNodepool(ctx, client, "my-service", "my-cluster", "my-pool")
 //	if err != nil {
 //	    fmt.Printf("Error getting k8s node pool: %s\n", err.Error())
 //	    return
 //	}
 //	fmt.Printf("Node Pool ID: %s\n", nodepool.Id)
-func GetK8SNodepool(client *ovh.Client, service, clusterid, poolid string) (K8SNodepool, error) {
+func GetK8SNodepool(ctx context.Context, client API, service, clusterid, poolid string) (K8SNodepool, error) {
 	var nodepool K8SNodepool
-	if err := client.Get("/cloud/project/"+service+"/kube/"+clusterid+"/nodepool/"+poolid, &nodepool); err != nil {
-		fmt.Printf("Error getting k8s nodepool %s: %q\n", poolid, err)
-		return nodepool, err
+	path := "/cloud/project/" + service + "/kube/" + clusterid + "/nodepool/" + poolid
+	if err := client.GetWithContext(ctx, path, &nodepool); err != nil {
+		return nodepool, wrapError(http.MethodGet, path, err)
 	}
 
 	return nodepool, nil
 }
 
-func GetK8SFlavors(client *ovh.Client, service, clusterid string) (K8SFlavors, error) {
+func GetK8SFlavors(ctx context.Context, client API, service, clusterid string) (K8SFlavors, error) {
 	var flavors K8SFlavors = make(K8SFlavors, 4)
 	var flavorlist []K8SFlavor
-	if err := client.Get("/cloud/project/"+service+"/kube/"+clusterid+"/flavors", &flavorlist); err != nil {
-		fmt.Printf("Error getting k8s flavors: %q\n", err)
-		return flavors, err
+	path := "/cloud/project/" + service + "/kube/" + clusterid + "/flavors"
+	if err := client.GetWithContext(ctx, path, &flavorlist); err != nil {
+		return flavors, wrapError(http.MethodGet, path, err)
 	}
 
 	for _, flavor := range flavorlist {
@@ -249,6 +249,99 @@ func (p K8SNodepool) Details() string {
 		p.Id, p.Name, p.ProjectId, p.Status, p.SizeStatus, p.Flavor, p.MonthlyBilled, p.AntiAffinity, p.CreatedAt,
 		p.UpdatedAt, p.DesiredNodes, p.MinNodes, p.MaxNodes, p.CurrentNodes, p.AvailableNodes, p.UpToDateNodes,
 		p.Autoscale, p.Autoscaling.ScaleDownUtilizationThreshold, p.Autoscaling.ScaleDownUnneededTimeSeconds,
-		p.Autoscaling.ScaleDownUnreadyTimeSeconds, p.Template.Metadata.Labels, p.Template.Metadata.Annotations,
-		p.Template.Metadata.Finalizers, p.Template.Spec.Unschedulable, p.Template.Spec.Taints)
+		p.Autoscaling.ScaleDownUnreadyTimeSeconds, formatMap(p.Template.Metadata.Labels),
+		formatMap(p.Template.Metadata.Annotations), p.Template.Metadata.Finalizers, p.Template.Spec.Unschedulable,
+		p.Template.Spec.Taints)
+}
+
+// K8SNodepoolSpec describes a nodepool to create, either together with a new cluster or in an existing one.
+type K8SNodepoolSpec struct {
+	Name          string `yaml:"name" json:"name"`
+	Flavor        string `yaml:"flavor" json:"flavorName"`
+	DesiredNodes  int    `yaml:"desiredNodes" json:"desiredNodes"`
+	MinNodes      int    `yaml:"minNodes,omitempty" json:"minNodes,omitempty"`
+	MaxNodes      int    `yaml:"maxNodes,omitempty" json:"maxNodes,omitempty"`
+	Autoscale     bool   `yaml:"autoscale,omitempty" json:"autoscale"`
+	AntiAffinity  bool   `yaml:"antiAffinity,omitempty" json:"antiAffinity"`
+	MonthlyBilled bool   `yaml:"monthlyBilled,omitempty" json:"monthlyBilled"`
+}
+
+// Validate checks the spec for missing names and inconsistent node counts.
+func (s K8SNodepoolSpec) Validate() error {
+	switch {
+	case s.Name == "":
+		return fmt.Errorf("nodepool without name")
+	case s.Flavor == "":
+		return fmt.Errorf("nodepool %s: flavor missing", s.Name)
+	case s.DesiredNodes < 0 || s.MinNodes < 0 || s.MaxNodes < 0:
+		return fmt.Errorf("nodepool %s: negative node count", s.Name)
+	case s.MaxNodes > 0 && s.MinNodes > s.MaxNodes:
+		return fmt.Errorf("nodepool %s: minNodes %d is greater than maxNodes %d", s.Name, s.MinNodes, s.MaxNodes)
+	case s.DesiredNodes < s.MinNodes || (s.MaxNodes > 0 && s.DesiredNodes > s.MaxNodes):
+		return fmt.Errorf("nodepool %s: desiredNodes %d is not between minNodes %d and maxNodes %d",
+			s.Name, s.DesiredNodes, s.MinNodes, s.MaxNodes)
+	}
+	return nil
+}
+
+// CreateK8SNodepool creates a nodepool in an existing cluster, the cluster has to be READY.
+func CreateK8SNodepool(ctx context.Context, client API, service, clusterid string, spec K8SNodepoolSpec) (K8SNodepool, error) {
+	var nodepool K8SNodepool
+	if err := spec.Validate(); err != nil {
+		return nodepool, err
+	}
+	path := "/cloud/project/" + service + "/kube/" + clusterid + "/nodepool"
+	if err := client.PostWithContext(ctx, path, spec, &nodepool); err != nil {
+		return nodepool, wrapError(http.MethodPost, path, err)
+	}
+	return nodepool, nil
+}
+
+// ResizeK8SNodepool sets the desired, minimum and maximum number of nodes of a nodepool.
+func ResizeK8SNodepool(ctx context.Context, client API, service, clusterid, poolid string, desired, minNodes, maxNodes int) error {
+	params := struct {
+		DesiredNodes int `json:"desiredNodes"`
+		MinNodes     int `json:"minNodes"`
+		MaxNodes     int `json:"maxNodes"`
+	}{desired, minNodes, maxNodes}
+
+	path := "/cloud/project/" + service + "/kube/" + clusterid + "/nodepool/" + poolid
+	if err := client.PutWithContext(ctx, path, &params, nil); err != nil {
+		return wrapError(http.MethodPut, path, err)
+	}
+	return nil
+}
+
+// UpdateK8SNodepoolAutoscaling enables or disables the autoscaler of a nodepool and sets its parameters.
+func UpdateK8SNodepoolAutoscaling(ctx context.Context, client API, service, clusterid, poolid string, autoscale bool,
+	autoscaling K8SAutoscaling) error {
+	params := struct {
+		Autoscale   bool           `json:"autoscale"`
+		Autoscaling K8SAutoscaling `json:"autoscaling"`
+	}{autoscale, autoscaling}
+
+	path := "/cloud/project/" + service + "/kube/" + clusterid + "/nodepool/" + poolid
+	if err := client.PutWithContext(ctx, path, &params, nil); err != nil {
+		return wrapError(http.MethodPut, path, err)
+	}
+	return nil
+}
+
+// DeleteK8SNodepool deletes a nodepool together with all of its nodes.
+func DeleteK8SNodepool(ctx context.Context, client API, service, clusterid, poolid string) error {
+	path := "/cloud/project/" + service + "/kube/" + clusterid + "/nodepool/" + poolid
+	if err := client.DeleteWithContext(ctx, path, nil); err != nil {
+		return wrapError(http.MethodDelete, path, err)
+	}
+	return nil
+}
+
+// DeleteK8SNode deletes a node of a cluster. Depending on the nodepool, OVH lowers its desired number of nodes
+// or creates a new node in its place.
+func DeleteK8SNode(ctx context.Context, client API, service, clusterid, nodeid string) error {
+	path := "/cloud/project/" + service + "/kube/" + clusterid + "/node/" + nodeid
+	if err := client.DeleteWithContext(ctx, path, nil); err != nil {
+		return wrapError(http.MethodDelete, path, err)
+	}
+	return nil
 }
@@ -0,0 +1,125 @@
+package ovhwrapper
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// K8SOpenIDConnect configures the api server of a cluster to accept tokens of an OpenID Connect provider.
+type K8SOpenIDConnect struct {
+	IssuerURL         string   `json:"issuerUrl" yaml:"issuerUrl"`
+	ClientID          string   `json:"clientId" yaml:"clientId"`
+	UsernameClaim     string   `json:"usernameClaim,omitempty" yaml:"usernameClaim,omitempty"`
+	UsernamePrefix    string   `json:"usernamePrefix,omitempty" yaml:"usernamePrefix,omitempty"`
+	GroupsClaim       []string `json:"groupsClaim,omitempty" yaml:"groupsClaim,omitempty"`
+	GroupsPrefix      string   `json:"groupsPrefix,omitempty" yaml:"groupsPrefix,omitempty"`
+	RequiredClaim     []string `json:"requiredClaim,omitempty" yaml:"requiredClaim,omitempty"`
+	SigningAlgorithms []string `json:"signingAlgorithms,omitempty" yaml:"signingAlgorithms,omitempty"`
+	// base64 encoded ca certificate of the provider, if it is not signed by a public ca
+	CaContent string `json:"caContent,omitempty" yaml:"caContent,omitempty"`
+}
+
+// Validate checks that the issuer is a https url and the client id is set.
+func (o K8SOpenIDConnect) Validate() error {
+	issuer, err := url.Parse(o.IssuerURL)
+	if err != nil || issuer.Scheme != "https" || issuer.Host == "" {
+		return fmt.Errorf("issuer url %q of the OpenID Connect configuration is no https url", o.IssuerURL)
+	}
+	if o.ClientID == "" {
+		return fmt.Errorf("OpenID Connect configuration without client id")
+	}
+	return nil
+}
+
+// settings returns the values of the configuration by their api names, lists are joined by commas.
+func (o K8SOpenIDConnect) settings() map[string]string {
+	return map[string]string{
+		"issuerUrl":         o.IssuerURL,
+		"clientId":          o.ClientID,
+		"usernameClaim":     o.UsernameClaim,
+		"usernamePrefix":    o.UsernamePrefix,
+		"groupsClaim":       strings.Join(o.GroupsClaim, ","),
+		"groupsPrefix":      o.GroupsPrefix,
+		"requiredClaim":     strings.Join(o.RequiredClaim, ","),
+		"signingAlgorithms": strings.Join(o.SigningAlgorithms, ","),
+		"caContent":         o.CaContent,
+	}
+}
+
+// Diff returns the changes from the configuration to the other one, like K8SNodepoolTemplate.Diff.
+func (o K8SOpenIDConnect) Diff(other K8SOpenIDConnect) []string {
+	before, after := o.settings(), other.settings()
+	for key, value := range before {
+		if value == "" {
+			delete(before, key)
+		}
+		if after[key] == "" {
+			delete(after, key)
+		}
+	}
+	return diffMap("oidc", before, after)
+}
+
+func (o K8SOpenIDConnect) Details() string {
+	return fmt.Sprintf(" OpenID Connect:\n"+
+		"  Issuer URL: %s\n"+
+		"  Client ID: %s\n"+
+		"  Username Claim: %s\n"+
+		"  Username Prefix: %s\n"+
+		"  Groups Claim: %s\n"+
+		"  Groups Prefix: %s\n"+
+		"  Required Claim: %s\n"+
+		"  Signing Algorithms: %s",
+		o.IssuerURL, o.ClientID, o.UsernameClaim, o.UsernamePrefix, strings.Join(o.GroupsClaim, ", "),
+		o.GroupsPrefix, strings.Join(o.RequiredClaim, ", "), strings.Join(o.SigningAlgorithms, ", "))
+}
+
+// GetK8SOpenIDConnect returns the OpenID Connect configuration of a cluster. The error matches ErrNotFound if
+// the cluster has none.
+func GetK8SOpenIDConnect(ctx context.Context, client API, service, clusterid string) (*K8SOpenIDConnect, error) {
+	var oidc K8SOpenIDConnect
+	path := "/cloud/project/" + service + "/kube/" + clusterid + "/openIdConnect"
+	if err := client.GetWithContext(ctx, path, &oidc); err != nil {
+		return nil, wrapError(http.MethodGet, path, err)
+	}
+	return &oidc, nil
+}
+
+// CreateK8SOpenIDConnect configures OpenID Connect for a cluster without one. The api server is redeployed
+// afterwards.
+func CreateK8SOpenIDConnect(ctx context.Context, client API, service, clusterid string, oidc K8SOpenIDConnect) error {
+	if err := oidc.Validate(); err != nil {
+		return err
+	}
+	path := "/cloud/project/" + service + "/kube/" + clusterid + "/openIdConnect"
+	if err := client.PostWithContext(ctx, path, &oidc, nil); err != nil {
+		return wrapError(http.MethodPost, path, err)
+	}
+	return nil
+}
+
+// UpdateK8SOpenIDConnect replaces the OpenID Connect configuration of a cluster. The api server is redeployed
+// afterwards.
+func UpdateK8SOpenIDConnect(ctx context.Context, client API, service, clusterid string, oidc K8SOpenIDConnect) error {
+	if err := oidc.Validate(); err != nil {
+		return err
+	}
+	path := "/cloud/project/" + service + "/kube/" + clusterid + "/openIdConnect"
+	if err := client.PutWithContext(ctx, path, &oidc, nil); err != nil {
+		return wrapError(http.MethodPut, path, err)
+	}
+	return nil
+}
+
+// DeleteK8SOpenIDConnect removes the OpenID Connect configuration of a cluster, only client certificates are
+// accepted by the api server afterwards.
+func DeleteK8SOpenIDConnect(ctx context.Context, client API, service, clusterid string) error {
+	path := "/cloud/project/" + service + "/kube/" + clusterid + "/openIdConnect"
+	if err := client.DeleteWithContext(ctx, path, nil); err != nil {
+		return wrapError(http.MethodDelete, path, err)
+	}
+	return nil
+}
@@ -0,0 +1,173 @@
+package ovhwrapper
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"net/http"
+	"slices"
+	"strings"
+)
+
+// Effects of a taint.
+const (
+	TaintNoSchedule       = "NoSchedule"
+	TaintPreferNoSchedule = "PreferNoSchedule"
+	TaintNoExecute        = "NoExecute"
+)
+
+// K8SNodepoolTemplate is applied by OVH to every node of a nodepool.
+type K8SNodepoolTemplate struct {
+	Metadata K8SNodepoolMetadata     `json:"metadata" yaml:"metadata"`
+	Spec     K8SNodepoolTemplateSpec `json:"spec" yaml:"spec"`
+}
+
+// K8SNodepoolMetadata are the labels, annotations and finalizers of the nodes of a nodepool.
+type K8SNodepoolMetadata struct {
+	Labels      map[string]string `json:"labels" yaml:"labels,omitempty"`
+	Annotations map[string]string `json:"annotations" yaml:"annotations,omitempty"`
+	Finalizers  []string          `json:"finalizers" yaml:"finalizers,omitempty"`
+}
+
+// K8SNodepoolTemplateSpec are the taints of the nodes of a nodepool and whether new pods can be scheduled on them.
+type K8SNodepoolTemplateSpec struct {
+	Unschedulable bool    `json:"unschedulable" yaml:"unschedulable"`
+	Taints        []Taint `json:"taints" yaml:"taints,omitempty"`
+}
+
+// Taint keeps pods without a matching toleration away from a node.
+type Taint struct {
+	Key    string `json:"key" yaml:"key"`
+	Value  string `json:"value,omitempty" yaml:"value,omitempty"`
+	Effect string `json:"effect" yaml:"effect"`
+}
+
+// ParseTaint parses a taint in the notation of kubectl, key=value:Effect or key:Effect.
+func ParseTaint(s string) (Taint, error) {
+	var taint Taint
+	keyvalue, effect, ok := strings.Cut(s, ":")
+	if !ok {
+		return taint, fmt.Errorf("taint %q has no effect, use key=value:Effect", s)
+	}
+	taint.Key, taint.Value, _ = strings.Cut(keyvalue, "=")
+	taint.Effect = effect
+	return taint, taint.Validate()
+}
+
+// Validate checks the key and effect of the taint.
+func (t Taint) Validate() error {
+	if t.Key == "" {
+		return fmt.Errorf("taint %s without key", t)
+	}
+	switch t.Effect {
+	case TaintNoSchedule, TaintPreferNoSchedule, TaintNoExecute:
+		return nil
+	}
+	return fmt.Errorf("taint %s has unknown effect %q, use %s, %s or %s", t, t.Effect,
+		TaintNoSchedule, TaintPreferNoSchedule, TaintNoExecute)
+}
+
+// String returns the taint in the notation of kubectl.
+func (t Taint) String() string {
+	if t.Value == "" {
+		return t.Key + ":" + t.Effect
+	}
+	return t.Key + "=" + t.Value + ":" + t.Effect
+}
+
+// Clone returns a deep copy of the template, which can be changed without touching the original.
+func (t K8SNodepoolTemplate) Clone() K8SNodepoolTemplate {
+	t.Metadata.Labels = maps.Clone(t.Metadata.Labels)
+	t.Metadata.Annotations = maps.Clone(t.Metadata.Annotations)
+	t.Metadata.Finalizers = slices.Clone(t.Metadata.Finalizers)
+	t.Spec.Taints = slices.Clone(t.Spec.Taints)
+	return t
+}
+
+// SetTaint adds a taint, replacing a taint with the same key and effect.
+func (t *K8SNodepoolTemplate) SetTaint(taint Taint) {
+	t.RemoveTaint(taint.Key, taint.Effect)
+	t.Spec.Taints = append(t.Spec.Taints, taint)
+}
+
+// RemoveTaint removes the taints with the given key and effect, or all taints of the key if effect is empty.
+func (t *K8SNodepoolTemplate) RemoveTaint(key, effect string) {
+	t.Spec.Taints = slices.DeleteFunc(t.Spec.Taints, func(taint Taint) bool {
+		return taint.Key == key && (effect == "" || taint.Effect == effect)
+	})
+}
+
+// Diff returns the changes from the template to the other one, one line per label, annotation or taint,
+// prefixed with - for removed and + for added values. A changed value is shown as removed and added.
+func (t K8SNodepoolTemplate) Diff(other K8SNodepoolTemplate) []string {
+	var diff []string
+	diff = append(diff, diffMap("label", t.Metadata.Labels, other.Metadata.Labels)...)
+	diff = append(diff, diffMap("annotation", t.Metadata.Annotations, other.Metadata.Annotations)...)
+
+	for _, taint := range t.Spec.Taints {
+		if !slices.Contains(other.Spec.Taints, taint) {
+			diff = append(diff, "- taint "+taint.String())
+		}
+	}
+	for _, taint := range other.Spec.Taints {
+		if !slices.Contains(t.Spec.Taints, taint) {
+			diff = append(diff, "+ taint "+taint.String())
+		}
+	}
+
+	if t.Spec.Unschedulable != other.Spec.Unschedulable {
+		diff = append(diff, fmt.Sprintf("- unschedulable %t", t.Spec.Unschedulable),
+			fmt.Sprintf("+ unschedulable %t", other.Spec.Unschedulable))
+	}
+	return diff
+}
+
+func diffMap(kind string, before, after map[string]string) []string {
+	var diff []string
+	for _, key := range slices.Sorted(maps.Keys(before)) {
+		if value, ok := after[key]; !ok || value != before[key] {
+			diff = append(diff, fmt.Sprintf("- %s %s=%s", kind, key, before[key]))
+		}
+	}
+	for _, key := range slices.Sorted(maps.Keys(after)) {
+		if value, ok := before[key]; !ok || value != after[key] {
+			diff = append(diff, fmt.Sprintf("+ %s %s=%s", kind, key, after[key]))
+		}
+	}
+	return diff
+}
+
+// formatMap returns the entries of a map sorted by key as key=value, separated by commas.
+func formatMap(m map[string]string) string {
+	entries := make([]string, 0, len(m))
+	for _, key := range slices.Sorted(maps.Keys(m)) {
+		entries = append(entries, key+"="+m[key])
+	}
+	return strings.Join(entries, ", ")
+}
+
+// UpdateK8SNodepoolTemplate replaces the template of a nodepool. OVH applies it to the existing and new nodes.
+func UpdateK8SNodepoolTemplate(ctx context.Context, client API, service, clusterid, poolid string, template K8SNodepoolTemplate) error {
+	// send empty objects and lists instead of null
+	if template.Metadata.Labels == nil {
+		template.Metadata.Labels = map[string]string{}
+	}
+	if template.Metadata.Annotations == nil {
+		template.Metadata.Annotations = map[string]string{}
+	}
+	if template.Metadata.Finalizers == nil {
+		template.Metadata.Finalizers = []string{}
+	}
+	if template.Spec.Taints == nil {
+		template.Spec.Taints = []Taint{}
+	}
+	params := struct {
+		Template K8SNodepoolTemplate `json:"template"`
+	}{template}
+
+	path := "/cloud/project/" + service + "/kube/" + clusterid + "/nodepool/" + poolid
+	if err := client.PutWithContext(ctx, path, &params, nil); err != nil {
+		return wrapError(http.MethodPut, path, err)
+	}
+	return nil
+}
@@ -0,0 +1,45 @@
+package ovhwrapper
+
+import "testing"
+
+func TestParseTaint(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    Taint
+		wantErr bool
+	}{
+		{"dedicated=gpu:NoSchedule", Taint{Key: "dedicated", Value: "gpu", Effect: TaintNoSchedule}, false},
+		{"dedicated:NoExecute", Taint{Key: "dedicated", Effect: TaintNoExecute}, false},
+		{"example.com/spot=true:PreferNoSchedule", Taint{Key: "example.com/spot", Value: "true", Effect: TaintPreferNoSchedule}, false},
+		{"dedicated=:NoSchedule", Taint{Key: "dedicated", Effect: TaintNoSchedule}, false},
+		{"dedicated=gpu", Taint{}, true},
+		{"dedicated=gpu:", Taint{}, true},
+		{"dedicated=gpu:noschedule", Taint{}, true},
+		{"=gpu:NoSchedule", Taint{}, true},
+		{":NoSchedule", Taint{}, true},
+		{"", Taint{}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := ParseTaint(tt.in)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseTaint(%q) error = %v, want error %t", tt.in, err, tt.wantErr)
+			}
+			if err == nil && got != tt.want {
+				t.Errorf("ParseTaint(%q) = %+v, want %+v", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTaintString(t *testing.T) {
+	for _, s := range []string{"dedicated=gpu:NoSchedule", "dedicated:NoExecute"} {
+		taint, err := ParseTaint(s)
+		if err != nil {
+			t.Fatalf("ParseTaint(%q) error = %v", s, err)
+		}
+		if got := taint.String(); got != s {
+			t.Errorf("ParseTaint(%q).String() = %q", s, got)
+		}
+	}
+}
@@ -0,0 +1,57 @@
+package ovhwrapper
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// minorVersion parses the major and minor number of a kubernetes version like 1.30 or 1.30.2.
+func minorVersion(version string) (int, int, error) {
+	parts := strings.SplitN(strings.TrimPrefix(version, "v"), ".", 3)
+	if len(parts) < 2 {
+		return 0, 0, fmt.Errorf("invalid kubernetes version %q, use major.minor like 1.30", version)
+	}
+	major, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid kubernetes version %q, use major.minor like 1.30", version)
+	}
+	minor, err := strconv.Atoi(parts[1])
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid kubernetes version %q, use major.minor like 1.30", version)
+	}
+	return major, minor, nil
+}
+
+// SameMinorVersion reports whether both versions have the same major and minor number, e.g. 1.30 and 1.30.2.
+func SameMinorVersion(a, b string) bool {
+	amajor, aminor, aerr := minorVersion(a)
+	bmajor, bminor, berr := minorVersion(b)
+	return aerr == nil && berr == nil && amajor == bmajor && aminor == bminor
+}
+
+// UpgradePath returns the minor versions a cluster has to be upgraded through to get from the current to the
+// target version, as OVH only upgrades one minor version at a time. 1.27 to 1.30 gives [1.28 1.29 1.30], the
+// path is empty if the cluster already runs the target version.
+func UpgradePath(current, target string) ([]string, error) {
+	curMajor, curMinor, err := minorVersion(current)
+	if err != nil {
+		return nil, err
+	}
+	major, minor, err := minorVersion(target)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case major != curMajor:
+		return nil, fmt.Errorf("can not upgrade from %s to another major version %s", current, target)
+	case minor < curMinor:
+		return nil, fmt.Errorf("can not downgrade from %s to %s", current, target)
+	}
+
+	var path []string
+	for m := curMinor + 1; m <= minor; m++ {
+		path = append(path, fmt.Sprintf("%d.%d", major, m))
+	}
+	return path, nil
+}
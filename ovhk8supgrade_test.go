@@ -0,0 +1,58 @@
+package ovhwrapper
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestUpgradePath(t *testing.T) {
+	tests := []struct {
+		current, target string
+		want            []string
+		wantErr         bool
+	}{
+		{"1.27", "1.30", []string{"1.28", "1.29", "1.30"}, false},
+		{"1.29", "1.30", []string{"1.30"}, false},
+		{"1.30", "1.30", nil, false},
+		{"1.30.2", "1.30", nil, false},
+		{"v1.29.5", "1.31", []string{"1.30", "1.31"}, false},
+		{"1.29", "v1.30", []string{"1.30"}, false},
+		{"1.30", "1.29", nil, true},
+		{"1.30", "2.0", nil, true},
+		{"1.30", "latest", nil, true},
+		{"1", "1.30", nil, true},
+		{"", "1.30", nil, true},
+		{"1.x", "1.30", nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.current+"->"+tt.target, func(t *testing.T) {
+			got, err := UpgradePath(tt.current, tt.target)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("UpgradePath(%q, %q) error = %v, want error %t", tt.current, tt.target, err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("UpgradePath(%q, %q) = %v, want %v", tt.current, tt.target, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSameMinorVersion(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want bool
+	}{
+		{"1.30", "1.30", true},
+		{"1.30.2", "1.30", true},
+		{"v1.30", "1.30.5", true},
+		{"1.30", "1.31", false},
+		{"1.30", "2.30", false},
+		{"1.30", "", false},
+		{"", "", false},
+	}
+	for _, tt := range tests {
+		if got := SameMinorVersion(tt.a, tt.b); got != tt.want {
+			t.Errorf("SameMinorVersion(%q, %q) = %t, want %t", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,182 @@
+package ovhwrapper
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// WaitOutcome is how waiting for a cluster ended.
+type WaitOutcome string
+
+const (
+	// WaitSucceeded: the cluster is READY
+	WaitSucceeded WaitOutcome = "succeeded"
+	// WaitFailed: the cluster is in a state it does not get READY from on its own, see K8SCluster.Failed, or it is
+	// gone or not accessible anymore
+	WaitFailed WaitOutcome = "failed"
+	// WaitTimedOut: the cluster did not get READY in time or got stuck
+	WaitTimedOut WaitOutcome = "timed out"
+	// WaitCancelled: the context got cancelled, e.g. by ctrl-c
+	WaitCancelled WaitOutcome = "cancelled"
+)
+
+// WaitResult is the outcome of waiting for a cluster.
+type WaitResult struct {
+	Outcome WaitOutcome
+	// Reason explains why waiting did not succeed, e.g. the error state or the state the cluster got stuck in.
+	Reason string
+	// Cluster is the last state of the cluster, nil if it could not be fetched at all.
+	Cluster  *K8SCluster
+	Duration time.Duration
+}
+
+// Succeeded reports whether the cluster got READY.
+func (r WaitResult) Succeeded() bool {
+	return r.Outcome == WaitSucceeded
+}
+
+func (r WaitResult) String() string {
+	s := fmt.Sprintf("%s after %s", r.Outcome, r.Duration.Round(time.Second))
+	if r.Reason != "" {
+		s += ": " + r.Reason
+	}
+	return s
+}
+
+// WaitFailure is returned by a WaitCheck if what is waited for is in a state it does not recover from on its own.
+type WaitFailure string
+
+func (f WaitFailure) Error() string {
+	return string(f)
+}
+
+// WaitCheck polls the state of what is waited for. It returns a description of the progress and whether waiting
+// is done. A WaitFailure or an error of IsPermanent ends waiting as failed, other errors are polled again.
+type WaitCheck func(ctx context.Context) (progress string, done bool, err error)
+
+// ClusterWaiter waits for a cluster to get READY after a change like an update, a reset or a redeployment of the
+// api server, or with WaitFor for any other check, e.g. of a nodepool. It gives up when the check fails, the
+// timeout is reached or nothing changed for StuckAfter.
+type ClusterWaiter struct {
+	// Delay gives the change time to get triggered before the first poll, as the cluster is still READY
+	// right after it has been started.
+	Delay time.Duration
+	// Interval between two polls, 30 seconds if not set.
+	Interval time.Duration
+	// Timeout for the whole wait including the delay, 0 waits without a time limit.
+	Timeout time.Duration
+	// StuckAfter gives up if the progress does not change for this time, 0 disables the check.
+	StuckAfter time.Duration
+	// Progress describes the state of the cluster in Wait, e.g. including the states of its nodes. It defaults to
+	// the status of the cluster.
+	Progress func(cluster *K8SCluster) string
+	// OnProgress is called whenever the progress changes.
+	OnProgress func(progress string)
+	// OnError is called if the check failed because of a transient error, the waiter keeps polling. If the
+	// cluster is not found or access to it is denied, waiting fails.
+	OnError func(err error)
+}
+
+// Wait polls the cluster until it is READY, has failed or is gone, the timeout is reached, it got stuck or the
+// context got cancelled.
+func (w ClusterWaiter) Wait(ctx context.Context, client API, service, clusterid string) WaitResult {
+	progress := w.Progress
+	if progress == nil {
+		progress = func(cluster *K8SCluster) string { return cluster.Status }
+	}
+
+	var last *K8SCluster
+	result := w.WaitFor(ctx, func(ctx context.Context) (string, bool, error) {
+		cluster, err := GetK8SCluster(ctx, client, service, clusterid)
+		if err != nil {
+			return "", false, err
+		}
+		last = cluster
+		if cluster.Failed() {
+			return progress(cluster), false, WaitFailure("cluster is " + cluster.Status)
+		}
+		return progress(cluster), cluster.Status == "READY", nil
+	})
+	result.Cluster = last
+	if result.Outcome == WaitTimedOut && last != nil {
+		result.Reason = strings.TrimPrefix(result.Reason+", cluster is "+last.Status, ", ")
+	}
+	return result
+}
+
+// WaitFor polls check until it is done, has failed, the timeout is reached, its progress got stuck or the context
+// got cancelled. The Cluster of the result is not set.
+func (w ClusterWaiter) WaitFor(ctx context.Context, check WaitCheck) WaitResult {
+	start := time.Now()
+	interval := w.Interval
+	if interval <= 0 {
+		interval = 30 * time.Second
+	}
+
+	wctx := ctx
+	if w.Timeout > 0 {
+		var cancel context.CancelFunc
+		wctx, cancel = context.WithTimeout(ctx, w.Timeout)
+		defer cancel()
+	}
+
+	end := func(outcome WaitOutcome, reason string) WaitResult {
+		if outcome == WaitCancelled && ctx.Err() != nil {
+			reason = ctx.Err().Error()
+		} else if outcome == WaitCancelled {
+			// the parent context is fine, so the timeout of the waiter has been reached
+			outcome = WaitTimedOut
+		}
+		return WaitResult{Outcome: outcome, Reason: reason, Duration: time.Since(start)}
+	}
+	sleep := func(d time.Duration) bool {
+		select {
+		case <-wctx.Done():
+			return false
+		case <-time.After(d):
+			return true
+		}
+	}
+
+	if w.Delay > 0 && !sleep(w.Delay) {
+		return end(WaitCancelled, "")
+	}
+
+	var last string
+	changed := time.Now()
+	for {
+		progress, done, err := check(wctx)
+		var failure WaitFailure
+		if progress != "" && progress != last {
+			last, changed = progress, time.Now()
+			if w.OnProgress != nil {
+				w.OnProgress(progress)
+			}
+		}
+		switch {
+		case errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) || wctx.Err() != nil:
+			return end(WaitCancelled, "")
+		case errors.As(err, &failure):
+			return end(WaitFailed, failure.Error())
+		case IsPermanent(err):
+			// a deleted resource or revoked credentials do not come back by polling again
+			return end(WaitFailed, err.Error())
+		case err != nil:
+			if w.OnError != nil {
+				w.OnError(err)
+			}
+		case done:
+			return end(WaitSucceeded, "")
+		}
+
+		if w.StuckAfter > 0 && time.Since(changed) >= w.StuckAfter {
+			return end(WaitTimedOut, fmt.Sprintf("no progress for %s", w.StuckAfter))
+		}
+		if !sleep(interval) {
+			return end(WaitCancelled, "")
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package ovhwrapper
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestWaitFor(t *testing.T) {
+	errTransient := errors.New("connection reset")
+
+	type step struct {
+		progress string
+		done     bool
+		err      error
+	}
+	tests := []struct {
+		name       string
+		steps      []step // the last step is repeated
+		timeout    time.Duration
+		stuckAfter time.Duration
+		want       WaitOutcome
+		wantPolls  int
+	}{
+		{"done", []step{{"READY", true, nil}}, 0, 0, WaitSucceeded, 1},
+		{"progress then done", []step{{"UPDATING", false, nil}, {"READY", true, nil}}, 0, 0, WaitSucceeded, 2},
+		{"transient error then done", []step{{"", false, errTransient}, {"READY", true, nil}}, 0, 0, WaitSucceeded, 2},
+		{"failure", []step{{"UPDATING", false, nil}, {"ERROR", false, WaitFailure("cluster is ERROR")}}, 0, 0, WaitFailed, 2},
+		{"not found", []step{{"", false, fmt.Errorf("GET /kube: %w", ErrNotFound)}}, 0, 0, WaitFailed, 1},
+		{"stuck", []step{{"UPDATING", false, nil}}, 0, 20 * time.Millisecond, WaitTimedOut, 0},
+		{"timeout", []step{{"UPDATING", false, nil}}, 20 * time.Millisecond, 0, WaitTimedOut, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var polls int
+			var progress []string
+			waiter := ClusterWaiter{
+				Interval:   time.Millisecond,
+				Timeout:    tt.timeout,
+				StuckAfter: tt.stuckAfter,
+				OnProgress: func(p string) { progress = append(progress, p) },
+			}
+			result := waiter.WaitFor(context.Background(), func(ctx context.Context) (string, bool, error) {
+				s := tt.steps[min(polls, len(tt.steps)-1)]
+				polls++
+				return s.progress, s.done, s.err
+			})
+			if result.Outcome != tt.want {
+				t.Fatalf("WaitFor outcome = %s (%s), want %s", result.Outcome, result.Reason, tt.want)
+			}
+			if tt.wantPolls > 0 && polls != tt.wantPolls {
+				t.Errorf("WaitFor polled %d times, want %d", polls, tt.wantPolls)
+			}
+			for i := 1; i < len(progress); i++ {
+				if progress[i] == progress[i-1] {
+					t.Errorf("OnProgress called twice with %q", progress[i])
+				}
+			}
+		})
+	}
+}
+
+func TestWaitForCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	result := ClusterWaiter{Delay: time.Second}.WaitFor(ctx, func(ctx context.Context) (string, bool, error) {
+		t.Fatal("check called after the context got cancelled")
+		return "", false, nil
+	})
+	if result.Outcome != WaitCancelled {
+		t.Errorf("WaitFor outcome = %s, want %s", result.Outcome, WaitCancelled)
+	}
+}
@@ -0,0 +1,66 @@
+package ovhwrapper
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+)
+
+// OVHQuota is the quota of a serviceline in a region.
+type OVHQuota struct {
+	Region   string        `json:"region"`
+	Instance InstanceQuota `json:"instance"`
+}
+
+// InstanceQuota limits the number of instances, cores and memory (in MB) of a serviceline in a region.
+type InstanceQuota struct {
+	MaxInstances  int `json:"maxInstances"`
+	UsedInstances int `json:"usedInstances"`
+	MaxCores      int `json:"maxCores"`
+	UsedCores     int `json:"usedCores"`
+	MaxRAM        int `json:"maxRam"`
+	UsedRAM       int `json:"usedRAM"`
+}
+
+// Fits returns an error describing the exceeded limit if the given number of additional nodes of the flavor
+// does not fit into the quota.
+func (q InstanceQuota) Fits(flavor K8SFlavor, nodes int) error {
+	switch {
+	case nodes <= 0:
+		return nil
+	case q.UsedInstances+nodes > q.MaxInstances:
+		return fmt.Errorf("%d more instances exceed the quota of %d instances, %d are used",
+			nodes, q.MaxInstances, q.UsedInstances)
+	case q.UsedCores+nodes*flavor.VCPUs > q.MaxCores:
+		return fmt.Errorf("%d more vcpus exceed the quota of %d vcpus, %d are used",
+			nodes*flavor.VCPUs, q.MaxCores, q.UsedCores)
+	case q.UsedRAM+nodes*flavor.RAM*1024 > q.MaxRAM:
+		return fmt.Errorf("%d GB more memory exceed the quota of %d GB, %d GB are used",
+			nodes*flavor.RAM, q.MaxRAM/1024, q.UsedRAM/1024)
+	}
+	return nil
+}
+
+// GetOVHQuotas returns the quotas of a serviceline for all of its regions.
+func GetOVHQuotas(ctx context.Context, client API, service string) ([]OVHQuota, error) {
+	var quotas []OVHQuota
+	path := "/cloud/project/" + service + "/quota"
+	if err := client.GetWithContext(ctx, path, &quotas); err != nil {
+		return quotas, wrapError(http.MethodGet, path, err)
+	}
+	return quotas, nil
+}
+
+// GetOVHQuota returns the quota of a serviceline in the given region.
+func GetOVHQuota(ctx context.Context, client API, service, region string) (OVHQuota, error) {
+	quotas, err := GetOVHQuotas(ctx, client, service)
+	if err != nil {
+		return OVHQuota{}, err
+	}
+	for _, quota := range quotas {
+		if quota.Region == region {
+			return quota, nil
+		}
+	}
+	return OVHQuota{}, fmt.Errorf("no quota for region %s in serviceline %s: %w", region, service, ErrNotFound)
+}
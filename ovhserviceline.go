@@ -1,11 +1,11 @@
 package ovhwrapper
 
 import (
+	"context"
 	"fmt"
+	"net/http"
 	"strings"
 	"time"
-
-	"github.com/ovh/go-ovh/ovh"
 )
 
 type OVHServiceLine struct {
@@ -52,32 +52,33 @@ func (iam Iam) Details() string {
 	return fmt.Sprintf("  ID: %s\n  URN: %s", iam.ID, iam.Urn)
 }
 
-func GetServicelineDetails(client *ovh.Client, service string) (OVHServiceLine, error) {
+func GetServicelineDetails(ctx context.Context, client API, service string) (OVHServiceLine, error) {
 	var serviceline OVHServiceLine
-	if err := client.Get(fmt.Sprintf("/cloud/project/%s", service), &serviceline); err != nil {
-		return OVHServiceLine{}, err
+	path := fmt.Sprintf("/cloud/project/%s", service)
+	if err := client.GetWithContext(ctx, path, &serviceline); err != nil {
+		return OVHServiceLine{}, wrapError(http.MethodGet, path, err)
 	}
 	return serviceline, nil
 }
 
-func GetServicelines(client *ovh.Client) []string {
+// GetServicelines returns the IDs of all public cloud projects (servicelines) the client has access to.
+func GetServicelines(ctx context.Context, client API) ([]string, error) {
 	var servicelist []string
 
-	if err := client.Get("/cloud/project/", &servicelist); err != nil {
-		fmt.Printf("Error getting serviceline list: %q\n", err)
-		return servicelist
+	if err := client.GetWithContext(ctx, "/cloud/project/", &servicelist); err != nil {
+		return servicelist, wrapError(http.MethodGet, "/cloud/project/", err)
 	}
 
-	return servicelist
+	return servicelist, nil
 }
 
-func GetOVHServiceline(client *ovh.Client, service string) *OVHServiceLine {
+func GetOVHServiceline(ctx context.Context, client API, service string) (*OVHServiceLine, error) {
 	serviceline := &OVHServiceLine{}
 
-	if err := client.Get("/cloud/project/"+service, serviceline); err != nil {
-		fmt.Printf("Error getting serviceline list: %q\n", err)
-		return nil
+	path := "/cloud/project/" + service
+	if err := client.GetWithContext(ctx, path, serviceline); err != nil {
+		return nil, wrapError(http.MethodGet, path, err)
 	}
 
-	return serviceline
+	return serviceline, nil
 }
@@ -1,11 +1,12 @@
 package ovhwrapper
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
-	"github.com/ovh/go-ovh/ovh"
 	"io/ioutil"
 	"log"
+	"net/http"
 	"os"
 	"time"
 )
@@ -55,34 +56,34 @@ func DescribeOVHVolume(volume OVHVolume, output string) {
 }
 
 // GetOVHVolumes retrieves the list of Kubernetes volumes in a given service and cluster ID.
-// It takes in an OVH client, the service name, and the cluster ID as parameters.
+// It takes in a context, an OVH API client, the service name, and the cluster ID as parameters.
 // It returns a []OVHVolume slice representing the list of volumes and an error if any occurred.
 //
 // The []OVHVolume slice is a collection of OVHVolume structs. Each OVHVolume struct contains information
 // about a volume such as its ID, project ID, instance ID, volume pool ID, name, flavor, status,
 // update status, version, creation timestamp, update timestamp, and deployment timestamp.
-func GetOVHVolumes(client *ovh.Client, service, clusterid string) ([]OVHVolume, error) {
+func GetOVHVolumes(ctx context.Context, client API, service, clusterid string) ([]OVHVolume, error) {
 	var volumelist []OVHVolume
 	//	volumelist:=  make([]OVHVolume, 3)
-	if err := client.Get("/cloud/project/"+service+"/kube/"+clusterid+"/volume", &volumelist); err != nil {
-		fmt.Printf("Error getting k8s volume list: %q\n", err)
-		return volumelist, err
+	path := "/cloud/project/" + service + "/kube/" + clusterid + "/volume"
+	if err := client.GetWithContext(ctx, path, &volumelist); err != nil {
+		return volumelist, wrapError(http.MethodGet, path, err)
 	}
 
 	return volumelist, nil
 }
 
 // GetOVHVolume retrieves the details of a specific Kubernetes volume in a given service and cluster ID.
-// It takes in an OVH client, the service name, the cluster ID, and the volume ID as parameters.
+// It takes in a context, an OVH API client, the service name, the cluster ID, and the volume ID as parameters.
 // It returns a OVHVolume struct representing the volume and an error if any occurred.
 // The OVHVolume struct contains information about the volume such as its ID, project ID, instance ID,
 // volume pool ID, name, flavor, status, update status, version, creation timestamp, update timestamp, and deployment timestamp.
-func GetOVHVolume(client *ovh.Client, service, clusterid, volumeid string) (OVHVolume, error) {
+func GetOVHVolume(ctx context.Context, client API, service, clusterid, volumeid string) (OVHVolume, error) {
 	var volume OVHVolume
 	//	volumelist:=  make([]OVHVolume, 3)
-	if err := client.Get("/cloud/project/"+service+"/kube/"+clusterid+"/volume/"+volumeid, &volume); err != nil {
-		fmt.Printf("Error getting k8s volume %s: %q\n", volumeid, err)
-		return volume, err
+	path := "/cloud/project/" + service + "/kube/" + clusterid + "/volume/" + volumeid
+	if err := client.GetWithContext(ctx, path, &volume); err != nil {
+		return volume, wrapError(http.MethodGet, path, err)
 	}
 
 	return volume, nil
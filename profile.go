@@ -0,0 +1,145 @@
+package ovhwrapper
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+)
+
+// DefaultProfileName is the name of the profile formed by the top level settings of the configuration.
+const DefaultProfileName = "default"
+
+// Profile holds the settings of one OVH account.
+type Profile struct {
+	// Endpoint is either the name of an OVH API endpoint (ovh-eu, ovh-ca, ovh-us, ...) or the URL of
+	// an API compatible server, e.g. http://localhost:8080 for the apiserver emulation.
+	Endpoint string   `yaml:"endpoint,omitempty" json:"endpoint,omitempty"`
+	Reader   Endpoint `yaml:"reader" json:"reader"`
+	Writer   Endpoint `yaml:"writer" json:"writer"`
+	// Retry configures retries and the request rate of the reader and writer clients.
+	Retry RetryPolicy `yaml:"retry,omitempty" json:"retry,omitempty"`
+	// Concurrency limits the number of parallel API requests used to gather the inventory.
+	Concurrency int `yaml:"concurrency,omitempty" json:"concurrency,omitempty"`
+	// Cache configures the on-disk cache of the gathered inventory.
+	Cache CacheConfig `yaml:"cache,omitempty" json:"cache,omitempty"`
+	// CredentialProcess is a command printing the credentials as JSON, see CredentialProcessOutput.
+	CredentialProcess string `yaml:"credential_process,omitempty" json:"credential_process,omitempty"`
+	// SecretStore is the store new credentials are saved in, keyring or file. Without a store they are
+	// written to the configuration file.
+	SecretStore string `yaml:"secret_store,omitempty" json:"secret_store,omitempty"`
+	// SecretFile is the path of the encrypted secret file, see DefaultSecretFile.
+	SecretFile string `yaml:"secret_file,omitempty" json:"secret_file,omitempty"`
+	// Inventory is the path of the clustergroups inventory file of this account.
+	Inventory string `yaml:"inventory,omitempty" json:"inventory,omitempty"`
+}
+
+// inherit fills the empty connection settings of a named profile from the default profile.
+// Credentials, the inventory file and the cache path are never inherited, as they belong to one account.
+func (p Profile) inherit(base Profile) Profile {
+	if p.Endpoint == "" {
+		p.Endpoint = base.Endpoint
+	}
+	if p.Retry == (RetryPolicy{}) {
+		p.Retry = base.Retry
+	}
+	if p.Concurrency == 0 {
+		p.Concurrency = base.Concurrency
+	}
+	if p.Cache.TTL == 0 {
+		p.Cache.TTL = base.Cache.TTL
+	}
+	if p.SecretStore == "" {
+		p.SecretStore = base.SecretStore
+	}
+	if p.SecretFile == "" {
+		p.SecretFile = base.SecretFile
+	}
+	return p
+}
+
+// ReadProfile reads the configuration like ReadConfiguration, activates the named profile and resolves its
+// credentials. An empty name selects the default_profile of the configuration, or the top level settings
+// if none is set.
+func ReadProfile(name string) (Configuration, error) {
+	config, err := ReadConfiguration()
+	if err != nil {
+		return config, err
+	}
+	if err := config.UseProfile(name); err != nil {
+		return config, err
+	}
+	if err := config.checkPermissions(); err != nil {
+		config.warnings = append(config.warnings, err.Error())
+	}
+	if err := config.ResolveCredentials(); err != nil {
+		return config, err
+	}
+	return config, nil
+}
+
+// Warnings returns the problems found by ReadProfile which do not prevent using the configuration, e.g. a
+// configuration file readable by others. The caller decides how to show them.
+func (c *Configuration) Warnings() []string {
+	return c.warnings
+}
+
+// UseProfile activates the named profile of a configuration read by ReadConfiguration, see ReadProfile.
+// The credentials are taken from the file only, ResolveCredentials adds the other sources.
+func (c *Configuration) UseProfile(name string) error {
+	if name == "" {
+		name = c.DefaultProfile
+	}
+	if name == "" || name == DefaultProfileName {
+		c.profile = ""
+		c.Profile = c.base
+		return nil
+	}
+
+	profile, ok := c.Profiles[name]
+	if !ok {
+		available := append([]string{DefaultProfileName}, slices.Sorted(maps.Keys(c.Profiles))...)
+		return fmt.Errorf("profile %q not found in %s, available profiles: %s", name, c.fpath,
+			strings.Join(available, ", "))
+	}
+	c.profile = name
+	c.Profile = profile.inherit(c.base)
+	return nil
+}
+
+// ProfileName returns the name of the active profile.
+func (c *Configuration) ProfileName() string {
+	if c.profile == "" {
+		return DefaultProfileName
+	}
+	return c.profile
+}
+
+// Save writes the configuration back to the file it was read from. For a named profile only its reader
+// and writer credentials are written back, the settings inherited from the default profile are not.
+// Credentials taken from the environment or the credential process are never written to the file, changed
+// credentials are saved in the secret store if they were read from it or if a secret_store is configured.
+func (c *Configuration) Save() error {
+	var err error
+	out := *c
+	out.Profile = c.base
+	if c.profile == "" {
+		if out.Reader, err = c.persisted(RoleReader, c.base.Reader); err != nil {
+			return err
+		}
+		if out.Writer, err = c.persisted(RoleWriter, c.base.Writer); err != nil {
+			return err
+		}
+	} else {
+		profile := c.Profiles[c.profile]
+		if profile.Reader, err = c.persisted(RoleReader, profile.Reader); err != nil {
+			return err
+		}
+		if profile.Writer, err = c.persisted(RoleWriter, profile.Writer); err != nil {
+			return err
+		}
+		out.Profiles = maps.Clone(c.Profiles)
+		out.Profiles[c.profile] = profile
+	}
+	return SaveYaml(out, c.fpath)
+}
@@ -0,0 +1,321 @@
+package ovhwrapper
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// ErrAmbiguous is matched if an identifier matches more than one resource.
+var ErrAmbiguous = errors.New("ambiguous")
+
+// Resource kinds used in ResolveError.
+const (
+	KindServiceline = "serviceline"
+	KindCluster     = "cluster"
+	KindDatabase    = "database"
+	KindNodepool    = "nodepool"
+)
+
+// ResolveError is returned if an identifier matches no or more than one resource.
+// It wraps ErrNotFound or ErrAmbiguous, so it can be checked with errors.Is.
+type ResolveError struct {
+	Kind       string
+	Identifier string
+	// Matches lists the names and IDs of all matching resources of an ambiguous identifier.
+	Matches []string
+	Err     error
+}
+
+func (e *ResolveError) Error() string {
+	if errors.Is(e.Err, ErrAmbiguous) {
+		return fmt.Sprintf("%s %q is ambiguous: matches %s", e.Kind, e.Identifier, strings.Join(e.Matches, ", "))
+	}
+	return fmt.Sprintf("%s %q not found", e.Kind, e.Identifier)
+}
+
+func (e *ResolveError) Unwrap() error {
+	return e.Err
+}
+
+// Resolver turns identifiers of servicelines, clusters, databases and nodepools into the resources of an
+// inventory. An identifier is either
+//   - the ID of the resource,
+//   - its full name or the ShortenName form of it,
+//   - a glob pattern like "prod-*", matched against ID, name and short name,
+//   - or a regular expression enclosed in slashes like "/^prod-(a|b)$/".
+//
+// An exact ID always wins, so an ID never gets ambiguous because of a name.
+type Resolver struct {
+	servicelines []ServiceLine
+}
+
+// NewResolver returns a resolver working on the given inventory. The resolver returns pointers into the
+// inventory, it is not copied.
+func NewResolver(servicelines []ServiceLine) *Resolver {
+	return &Resolver{servicelines: servicelines}
+}
+
+// candidate is a resource with the names it can be matched by.
+type candidate[T any] struct {
+	id   string
+	name string
+	item *T
+}
+
+func (c candidate[T]) label() string {
+	if c.name == "" || c.name == c.id {
+		return c.id
+	}
+	return fmt.Sprintf("%s (%s)", c.name, c.id)
+}
+
+// IsPattern returns true if the identifier is a glob pattern or a regular expression instead of a name or ID.
+func IsPattern(identifier string) bool {
+	return isRegexp(identifier) || strings.ContainsAny(identifier, "*?[")
+}
+
+func isRegexp(identifier string) bool {
+	return len(identifier) > 2 && strings.HasPrefix(identifier, "/") && strings.HasSuffix(identifier, "/")
+}
+
+// match returns all candidates matching the identifier, an empty identifier matches all candidates.
+func match[T any](identifier string, candidates []candidate[T]) ([]candidate[T], error) {
+	if identifier == "" {
+		return candidates, nil
+	}
+
+	var matcher func(string) bool
+	switch {
+	case isRegexp(identifier):
+		re, err := regexp.Compile(identifier[1 : len(identifier)-1])
+		if err != nil {
+			return nil, fmt.Errorf("invalid regular expression %s: %w", identifier, err)
+		}
+		matcher = re.MatchString
+	case IsPattern(identifier):
+		if _, err := path.Match(identifier, ""); err != nil {
+			return nil, fmt.Errorf("invalid pattern %s: %w", identifier, err)
+		}
+		matcher = func(s string) bool {
+			ok, _ := path.Match(identifier, s)
+			return ok
+		}
+	default:
+		for _, c := range candidates {
+			if c.id == identifier {
+				return []candidate[T]{c}, nil
+			}
+		}
+		matcher = func(s string) bool { return s == identifier }
+	}
+
+	var matches []candidate[T]
+	for _, c := range candidates {
+		if matcher(c.id) || (c.name != "" && (matcher(c.name) || matcher(ShortenName(c.name)))) {
+			matches = append(matches, c)
+		}
+	}
+	return matches, nil
+}
+
+// matchAll returns the items of all candidates matching the identifier or a not found error.
+func matchAll[T any](kind, identifier string, candidates []candidate[T]) ([]*T, error) {
+	matches, err := match(identifier, candidates)
+	if err != nil {
+		return nil, err
+	}
+	if len(matches) == 0 && identifier != "" {
+		return nil, &ResolveError{Kind: kind, Identifier: identifier, Err: ErrNotFound}
+	}
+
+	items := make([]*T, len(matches))
+	for i, m := range matches {
+		items[i] = m.item
+	}
+	return items, nil
+}
+
+// matchOne returns the item of the only candidate matching the identifier, or a not found or ambiguous error.
+func matchOne[T any](kind, identifier string, candidates []candidate[T]) (*T, error) {
+	if identifier == "" {
+		return nil, &ResolveError{Kind: kind, Identifier: identifier, Err: ErrNotFound}
+	}
+	matches, err := match(identifier, candidates)
+	if err != nil {
+		return nil, err
+	}
+
+	switch len(matches) {
+	case 0:
+		return nil, &ResolveError{Kind: kind, Identifier: identifier, Err: ErrNotFound}
+	case 1:
+		return matches[0].item, nil
+	}
+
+	labels := make([]string, len(matches))
+	for i, m := range matches {
+		labels[i] = m.label()
+	}
+	sort.Strings(labels)
+	return nil, &ResolveError{Kind: kind, Identifier: identifier, Matches: labels, Err: ErrAmbiguous}
+}
+
+// names returns the short names of the candidates, falling back to the ID for unnamed resources.
+func names[T any](candidates []candidate[T]) []string {
+	var list []string
+	seen := map[string]bool{}
+	for _, c := range candidates {
+		name := c.id
+		if c.name != "" {
+			name = ShortenName(c.name)
+		}
+		if !seen[name] {
+			seen[name] = true
+			list = append(list, name)
+		}
+	}
+	sort.Strings(list)
+	return list
+}
+
+func (r *Resolver) servicelineCandidates() []candidate[ServiceLine] {
+	candidates := make([]candidate[ServiceLine], len(r.servicelines))
+	for i := range r.servicelines {
+		sl := &r.servicelines[i]
+		candidates[i] = candidate[ServiceLine]{id: sl.ID, name: sl.SLDetails.Description, item: sl}
+	}
+	return candidates
+}
+
+func clusterCandidates(sl *ServiceLine) []candidate[K8SCluster] {
+	candidates := make([]candidate[K8SCluster], len(sl.Cluster))
+	for i := range sl.Cluster {
+		cl := &sl.Cluster[i]
+		candidates[i] = candidate[K8SCluster]{id: cl.ID, name: cl.Name, item: cl}
+	}
+	return candidates
+}
+
+func databaseCandidates(sl *ServiceLine) []candidate[OVHDatabase] {
+	candidates := make([]candidate[OVHDatabase], len(sl.Databases))
+	for i := range sl.Databases {
+		db := &sl.Databases[i]
+		candidates[i] = candidate[OVHDatabase]{id: db.Id.String(), name: db.Description, item: db}
+	}
+	return candidates
+}
+
+func nodepoolCandidates(cl *K8SCluster) []candidate[K8SNodepool] {
+	candidates := make([]candidate[K8SNodepool], len(cl.Nodepools))
+	for i := range cl.Nodepools {
+		np := &cl.Nodepools[i]
+		candidates[i] = candidate[K8SNodepool]{id: np.Id, name: np.Name, item: np}
+	}
+	return candidates
+}
+
+// Serviceline returns the only serviceline matching the identifier.
+func (r *Resolver) Serviceline(identifier string) (*ServiceLine, error) {
+	return matchOne(KindServiceline, identifier, r.servicelineCandidates())
+}
+
+// Servicelines returns all servicelines matching the identifier, an empty identifier returns all servicelines.
+func (r *Resolver) Servicelines(identifier string) ([]*ServiceLine, error) {
+	return matchAll(KindServiceline, identifier, r.servicelineCandidates())
+}
+
+// Cluster returns the only cluster matching the identifier together with its serviceline.
+// If slIdentifier is empty, the clusters of all servicelines are searched.
+func (r *Resolver) Cluster(slIdentifier, identifier string) (*ServiceLine, *K8SCluster, error) {
+	servicelines, err := r.servicelinesOf(slIdentifier)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// resolve on the combined list, so a name used in two servicelines is reported as ambiguous
+	type slCluster struct {
+		sl *ServiceLine
+		cl *K8SCluster
+	}
+	var candidates []candidate[slCluster]
+	for _, sl := range servicelines {
+		for _, c := range clusterCandidates(sl) {
+			candidates = append(candidates, candidate[slCluster]{id: c.id, name: c.name, item: &slCluster{sl, c.item}})
+		}
+	}
+
+	found, err := matchOne(KindCluster, identifier, candidates)
+	if err != nil {
+		return nil, nil, err
+	}
+	return found.sl, found.cl, nil
+}
+
+// Clusters returns all clusters of a serviceline matching the identifier, an empty identifier returns all
+// clusters of the serviceline.
+func (r *Resolver) Clusters(sl *ServiceLine, identifier string) ([]*K8SCluster, error) {
+	return matchAll(KindCluster, identifier, clusterCandidates(sl))
+}
+
+// Database returns the only database of the serviceline matching the identifier.
+func (r *Resolver) Database(sl *ServiceLine, identifier string) (*OVHDatabase, error) {
+	return matchOne(KindDatabase, identifier, databaseCandidates(sl))
+}
+
+// Databases returns all databases of a serviceline matching the identifier, an empty identifier returns all
+// databases of the serviceline.
+func (r *Resolver) Databases(sl *ServiceLine, identifier string) ([]*OVHDatabase, error) {
+	return matchAll(KindDatabase, identifier, databaseCandidates(sl))
+}
+
+// Nodepool returns the only nodepool of the cluster matching the identifier.
+func (r *Resolver) Nodepool(cl *K8SCluster, identifier string) (*K8SNodepool, error) {
+	return matchOne(KindNodepool, identifier, nodepoolCandidates(cl))
+}
+
+// ServicelineCandidates returns the short names of all servicelines, e.g. for shell completion.
+func (r *Resolver) ServicelineCandidates() []string {
+	return names(r.servicelineCandidates())
+}
+
+// ClusterCandidates returns the short names of the clusters of all servicelines matching slIdentifier,
+// e.g. for shell completion. An empty slIdentifier returns the clusters of all servicelines.
+func (r *Resolver) ClusterCandidates(slIdentifier string) []string {
+	servicelines, _ := r.servicelinesOf(slIdentifier)
+	var candidates []candidate[K8SCluster]
+	for _, sl := range servicelines {
+		candidates = append(candidates, clusterCandidates(sl)...)
+	}
+	return names(candidates)
+}
+
+// DatabaseCandidates returns the short names of the databases of all servicelines matching slIdentifier.
+func (r *Resolver) DatabaseCandidates(slIdentifier string) []string {
+	servicelines, _ := r.servicelinesOf(slIdentifier)
+	var candidates []candidate[OVHDatabase]
+	for _, sl := range servicelines {
+		candidates = append(candidates, databaseCandidates(sl)...)
+	}
+	return names(candidates)
+}
+
+// NodepoolCandidates returns the names of the nodepools of a cluster.
+func (r *Resolver) NodepoolCandidates(cl *K8SCluster) []string {
+	return names(nodepoolCandidates(cl))
+}
+
+// servicelinesOf returns the only serviceline matching the identifier, or all servicelines if it is empty.
+func (r *Resolver) servicelinesOf(identifier string) ([]*ServiceLine, error) {
+	if identifier == "" {
+		return r.Servicelines("")
+	}
+	sl, err := r.Serviceline(identifier)
+	if err != nil {
+		return nil, err
+	}
+	return []*ServiceLine{sl}, nil
+}
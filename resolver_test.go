@@ -0,0 +1,211 @@
+package ovhwrapper
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func testInventory() []ServiceLine {
+	return []ServiceLine{
+		{
+			ID:        "sl1",
+			SLDetails: OVHServiceLine{Description: "sl_prod-a"},
+			Cluster: []K8SCluster{
+				{ID: "c1", Name: "ovh-k8s-web-00", Nodepools: []K8SNodepool{{Id: "np1", Name: "default"}, {Id: "np2", Name: "gpu"}}},
+				{ID: "c2", Name: "ovh-k8s-db"},
+				// the name of this cluster is the ID of another one
+				{ID: "c3", Name: "c4"},
+				{ID: "c4", Name: "ovh-k8s-batch"},
+			},
+		},
+		{
+			ID:        "sl2",
+			SLDetails: OVHServiceLine{Description: "sl_prod-b"},
+			Cluster: []K8SCluster{
+				{ID: "c5", Name: "ovh-k8s-web-00"},
+			},
+		},
+		{
+			ID:        "sl3",
+			SLDetails: OVHServiceLine{Description: "sl_test"},
+		},
+	}
+}
+
+func TestResolverServiceline(t *testing.T) {
+	r := NewResolver(testInventory())
+
+	tests := []struct {
+		identifier string
+		want       string
+		err        error
+	}{
+		{"sl1", "sl1", nil},
+		{"sl_prod-b", "sl2", nil},
+		{"test", "sl3", nil},
+		{"prod-*", "", ErrAmbiguous},
+		{"*-a", "sl1", nil},
+		{"/^sl_t/", "sl3", nil},
+		{"/prod-(a|b)/", "", ErrAmbiguous},
+		{"staging", "", ErrNotFound},
+		{"", "", ErrNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.identifier, func(t *testing.T) {
+			sl, err := r.Serviceline(tt.identifier)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("Serviceline(%q) error = %v, want %v", tt.identifier, err, tt.err)
+			}
+			if err == nil && sl.ID != tt.want {
+				t.Errorf("Serviceline(%q) = %s, want %s", tt.identifier, sl.ID, tt.want)
+			}
+		})
+	}
+}
+
+func TestResolverCluster(t *testing.T) {
+	r := NewResolver(testInventory())
+
+	tests := []struct {
+		name        string
+		serviceline string
+		identifier  string
+		wantSL      string
+		want        string
+		err         error
+	}{
+		{"id", "prod-a", "c2", "sl1", "c2", nil},
+		{"full name", "prod-a", "ovh-k8s-db", "sl1", "c2", nil},
+		{"short name", "prod-a", "db", "sl1", "c2", nil},
+		{"shortened -00", "prod-a", "web", "sl1", "c1", nil},
+		{"id wins over name", "prod-a", "c4", "sl1", "c4", nil},
+		{"glob", "prod-a", "b*", "sl1", "c4", nil},
+		{"ambiguous glob", "prod-a", "ovh-k8s-*", "", "", ErrAmbiguous},
+		{"regexp", "prod-a", "/^ovh-k8s-d/", "sl1", "c2", nil},
+		{"not found", "prod-a", "cache", "", "", ErrNotFound},
+		{"other serviceline", "prod-b", "web", "sl2", "c5", nil},
+		{"all servicelines", "", "db", "sl1", "c2", nil},
+		{"same name in two servicelines", "", "web", "", "", ErrAmbiguous},
+		{"unknown serviceline", "staging", "web", "", "", ErrNotFound},
+		{"ambiguous serviceline", "prod-*", "web", "", "", ErrAmbiguous},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sl, cl, err := r.Cluster(tt.serviceline, tt.identifier)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("Cluster(%q, %q) error = %v, want %v", tt.serviceline, tt.identifier, err, tt.err)
+			}
+			if err == nil && (sl.ID != tt.wantSL || cl.ID != tt.want) {
+				t.Errorf("Cluster(%q, %q) = %s/%s, want %s/%s", tt.serviceline, tt.identifier, sl.ID, cl.ID,
+					tt.wantSL, tt.want)
+			}
+		})
+	}
+}
+
+func TestResolverAmbiguousMatches(t *testing.T) {
+	_, _, err := NewResolver(testInventory()).Cluster("", "web")
+	var resErr *ResolveError
+	if !errors.As(err, &resErr) {
+		t.Fatalf("Cluster error = %v, want a ResolveError", err)
+	}
+	want := []string{"ovh-k8s-web-00 (c1)", "ovh-k8s-web-00 (c5)"}
+	if !reflect.DeepEqual(resErr.Matches, want) {
+		t.Errorf("Matches = %v, want %v", resErr.Matches, want)
+	}
+}
+
+func TestResolverClusters(t *testing.T) {
+	r := NewResolver(testInventory())
+	sl, err := r.Serviceline("sl1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		identifier string
+		want       []string
+		err        error
+	}{
+		{"", []string{"c1", "c2", "c3", "c4"}, nil},
+		{"ovh-k8s-*", []string{"c1", "c2", "c4"}, nil},
+		{"/^c[0-9]$/", []string{"c1", "c2", "c3", "c4"}, nil},
+		{"db", []string{"c2"}, nil},
+		{"cache*", nil, ErrNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.identifier, func(t *testing.T) {
+			clusters, err := r.Clusters(sl, tt.identifier)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("Clusters(%q) error = %v, want %v", tt.identifier, err, tt.err)
+			}
+			var ids []string
+			for _, cl := range clusters {
+				ids = append(ids, cl.ID)
+			}
+			if !reflect.DeepEqual(ids, tt.want) {
+				t.Errorf("Clusters(%q) = %v, want %v", tt.identifier, ids, tt.want)
+			}
+		})
+	}
+}
+
+func TestResolverInvalidPatterns(t *testing.T) {
+	r := NewResolver(testInventory())
+	for _, identifier := range []string{"/prod-(/", "prod-["} {
+		if _, err := r.Serviceline(identifier); err == nil || errors.Is(err, ErrNotFound) {
+			t.Errorf("Serviceline(%q) error = %v, want an invalid pattern error", identifier, err)
+		}
+	}
+}
+
+func TestResolverNodepool(t *testing.T) {
+	r := NewResolver(testInventory())
+	_, cl, err := r.Cluster("sl1", "c1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		identifier string
+		want       string
+		err        error
+	}{
+		{"np2", "np2", nil},
+		{"default", "np1", nil},
+		{"*", "", ErrAmbiguous},
+		{"spot", "", ErrNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.identifier, func(t *testing.T) {
+			np, err := r.Nodepool(cl, tt.identifier)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("Nodepool(%q) error = %v, want %v", tt.identifier, err, tt.err)
+			}
+			if err == nil && np.Id != tt.want {
+				t.Errorf("Nodepool(%q) = %s, want %s", tt.identifier, np.Id, tt.want)
+			}
+		})
+	}
+}
+
+func TestIsPattern(t *testing.T) {
+	tests := []struct {
+		identifier string
+		want       bool
+	}{
+		{"prod", false},
+		{"prod-*", true},
+		{"prod-?", true},
+		{"prod-[ab]", true},
+		{"/^prod/", true},
+		{"//", false},
+		{"/prod", false},
+	}
+	for _, tt := range tests {
+		if got := IsPattern(tt.identifier); got != tt.want {
+			t.Errorf("IsPattern(%q) = %t, want %t", tt.identifier, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,438 @@
+package ovhwrapper
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/term"
+)
+
+// Secret stores, set with secret_store in the configuration.
+const (
+	// StoreKeyring keeps the secrets in the Secret Service of the desktop session (gnome-keyring, KWallet, ...)
+	// using the secret-tool command.
+	StoreKeyring = "keyring"
+	// StoreFile keeps the secrets in a file encrypted with a passphrase.
+	StoreFile = "file"
+)
+
+// Prefixes of credential values in the configuration referencing a secret instead of containing it.
+const (
+	keyringPrefix    = "keyring:"
+	secretFilePrefix = "secretfile:"
+)
+
+// SecretsPassphraseEnv is the environment variable read for the passphrase of the encrypted secret file,
+// if it is not set, the passphrase is read from the terminal.
+const SecretsPassphraseEnv = "OVH_SECRETS_PASSPHRASE"
+
+// ErrSecretNotFound is returned if a referenced secret does not exist in its store.
+var ErrSecretNotFound = errors.New("secret not found")
+
+// SecretStore keeps credential values outside the configuration file.
+type SecretStore interface {
+	Get(key string) (string, error)
+	Set(key, value string) error
+	Delete(key string) error
+}
+
+// isSecretRef returns true if the credential value is a reference into a secret store.
+func isSecretRef(value string) bool {
+	return strings.HasPrefix(value, keyringPrefix) || strings.HasPrefix(value, secretFilePrefix)
+}
+
+// secretRef returns the reference written to the configuration for a secret in the given store.
+func secretRef(store, key string) string {
+	if store == StoreFile {
+		return secretFilePrefix + key
+	}
+	return keyringPrefix + key
+}
+
+// secretStore returns the store and key of a reference. The stores are created once per configuration,
+// so the encrypted file is only decrypted and the passphrase only asked for once.
+func (c *Configuration) secretStore(ref string) (SecretStore, string, error) {
+	if c.stores == nil {
+		c.stores = &secretStores{}
+	}
+	switch {
+	case strings.HasPrefix(ref, keyringPrefix):
+		if c.stores.keyring == nil {
+			c.stores.keyring = &KeyringStore{}
+		}
+		return c.stores.keyring, strings.TrimPrefix(ref, keyringPrefix), nil
+	case strings.HasPrefix(ref, secretFilePrefix):
+		if c.stores.file == nil {
+			path := c.SecretFile
+			if path == "" {
+				path = DefaultSecretFile()
+			}
+			c.stores.file = &FileStore{Path: path, Passphrase: readPassphrase}
+		}
+		return c.stores.file, strings.TrimPrefix(ref, secretFilePrefix), nil
+	}
+	return nil, "", fmt.Errorf("invalid secret reference %q", ref)
+}
+
+type secretStores struct {
+	keyring *KeyringStore
+	file    *FileStore
+}
+
+// readSecret returns the secret a credential value references.
+func (c *Configuration) readSecret(ref string) (string, error) {
+	store, key, err := c.secretStore(ref)
+	if err != nil {
+		return "", err
+	}
+	value, err := store.Get(key)
+	if err != nil {
+		return "", fmt.Errorf("reading secret %s: %w", ref, err)
+	}
+	return value, nil
+}
+
+// writeSecret saves a credential value in the store and returns the reference to write to the configuration.
+// An empty value deletes the secret.
+func (c *Configuration) writeSecret(ref, value string) (string, error) {
+	store, key, err := c.secretStore(ref)
+	if err != nil {
+		return "", err
+	}
+	if value == "" {
+		if err := store.Delete(key); err != nil && !errors.Is(err, ErrSecretNotFound) {
+			return "", fmt.Errorf("deleting secret %s: %w", ref, err)
+		}
+		return "", nil
+	}
+	if err := store.Set(key, value); err != nil {
+		return "", fmt.Errorf("saving secret %s: %w", ref, err)
+	}
+	return ref, nil
+}
+
+// secretKey returns the key of a credential in the secret store, e.g. "default/writer/consumer_key".
+func (c *Configuration) secretKey(profile, role, name string) string {
+	return profile + "/" + role + "/" + name
+}
+
+// MigrateSecrets moves all plaintext credentials of all profiles into the given secret store, replaces them
+// with references and writes the configuration file. Values already stored as references are kept.
+func (c *Configuration) MigrateSecrets(store string) (int, error) {
+	if store != StoreKeyring && store != StoreFile {
+		return 0, fmt.Errorf("unknown secret store %q, use %s or %s", store, StoreKeyring, StoreFile)
+	}
+
+	var migrated int
+	migrate := func(profile string, p *Profile) error {
+		for _, role := range []string{RoleReader, RoleWriter} {
+			endpoint := &p.Reader
+			if role == RoleWriter {
+				endpoint = &p.Writer
+			}
+			for _, name := range credentialNames {
+				value := endpoint.field(name)
+				if *value == "" || isSecretRef(*value) {
+					continue
+				}
+				ref, err := c.writeSecret(secretRef(store, c.secretKey(profile, role, name)), *value)
+				if err != nil {
+					return err
+				}
+				*value = ref
+				migrated++
+			}
+		}
+		p.SecretStore = store
+		return nil
+	}
+
+	out := *c
+	out.Profile = c.base
+	if err := migrate(DefaultProfileName, &out.Profile); err != nil {
+		return migrated, err
+	}
+	out.Profiles = make(map[string]Profile, len(c.Profiles))
+	for name, profile := range c.Profiles {
+		if err := migrate(name, &profile); err != nil {
+			return migrated, err
+		}
+		// named profiles inherit the store of the default profile
+		profile.SecretStore = ""
+		out.Profiles[name] = profile
+	}
+	if err := SaveYaml(out, c.fpath); err != nil {
+		return migrated, fmt.Errorf("saving configuration file %s: %w", c.fpath, err)
+	}
+	return migrated, nil
+}
+
+// checkPermissions warns if the configuration file contains plaintext credentials and can be read by other users.
+func (c *Configuration) checkPermissions() error {
+	info, err := os.Stat(c.fpath)
+	if err != nil || info.Mode().Perm()&0o077 == 0 {
+		return nil
+	}
+
+	profiles := []Profile{c.base}
+	for _, profile := range c.Profiles {
+		profiles = append(profiles, profile)
+	}
+	for _, p := range profiles {
+		for _, endpoint := range []Endpoint{p.Reader, p.Writer} {
+			for _, name := range credentialNames {
+				if value := *endpoint.field(name); value != "" && !isSecretRef(value) {
+					return fmt.Errorf("configuration file %s contains plaintext credentials and has mode %s, "+
+						"run 'chmod 600 %s' or move the credentials into a secret store", c.fpath, info.Mode().Perm(), c.fpath)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// KeyringStore keeps secrets in the Secret Service of the desktop session using the secret-tool command
+// of libsecret.
+type KeyringStore struct{}
+
+const keyringService = "ovhwrapper"
+
+func (k *KeyringStore) Get(key string) (string, error) {
+	var stdout bytes.Buffer
+	cmd := exec.Command("secret-tool", "lookup", "service", keyringService, "account", key)
+	cmd.Stdout = &stdout
+	if err := cmd.Run(); err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && stdout.Len() == 0 {
+			return "", ErrSecretNotFound
+		}
+		return "", fmt.Errorf("running secret-tool: %w", err)
+	}
+	return strings.TrimRight(stdout.String(), "\n"), nil
+}
+
+func (k *KeyringStore) Set(key, value string) error {
+	cmd := exec.Command("secret-tool", "store", "--label=ovhwrapper "+key, "service", keyringService, "account", key)
+	cmd.Stdin = strings.NewReader(value)
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("running secret-tool: %w", err)
+	}
+	return nil
+}
+
+func (k *KeyringStore) Delete(key string) error {
+	if err := exec.Command("secret-tool", "clear", "service", keyringService, "account", key).Run(); err != nil {
+		return fmt.Errorf("running secret-tool: %w", err)
+	}
+	return nil
+}
+
+// DefaultSecretFile returns the default path of the encrypted secret file, ~/.config/ovhwrapper/secrets.enc.
+func DefaultSecretFile() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		dir = os.Getenv("HOME")
+	}
+	return filepath.Join(dir, "ovhwrapper", "secrets.enc")
+}
+
+// FileStore keeps secrets in a file encrypted with AES-256-GCM, the key is derived from a passphrase with
+// PBKDF2-SHA256. The file is decrypted on first access and kept in memory afterwards.
+type FileStore struct {
+	Path string
+	// Passphrase returns the passphrase, confirm is set if the file is created and the passphrase should be
+	// asked for twice.
+	Passphrase func(confirm bool) (string, error)
+
+	key     []byte
+	salt    []byte
+	secrets map[string]string
+}
+
+// pbkdf2Iterations follows the OWASP recommendation for PBKDF2-HMAC-SHA256.
+const pbkdf2Iterations = 600000
+
+// secretFile is the format of the encrypted file.
+type secretFile struct {
+	Version    int    `json:"version"`
+	Iterations int    `json:"iterations"`
+	Salt       []byte `json:"salt"`
+	Nonce      []byte `json:"nonce"`
+	Ciphertext []byte `json:"ciphertext"`
+}
+
+func (f *FileStore) Get(key string) (string, error) {
+	if err := f.load(); err != nil {
+		return "", err
+	}
+	value, ok := f.secrets[key]
+	if !ok {
+		return "", ErrSecretNotFound
+	}
+	return value, nil
+}
+
+func (f *FileStore) Set(key, value string) error {
+	if err := f.load(); err != nil {
+		return err
+	}
+	f.secrets[key] = value
+	return f.save()
+}
+
+func (f *FileStore) Delete(key string) error {
+	if err := f.load(); err != nil {
+		return err
+	}
+	if _, ok := f.secrets[key]; !ok {
+		return ErrSecretNotFound
+	}
+	delete(f.secrets, key)
+	return f.save()
+}
+
+// load decrypts the file, or prepares a new one with a new salt if it does not exist yet.
+func (f *FileStore) load() error {
+	if f.secrets != nil {
+		return nil
+	}
+
+	data, err := os.ReadFile(f.Path)
+	if errors.Is(err, os.ErrNotExist) {
+		passphrase, err := f.Passphrase(true)
+		if err != nil {
+			return err
+		}
+		f.salt = make([]byte, 16)
+		if _, err := rand.Read(f.salt); err != nil {
+			return err
+		}
+		if f.key, err = pbkdf2.Key(sha256.New, passphrase, f.salt, pbkdf2Iterations, 32); err != nil {
+			return err
+		}
+		f.secrets = map[string]string{}
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("reading secret file %s: %w", f.Path, err)
+	}
+
+	var file secretFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return fmt.Errorf("decoding secret file %s: %w", f.Path, err)
+	}
+	passphrase, err := f.Passphrase(false)
+	if err != nil {
+		return err
+	}
+	key, err := pbkdf2.Key(sha256.New, passphrase, file.Salt, file.Iterations, 32)
+	if err != nil {
+		return err
+	}
+	gcm, err := newGCM(key)
+	if err != nil {
+		return err
+	}
+	plaintext, err := gcm.Open(nil, file.Nonce, file.Ciphertext, nil)
+	if err != nil {
+		return fmt.Errorf("decrypting secret file %s: wrong passphrase or corrupted file", f.Path)
+	}
+
+	secrets := map[string]string{}
+	if err := json.Unmarshal(plaintext, &secrets); err != nil {
+		return fmt.Errorf("decoding secret file %s: %w", f.Path, err)
+	}
+	f.key, f.salt, f.secrets = key, file.Salt, secrets
+	return nil
+}
+
+// save encrypts the secrets with a new nonce and replaces the file.
+func (f *FileStore) save() error {
+	plaintext, err := json.Marshal(f.secrets)
+	if err != nil {
+		return err
+	}
+	gcm, err := newGCM(f.key)
+	if err != nil {
+		return err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return err
+	}
+	data, err := json.Marshal(secretFile{
+		Version:    1,
+		Iterations: pbkdf2Iterations,
+		Salt:       f.salt,
+		Nonce:      nonce,
+		Ciphertext: gcm.Seal(nil, nonce, plaintext, nil),
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(f.Path), 0o700); err != nil {
+		return fmt.Errorf("creating secret file directory: %w", err)
+	}
+	tmp := f.Path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return fmt.Errorf("writing secret file %s: %w", f.Path, err)
+	}
+	if err := os.Rename(tmp, f.Path); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("writing secret file %s: %w", f.Path, err)
+	}
+	return nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// readPassphrase returns the passphrase of the secret file from the environment or the terminal.
+func readPassphrase(confirm bool) (string, error) {
+	if passphrase := os.Getenv(SecretsPassphraseEnv); passphrase != "" {
+		return passphrase, nil
+	}
+	if !term.IsTerminal(int(os.Stdin.Fd())) {
+		return "", fmt.Errorf("no terminal to read the passphrase of the secret file, set %s", SecretsPassphraseEnv)
+	}
+
+	fmt.Fprint(os.Stderr, "Passphrase for the secret file: ")
+	passphrase, err := term.ReadPassword(int(os.Stdin.Fd()))
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return "", fmt.Errorf("reading passphrase: %w", err)
+	}
+	if confirm {
+		fmt.Fprint(os.Stderr, "Repeat passphrase: ")
+		repeated, err := term.ReadPassword(int(os.Stdin.Fd()))
+		fmt.Fprintln(os.Stderr)
+		if err != nil {
+			return "", fmt.Errorf("reading passphrase: %w", err)
+		}
+		if !bytes.Equal(passphrase, repeated) {
+			return "", errors.New("passphrases do not match")
+		}
+	}
+	if len(passphrase) == 0 {
+		return "", errors.New("empty passphrase")
+	}
+	return string(passphrase), nil
+}
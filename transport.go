@@ -0,0 +1,267 @@
+package ovhwrapper
+
+import (
+	"context"
+	"io"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ovh/go-ovh/ovh"
+)
+
+// Defaults of the RetryPolicy, used for every value left empty in the configuration.
+const (
+	DefaultMaxRetries        = 4
+	DefaultMinBackoff        = 500 * time.Millisecond
+	DefaultMaxBackoff        = 30 * time.Second
+	DefaultRequestsPerSecond = 20
+)
+
+// RetryPolicy configures the retry and rate limit behaviour of the API clients.
+// Durations are given as strings in the configuration file, e.g. "500ms" or "30s".
+type RetryPolicy struct {
+	// MaxRetries is the number of retries of a failed idempotent request, a negative value disables retries.
+	MaxRetries int `yaml:"max_retries,omitempty" json:"max_retries,omitempty"`
+	// MinBackoff is the wait time before the first retry, it is doubled for every further retry.
+	MinBackoff time.Duration `yaml:"min_backoff,omitempty" json:"min_backoff,omitempty"`
+	// MaxBackoff caps the wait time between two retries, including waits requested by a Retry-After header.
+	MaxBackoff time.Duration `yaml:"max_backoff,omitempty" json:"max_backoff,omitempty"`
+	// RequestsPerSecond caps the number of requests sent by one client, a negative value disables the limit.
+	RequestsPerSecond float64 `yaml:"requests_per_second,omitempty" json:"requests_per_second,omitempty"`
+}
+
+// withDefaults returns a copy of the policy with all empty values replaced by their defaults.
+func (p RetryPolicy) withDefaults() RetryPolicy {
+	if p.MaxRetries == 0 {
+		p.MaxRetries = DefaultMaxRetries
+	}
+	if p.MinBackoff <= 0 {
+		p.MinBackoff = DefaultMinBackoff
+	}
+	if p.MaxBackoff <= 0 {
+		p.MaxBackoff = DefaultMaxBackoff
+	}
+	if p.MaxBackoff < p.MinBackoff {
+		p.MaxBackoff = p.MinBackoff
+	}
+	if p.RequestsPerSecond == 0 {
+		p.RequestsPerSecond = DefaultRequestsPerSecond
+	}
+	return p
+}
+
+// TransportStats holds the counters of a RetryTransport.
+type TransportStats struct {
+	Requests    int64 `yaml:"requests" json:"requests"`
+	Retries     int64 `yaml:"retries" json:"retries"`
+	RateLimited int64 `yaml:"rate_limited" json:"rate_limited"`
+	ServerError int64 `yaml:"server_errors" json:"server_errors"`
+	Failed      int64 `yaml:"failed" json:"failed"`
+}
+
+// RetryTransport is a http.RoundTripper that caps the request rate and retries idempotent requests
+// failing with 429, a 5xx gateway error or a network error, using exponential backoff with jitter.
+// A Retry-After header sent by the API takes precedence over the calculated backoff.
+type RetryTransport struct {
+	Base   http.RoundTripper
+	Policy RetryPolicy
+	// Debugf is called for every retry if set, e.g. with log.Printf when debug output is enabled.
+	Debugf func(format string, args ...any)
+
+	limiter limiter
+
+	requests    atomic.Int64
+	retries     atomic.Int64
+	rateLimited atomic.Int64
+	serverError atomic.Int64
+	failed      atomic.Int64
+}
+
+// NewRetryTransport wraps base (http.DefaultTransport if nil) into a RetryTransport using the given policy.
+func NewRetryTransport(base http.RoundTripper, policy RetryPolicy) *RetryTransport {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	policy = policy.withDefaults()
+	t := &RetryTransport{Base: base, Policy: policy}
+	if policy.RequestsPerSecond > 0 {
+		t.limiter.interval = time.Duration(float64(time.Second) / policy.RequestsPerSecond)
+	}
+	return t
+}
+
+// GetRetryTransport returns the RetryTransport installed in the given client, if any.
+func GetRetryTransport(client *ovh.Client) (*RetryTransport, bool) {
+	if client == nil || client.Client == nil {
+		return nil, false
+	}
+	t, ok := client.Client.Transport.(*RetryTransport)
+	return t, ok
+}
+
+// Stats returns a snapshot of the request and retry counters.
+func (t *RetryTransport) Stats() TransportStats {
+	return TransportStats{
+		Requests:    t.requests.Load(),
+		Retries:     t.retries.Load(),
+		RateLimited: t.rateLimited.Load(),
+		ServerError: t.serverError.Load(),
+		Failed:      t.failed.Load(),
+	}
+}
+
+func (t *RetryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+	retryable := isIdempotent(req.Method) && (req.Body == nil || req.Body == http.NoBody || req.GetBody != nil)
+
+	for attempt := 0; ; attempt++ {
+		if err := t.limiter.wait(ctx); err != nil {
+			return nil, err
+		}
+
+		if attempt > 0 && req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			req.Body = body
+		}
+
+		t.requests.Add(1)
+		resp, err := t.Base.RoundTrip(req)
+
+		if err == nil {
+			switch {
+			case resp.StatusCode == http.StatusTooManyRequests:
+				t.rateLimited.Add(1)
+			case retryableStatus(resp.StatusCode):
+				t.serverError.Add(1)
+			default:
+				return resp, nil
+			}
+		}
+
+		if !retryable || attempt >= t.Policy.MaxRetries || ctx.Err() != nil {
+			if err != nil || resp.StatusCode >= 400 {
+				t.failed.Add(1)
+			}
+			return resp, err
+		}
+
+		wait := t.backoff(attempt, resp)
+		t.retries.Add(1)
+		if t.Debugf != nil {
+			var reason string
+			if err != nil {
+				reason = err.Error()
+			} else {
+				reason = resp.Status
+			}
+			t.Debugf("retry %d/%d of %s %s in %s: %s", attempt+1, t.Policy.MaxRetries, req.Method,
+				req.URL.Path, wait.Round(time.Millisecond), reason)
+		}
+
+		if resp != nil {
+			// drain the body so the connection can be reused
+			_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+			_ = resp.Body.Close()
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// backoff returns the time to wait before the next attempt. It honours a Retry-After header given in
+// seconds or as HTTP date and otherwise doubles MinBackoff per attempt with a random jitter of up to 50%.
+func (t *RetryTransport) backoff(attempt int, resp *http.Response) time.Duration {
+	if resp != nil {
+		if d, ok := retryAfter(resp.Header.Get("Retry-After")); ok {
+			return min(d, t.Policy.MaxBackoff)
+		}
+	}
+
+	d := t.Policy.MinBackoff << attempt
+	if d <= 0 || d > t.Policy.MaxBackoff {
+		d = t.Policy.MaxBackoff
+	}
+	return d/2 + time.Duration(rand.Int63n(int64(d/2)+1))
+}
+
+// retryAfter parses the value of a Retry-After header.
+func retryAfter(value string) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		return max(time.Until(date), 0), true
+	}
+	return 0, false
+}
+
+// isIdempotent returns true for request methods which can safely be sent again.
+func isIdempotent(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return true
+	}
+	return false
+}
+
+// retryableStatus returns true for server errors which are usually transient.
+func retryableStatus(code int) bool {
+	switch code {
+	case http.StatusInternalServerError, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// limiter spaces requests by a fixed interval, an interval of 0 disables the limit.
+type limiter struct {
+	interval time.Duration
+
+	mu   sync.Mutex
+	next time.Time
+}
+
+// wait blocks until the next request may be sent or the context is canceled.
+func (l *limiter) wait(ctx context.Context) error {
+	if l.interval <= 0 {
+		return nil
+	}
+
+	l.mu.Lock()
+	now := time.Now()
+	slot := l.next
+	if slot.Before(now) {
+		slot = now
+	}
+	l.next = slot.Add(l.interval)
+	l.mu.Unlock()
+
+	delay := time.Until(slot)
+	if delay <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
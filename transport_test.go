@@ -0,0 +1,130 @@
+package ovhwrapper
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRetryAfter(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+		want  time.Duration
+		ok    bool
+	}{
+		{"empty", "", 0, false},
+		{"seconds", "3", 3 * time.Second, true},
+		{"zero", "0", 0, true},
+		{"negative", "-1", 0, false},
+		{"invalid", "soon", 0, false},
+		{"date in the past", "Mon, 02 Jan 2006 15:04:05 GMT", 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := retryAfter(tt.value)
+			if got != tt.want || ok != tt.ok {
+				t.Errorf("retryAfter(%q) = %s, %t, want %s, %t", tt.value, got, ok, tt.want, tt.ok)
+			}
+		})
+	}
+
+	date := time.Now().Add(time.Minute).UTC().Format(http.TimeFormat)
+	if got, ok := retryAfter(date); !ok || got <= 50*time.Second || got > time.Minute {
+		t.Errorf("retryAfter(%q) = %s, %t, want about a minute", date, got, ok)
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	transport := NewRetryTransport(nil, RetryPolicy{MinBackoff: 100 * time.Millisecond, MaxBackoff: time.Second})
+	retryAfterResp := func(value string) *http.Response {
+		return &http.Response{Header: http.Header{"Retry-After": []string{value}}}
+	}
+
+	tests := []struct {
+		name     string
+		attempt  int
+		resp     *http.Response
+		min, max time.Duration
+	}{
+		{"first retry", 0, nil, 50 * time.Millisecond, 100 * time.Millisecond},
+		{"doubled", 2, nil, 200 * time.Millisecond, 400 * time.Millisecond},
+		{"capped", 10, nil, 500 * time.Millisecond, time.Second},
+		{"overflow capped", 100, nil, 500 * time.Millisecond, time.Second},
+		{"retry-after", 0, retryAfterResp("0"), 0, 0},
+		{"retry-after capped", 0, retryAfterResp("120"), time.Second, time.Second},
+		{"invalid retry-after", 0, retryAfterResp("soon"), 50 * time.Millisecond, 100 * time.Millisecond},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for range 20 {
+				if got := transport.backoff(tt.attempt, tt.resp); got < tt.min || got > tt.max {
+					t.Fatalf("backoff(%d) = %s, want between %s and %s", tt.attempt, got, tt.min, tt.max)
+				}
+			}
+		})
+	}
+}
+
+// roundTripFunc is a fake http.RoundTripper.
+type roundTripFunc func(req *http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func TestRoundTripRetries(t *testing.T) {
+	respond := func(code int) (*http.Response, error) {
+		return &http.Response{StatusCode: code, Status: http.StatusText(code), Header: http.Header{},
+			Body: io.NopCloser(strings.NewReader(""))}, nil
+	}
+	errNetwork := errors.New("connection reset")
+
+	tests := []struct {
+		name      string
+		method    string
+		responses []int // 0 is a network error
+		wantCode  int
+		wantErr   bool
+		wantCalls int
+	}{
+		{"success", http.MethodGet, []int{200}, 200, false, 1},
+		{"client error is not retried", http.MethodGet, []int{404}, 404, false, 1},
+		{"rate limited then success", http.MethodGet, []int{429, 200}, 200, false, 2},
+		{"gateway errors then success", http.MethodGet, []int{502, 503, 200}, 200, false, 3},
+		{"network error then success", http.MethodGet, []int{0, 200}, 200, false, 2},
+		{"retries exhausted", http.MethodGet, []int{500, 500, 500}, 500, false, 3},
+		{"post is not retried", http.MethodPost, []int{503, 200}, 503, false, 1},
+		{"post network error", http.MethodPost, []int{0, 200}, 0, true, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var calls int
+			base := roundTripFunc(func(req *http.Request) (*http.Response, error) {
+				code := tt.responses[min(calls, len(tt.responses)-1)]
+				calls++
+				if code == 0 {
+					return nil, errNetwork
+				}
+				return respond(code)
+			})
+			transport := NewRetryTransport(base, RetryPolicy{MaxRetries: 2, MinBackoff: time.Millisecond,
+				MaxBackoff: time.Millisecond, RequestsPerSecond: -1})
+
+			req, _ := http.NewRequest(tt.method, "https://api.example/1.0/cloud/project", nil)
+			resp, err := transport.RoundTrip(req)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("RoundTrip error = %v, want error %t", err, tt.wantErr)
+			}
+			if err == nil && resp.StatusCode != tt.wantCode {
+				t.Errorf("RoundTrip status = %d, want %d", resp.StatusCode, tt.wantCode)
+			}
+			if calls != tt.wantCalls {
+				t.Errorf("RoundTrip sent %d requests, want %d", calls, tt.wantCalls)
+			}
+		})
+	}
+}